@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client"
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/model"
@@ -13,13 +14,14 @@ func main() {
 		fmt.Println("Please provide domain user and password e.g.: meow amazon.com your_amazon_user@email.com your_amazon_password")
 		os.Exit(1)
 	}
+	ctx := context.Background()
 	alexaClient := client.NewAlexaClient(os.Args[1], os.Args[2], os.Args[3], "cookies.data")
-	err := alexaClient.LogIn(false)
+	err := alexaClient.LogIn(ctx, false)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	devices, err := alexaClient.GetDevices()
+	devices, err := alexaClient.GetDevices(ctx)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -36,7 +38,7 @@ func main() {
 		)
 		fmt.Println("Meow!")
 		time.Sleep(3 * time.Second)
-		err = alexaClient.PostSequenceCmd(model.BuildSpeakCmd(
+		err = alexaClient.PostSequenceCmd(ctx, model.BuildSpeakCmd(
 			`<audio src="soundbank://soundlibrary/animals/amzn_sfx_cat_angry_meow_1x_02"/>`, "en-US",
 			device.DeviceType,
 			device.SerialNumber,
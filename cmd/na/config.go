@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ahimgit/navidrome-alexa/pkg/server"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const redacted = "<redacted>"
+
+var commandOnlyOptions = map[string]bool{"config": true, "print-config": true}
+
+const apiKeysOption = "apiKeys" // the only config file option without command line flag
+var secretOptions = map[string]bool{"amazonPassword": true, "apiKey": true, "navidromePassword": true}
+
+// loadConfigFile reads config file by extension, keys are the same as command line flag names
+func loadConfigFile(path string) (values map[string]any, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading config file failed")
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, errors.New("config file " + path + " must be .yaml, .yml or .toml")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing config file "+path+" failed")
+	}
+	return values, nil
+}
+
+// applyConfigFile sets options from config file unless set by command line flag or env var, returns all invalid keys
+func applyConfigFile(values map[string]any) (invalid []string) {
+	setByFlag := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { setByFlag[f.Name] = true })
+	for _, name := range sortedKeys(values) {
+		if name == apiKeysOption {
+			continue
+		}
+		option := flag.Lookup(name)
+		if option == nil || commandOnlyOptions[name] {
+			invalid = append(invalid, "config file key "+name+" is unknown")
+			continue
+		}
+		value, ok := optionValue(option, values[name])
+		if !ok {
+			invalid = append(invalid, "config file key "+name+" must be a "+optionType(option))
+			continue
+		}
+		if _, fromEnv := os.LookupEnv(toEnvVarName(name)); fromEnv || setByFlag[name] {
+			continue
+		}
+		if err := option.Value.Set(value); err != nil {
+			invalid = append(invalid, "config file key "+name+" is invalid: "+err.Error())
+		}
+	}
+	return invalid
+}
+
+// apiKeysFromFile reads list of named keys: name, hash (hex encoded SHA-256 of the key) and scopes
+func apiKeysFromFile(values map[string]any) (keys []server.ApiKeyConfig, invalid []string) {
+	value, exists := values[apiKeysOption]
+	if !exists {
+		return nil, nil
+	}
+	list, ok := value.([]any)
+	if !ok {
+		return nil, []string{"config file key apiKeys must be a list"}
+	}
+	for i, item := range list {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			invalid = append(invalid, "config file key apiKeys #"+strconv.Itoa(i+1)+" must be a map with name, hash and scopes")
+			continue
+		}
+		var key server.ApiKeyConfig
+		for _, field := range sortedKeys(entry) {
+			fieldValue := entry[field]
+			switch field {
+			case "name":
+				key.Name, ok = fieldValue.(string)
+			case "hash":
+				key.Hash, ok = fieldValue.(string)
+			case "scopes":
+				key.Scopes, ok = stringList(fieldValue)
+			default:
+				ok = false
+			}
+			if !ok {
+				invalid = append(invalid, "config file key apiKeys #"+strconv.Itoa(i+1)+" field "+field+" is invalid")
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, invalid
+}
+
+func stringList(value any) (values []string, ok bool) {
+	list, ok := value.([]any)
+	if !ok {
+		return nil, false
+	}
+	for _, item := range list {
+		str, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, str)
+	}
+	return values, true
+}
+
+func sortedKeys(values map[string]any) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func optionValue(option *flag.Flag, value any) (string, bool) {
+	switch typed := value.(type) {
+	case []any:
+		list, ok := stringList(typed)
+		return strings.Join(list, ","), ok && optionType(option) == "list"
+	case bool:
+		return fmt.Sprint(typed), optionType(option) == "boolean"
+	case string:
+		return typed, optionType(option) == "string" || optionType(option) == "list"
+	case int, int64, uint64, float64: // unquoted numbers, e.g. apiKey: 12345
+		return fmt.Sprint(typed), optionType(option) == "string"
+	default:
+		return "", false
+	}
+}
+
+func optionType(option *flag.Flag) string {
+	switch option.Value.(flag.Getter).Get().(type) {
+	case bool:
+		return "boolean"
+	case []string:
+		return "list"
+	}
+	return "string"
+}
+
+// writeConfig writes effective config as YAML usable as config file, with secrets redacted
+func writeConfig(writer io.Writer, config *server.Config) error {
+	values := map[string]any{}
+	flag.VisitAll(func(option *flag.Flag) {
+		if commandOnlyOptions[option.Name] {
+			return
+		}
+		value := option.Value.(flag.Getter).Get()
+		if secretOptions[option.Name] && value != "" {
+			value = redacted
+		}
+		values[option.Name] = value
+	})
+	if len(config.ApiKeys) > 0 {
+		keys := make([]map[string]any, len(config.ApiKeys))
+		for i, key := range config.ApiKeys {
+			keys[i] = map[string]any{"name": key.Name, "hash": key.Hash, "scopes": key.Scopes}
+		}
+		values[apiKeysOption] = keys
+	}
+	return errors.Wrap(yaml.NewEncoder(writer).Encode(values), "writing config failed")
+}
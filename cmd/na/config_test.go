@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ahimgit/navidrome-alexa/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const yamlConfig = `
+amazonUser: amazonUserFromFile
+amazonPassword: amazonPasswordFromFile
+apiKey: apiKeyFromFile
+streamDomain: https://file.example.com
+alexaSkillId: amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd
+listenAddress: "localhost:9090"
+logStructured: true
+`
+
+const tomlConfig = `
+amazonUser = "amazonUserFromFile"
+apiKey = "apiKeyFromFile"
+streamDomain = "https://file.example.com"
+alexaSkillId = "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd"
+logStructured = true
+`
+
+func TestParseConfigurationFile(t *testing.T) {
+
+	t.Run("parse yaml config file", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", yamlConfig)
+		withArgs([]string{"command", "-config", path}, func() {
+			config, err := parseConfiguration()
+			assert.NoError(t, err)
+			assert.Equal(t, "amazon.com", config.AmazonDomain)
+			assert.Equal(t, "amazonUserFromFile", config.AmazonUser)
+			assert.Equal(t, "amazonPasswordFromFile", config.AmazonPassword)
+			assert.Equal(t, "apiKeyFromFile", config.ApiKey)
+			assert.Equal(t, "https://file.example.com", config.StreamDomain)
+			assert.Equal(t, "localhost:9090", config.ListenAddress)
+			assert.Equal(t, true, config.LogStructured)
+		})
+	})
+
+	t.Run("parse toml config file from env", func(t *testing.T) {
+		path := writeFile(t, "config.toml", tomlConfig)
+		withArgs([]string{"command"}, func() {
+			withEnv(map[string]string{"NA_CONFIG": path}, func() {
+				config, err := parseConfiguration()
+				assert.NoError(t, err)
+				assert.Equal(t, "amazonUserFromFile", config.AmazonUser)
+				assert.Equal(t, "apiKeyFromFile", config.ApiKey)
+				assert.Equal(t, true, config.LogStructured)
+			})
+		})
+	})
+
+	t.Run("flags and env take precedence over config file", func(t *testing.T) {
+		path := writeFile(t, "config.yml", yamlConfig)
+		withArgs([]string{"command", "-config", path, "-apiKey", "apiKeyFromFlag", "-logStructured=false"}, func() {
+			withEnv(map[string]string{"NA_API_KEY": "apiKeyFromEnv", "NA_AMAZON_USER": "amazonUserFromEnv"}, func() {
+				config, err := parseConfiguration()
+				assert.NoError(t, err)
+				assert.Equal(t, "apiKeyFromFlag", config.ApiKey)
+				assert.Equal(t, "amazonUserFromEnv", config.AmazonUser)
+				assert.Equal(t, "amazonPasswordFromFile", config.AmazonPassword)
+				assert.Equal(t, false, config.LogStructured)
+			})
+		})
+	})
+
+	t.Run("parse cors lists from config file, flag and env", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", yamlConfig+`
+corsAllowOrigins: [https://music.example.com, "http://localhost:4533"]
+corsAllowMethods: GET, POST
+corsAllowHeaders: [Authorization]
+`)
+		withArgs([]string{"command", "-config", path, "-corsAllowHeaders", "Authorization, Content-Type"}, func() {
+			withEnv(map[string]string{"NA_CORS_ALLOW_CREDENTIALS": "true"}, func() {
+				config, err := parseConfiguration()
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"https://music.example.com", "http://localhost:4533"}, config.CorsAllowOrigins)
+				assert.Equal(t, []string{"GET", "POST"}, config.CorsAllowMethods)
+				assert.Equal(t, []string{"Authorization", "Content-Type"}, config.CorsAllowHeaders)
+				assert.Equal(t, true, config.CorsAllowCredentials)
+			})
+		})
+	})
+
+	t.Run("cors wildcard with credentials is rejected", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", yamlConfig+`
+corsAllowOrigins: ["*"]
+corsAllowCredentials: true
+corsAllowMethods: [GET, 1]
+`)
+		withArgs([]string{"command", "-config", path}, func() {
+			_, err := parseConfiguration()
+			assert.EqualError(t, err, "config file key corsAllowMethods must be a list; "+
+				"corsAllowOrigins with * can't be used with corsAllowCredentials, list origins explicitly")
+		})
+	})
+
+	t.Run("config file errors are reported with validation errors", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", `
+apiKey: apiKeyFromFile
+logStructured: "yes"
+print-config: true
+unknownKey: value
+`)
+		withArgs([]string{"command", "-config", path}, func() {
+			_, err := parseConfiguration()
+			assert.EqualError(t, err, "config file key logStructured must be a boolean; "+
+				"config file key print-config is unknown; "+
+				"config file key unknownKey is unknown; "+
+				"streamDomain is required; "+
+				"alexaSkillId is required")
+		})
+	})
+
+	t.Run("unsupported config file", func(t *testing.T) {
+		path := writeFile(t, "config.json", "{}")
+		withArgs([]string{"command", "-config", path}, func() {
+			_, err := parseConfiguration()
+			assert.ErrorContains(t, err, "config file "+path+" must be .yaml, .yml or .toml")
+		})
+	})
+
+	t.Run("missing config file", func(t *testing.T) {
+		withArgs([]string{"command", "-config", filepath.Join(t.TempDir(), "missing.yaml")}, func() {
+			_, err := parseConfiguration()
+			assert.ErrorContains(t, err, "reading config file failed")
+		})
+	})
+
+}
+
+func TestParseConfigurationApiKeys(t *testing.T) {
+
+	hash := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	t.Run("parse api keys from yaml", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", `
+streamDomain: https://file.example.com
+alexaSkillId: amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd
+allowQueryApiKey: false
+apiKeys:
+  - name: widget
+    hash: `+hash+`
+    scopes: [read]
+  - name: remote
+    hash: `+hash+`
+    scopes: [read, control]
+`)
+		withArgs([]string{"command", "-config", path}, func() {
+			config, err := parseConfiguration()
+			assert.NoError(t, err)
+			assert.Equal(t, "", config.ApiKey)
+			assert.Equal(t, false, config.AllowQueryApiKey)
+			assert.Equal(t, []server.ApiKeyConfig{
+				{Name: "widget", Hash: hash, Scopes: []string{"read"}},
+				{Name: "remote", Hash: hash, Scopes: []string{"read", "control"}},
+			}, config.ApiKeys)
+		})
+	})
+
+	t.Run("parse api keys from toml", func(t *testing.T) {
+		path := writeFile(t, "config.toml", `
+streamDomain = "https://file.example.com"
+alexaSkillId = "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd"
+
+[[apiKeys]]
+name = "admin"
+hash = "`+hash+`"
+scopes = ["admin"]
+`)
+		withArgs([]string{"command", "-config", path}, func() {
+			config, err := parseConfiguration()
+			assert.NoError(t, err)
+			assert.Equal(t, []server.ApiKeyConfig{{Name: "admin", Hash: hash, Scopes: []string{"admin"}}}, config.ApiKeys)
+		})
+	})
+
+	t.Run("invalid api keys are reported", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", `
+apiKey: apiKeyFromFile
+streamDomain: https://file.example.com
+alexaSkillId: amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd
+apiKeys:
+  - widget
+  - name: remote
+    key: plainKey
+    scopes: read
+`)
+		withArgs([]string{"command", "-config", path}, func() {
+			_, err := parseConfiguration()
+			assert.EqualError(t, err, "config file key apiKeys #1 must be a map with name, hash and scopes; "+
+				"config file key apiKeys #2 field key is invalid; "+
+				"config file key apiKeys #2 field scopes is invalid; "+
+				"apiKeys remote hash must be hex encoded SHA-256; "+
+				"apiKeys remote scopes are required")
+		})
+	})
+
+	t.Run("print api keys", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		withArgs([]string{"command"}, func() {
+			assert.NoError(t, writeConfig(buf, &server.Config{ApiKeys: []server.ApiKeyConfig{{Name: "widget", Hash: hash, Scopes: []string{"read"}}}}))
+		})
+		assert.Equal(t, `apiKeys:
+    - hash: `+hash+`
+      name: widget
+      scopes:
+        - read
+`, buf.String())
+	})
+
+}
+
+func TestReloadConfiguration(t *testing.T) {
+
+	t.Run("reload reads changed config file, keeps command line", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", yamlConfig)
+		withArgs([]string{"command", "-config", path, "-amazonUser", "amazonUserFromFlag"}, func() {
+			_, err := parseConfiguration()
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(path, []byte(yamlConfig+"logLevel: info\n"), 0600))
+
+			config, err := reloadConfiguration()
+
+			assert.NoError(t, err)
+			assert.Equal(t, "info", config.LogLevel)
+			assert.Equal(t, "amazonUserFromFlag", config.AmazonUser)
+			assert.Equal(t, "apiKeyFromFile", config.ApiKey)
+		})
+	})
+
+}
+
+func TestWriteConfig(t *testing.T) {
+
+	t.Run("print effective config with secrets redacted", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", yamlConfig)
+		withArgs([]string{"command", "-config", path, "-print-config"}, func() {
+			config, err := parseConfiguration()
+			assert.NoError(t, err)
+			assert.True(t, printConfig)
+			buf := new(bytes.Buffer)
+			assert.NoError(t, writeConfig(buf, config))
+			assert.Equal(t, `alexaSkillDisplayName: ""
+alexaSkillId: amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd
+alexaSkillName: navi stream
+allowQueryApiKey: true
+amazonCookiePath: cookies.data
+amazonDomain: amazon.com
+amazonPassword: <redacted>
+amazonUser: amazonUserFromFile
+apiKey: <redacted>
+corsAllowCredentials: false
+corsAllowHeaders:
+    - Origin
+    - Authorization
+    - Content-Type
+    - X-Subsonic-User
+    - X-Subsonic-Token
+    - X-Subsonic-Salt
+corsAllowMethods:
+    - GET
+    - POST
+    - PUT
+    - PATCH
+    - DELETE
+corsAllowOrigins: []
+httpRedirectAddress: ""
+listenAddress: localhost:9090
+logIncomingRequests: false
+logLevel: debug
+logOutgoingRequests: false
+logStructured: true
+navidromeAuth: false
+navidromePassword: ""
+navidromeProxy: false
+navidromeURL: ""
+navidromeUser: ""
+streamDomain: https://file.example.com
+tlsCertFile: ""
+tlsKeyFile: ""
+tlsMinVersion: "1.2"
+tracingEndpoint: ""
+widgetProxyHosts: []
+`, buf.String())
+		})
+	})
+
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
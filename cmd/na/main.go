@@ -4,42 +4,94 @@ import (
 	"bytes"
 	"flag"
 	"github.com/ahimgit/navidrome-alexa/pkg/server"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/mid"
 	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
-	"log/slog"
 	"os"
 	"regexp"
 	"strings"
 )
 
+var configFile string // command only options, not part of server config
+var printConfig bool
+
 func main() {
-	config := parseConfiguration()
-	server.StartRouter(config)
+	config, err := parseConfiguration()
+	log.Init(config.LogStructured, config.Level())
+	if printConfig {
+		if printErr := writeConfig(os.Stdout, config); printErr != nil {
+			log.Logger().Error("Unable to print config", "error", printErr)
+		}
+	}
+	if err != nil {
+		log.Logger().Error("Invalid configuration", "error", err)
+		log.Logger().Info("Usage:\n" + usage())
+		os.Exit(1)
+	}
+	if printConfig {
+		return
+	}
+	server.StartRouter(config, reloadConfiguration)
 }
 
-func parseConfiguration() *server.Config {
+// reloadConfiguration parses the same command line again, env vars and config file are read anew
+func reloadConfiguration() (*server.Config, error) {
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	return parseConfiguration()
+}
+
+// parseConfiguration merges config in order of precedence: command line flags, NA_* env vars, config file, defaults
+func parseConfiguration() (*server.Config, error) {
 	config := new(server.Config)
+	getStr(&configFile, "config", "", "Path to YAML (.yaml, .yml) or TOML (.toml) config file with the same keys as command line flags.")
+	flag.BoolVar(&printConfig, "print-config", false, "Print effective config with secrets redacted and exit.")
 	getStr(&config.AmazonDomain, "amazonDomain", "amazon.com", "Base domain to use for Alexa API calls.")
 	getStr(&config.AmazonUser, "amazonUser", "", "Amazon account email with Alexa devices, can be left blank if auth cookies already exist.")
 	getStr(&config.AmazonPassword, "amazonPassword", "", "Amazon account password, can be left blank if auth cookies already exist.")
 	getStr(&config.AmazonCookiePath, "amazonCookiePath", "cookies.data", "Path to a writable file to store auth cookies.")
-	getStr(&config.ApiKey, "apiKey", "", "Required. API key to authenticate /client calls.")
+	getStr(&config.ApiKey, "apiKey", "", "API key with all scopes to authenticate /api calls. Required unless apiKeys are set in config file.")
+	getBool(&config.AllowQueryApiKey, "allowQueryApiKey", true, "Accept API key in apiKey query param, not only in Authorization header.")
+	getBool(&config.NavidromeAuth, "navidromeAuth", false, "Authenticate widget users with their Navidrome credentials instead of API key.")
+	getStr(&config.NavidromeURL, "navidromeURL", "", "Navidrome URL for Subsonic API calls, streamDomain if empty.")
+	getStr(&config.NavidromeUser, "navidromeUser", "", "Navidrome user for library search from remote UI. Off if empty.")
+	getStr(&config.NavidromePassword, "navidromePassword", "", "Navidrome password of navidromeUser.")
+	getBool(&config.NavidromeProxy, "navidromeProxy", false, "Reverse proxy Navidrome (navidromeURL) with widget injected, NA is served under /na.")
+	getList(&config.WidgetProxyHosts, "widgetProxyHosts", nil, "Comma separated hosts (host or host:port) /proxy may fetch Navidrome UI script from. navidromeURL (or streamDomain) host if empty.")
+	getList(&config.CorsAllowOrigins, "corsAllowOrigins", nil, "Comma separated origins allowed to call API from browser, * wildcard allowed. streamDomain origin if empty.")
+	getList(&config.CorsAllowMethods, "corsAllowMethods", mid.DefaultCorsMethods, "Comma separated methods allowed to call API from browser.")
+	getList(&config.CorsAllowHeaders, "corsAllowHeaders", mid.DefaultCorsHeaders, "Comma separated headers allowed to call API from browser.")
+	getBool(&config.CorsAllowCredentials, "corsAllowCredentials", false, "Allow browser calls with credentials (cookies), can't be used with wildcard origins.")
 	getStr(&config.StreamDomain, "streamDomain", "", "Required. Navidrome public server domain URL.")
 	getStr(&config.AlexaSkillId, "alexaSkillId", "", "Required. Skill id to authenticate calls from Alexa.")
 	getStr(&config.AlexaSkillName, "alexaSkillName", "navi stream", "Skill invocation name.")
+	getStr(&config.AlexaSkillDisplayName, "alexaSkillDisplayName", "", "Skill name Alexa shows as playback provider, if it differs from alexaSkillName.")
 	getStr(&config.ListenAddress, "listenAddress", ":8080", "Listen address.")
+	getStr(&config.TlsCertFile, "tlsCertFile", "", "Path to TLS certificate (chain) PEM file, serves HTTPS if set. Reloaded when changed.")
+	getStr(&config.TlsKeyFile, "tlsKeyFile", "", "Path to TLS private key PEM file, required with tlsCertFile.")
+	getStr(&config.TlsMinVersion, "tlsMinVersion", "1.2", "Minimum TLS version: 1.2 or 1.3.")
+	getStr(&config.HttpRedirectAddress, "httpRedirectAddress", "", "Listen address for plain HTTP redirecting to HTTPS, e.g. :80. Off if empty.")
 	getBool(&config.LogIncomingRequests, "logIncomingRequests", false, "Log API and Skill requests/responses.")
 	getBool(&config.LogOutgoingRequests, "logOutgoingRequests", false, "Log outgoing (to Alexa APIs) requests/responses. Will leak sensitive data into logs.")
 	getBool(&config.LogStructured, "logStructured", false, "Structured logs. Much JSON, Wow!")
+	getStr(&config.LogLevel, "logLevel", "debug", "Log level: debug, info, warn or error.")
+	getStr(&config.TracingEndpoint, "tracingEndpoint", "", "OTLP/HTTP traces endpoint URL, e.g. http://localhost:4318. Tracing is off when empty.")
 	flag.Parse()
-	log.Init(config.LogStructured, slog.LevelDebug)
-	validate("amazonDomain", config.AmazonDomain)
-	validate("amazonCookiePath", config.AmazonCookiePath)
-	validate("apiKey", config.ApiKey)
-	validate("streamDomain", config.StreamDomain)
-	validate("alexaSkillId", config.AlexaSkillId)
-	validate("alexaSkillName", config.AlexaSkillName)
-	validate("listenAddress", config.ListenAddress)
-	return config
+	var errors configErrors
+	if configFile != "" {
+		values, err := loadConfigFile(configFile)
+		if err != nil {
+			errors = append(errors, err.Error())
+		} else {
+			errors = append(errors, applyConfigFile(values)...)
+			var invalidKeys []string
+			config.ApiKeys, invalidKeys = apiKeysFromFile(values)
+			errors = append(errors, invalidKeys...)
+		}
+	}
+	errors = append(errors, config.Errors()...)
+	if len(errors) > 0 {
+		return config, errors
+	}
+	return config, nil
 }
 
 func getStr(flagPointer *string, flagName, defaultValue, usage string) {
@@ -60,19 +112,59 @@ func getBool(flagPointer *bool, flagName string, defaultValue bool, usage string
 	flag.BoolVar(flagPointer, flagName, *flagPointer, usage)
 }
 
+func getList(flagPointer *[]string, flagName string, defaultValue []string, usage string) {
+	envVar := toEnvVarName(flagName)
+	*flagPointer = defaultValue
+	if value, exists := os.LookupEnv(envVar); exists {
+		*flagPointer = splitList(value)
+	}
+	flag.Var((*listValue)(flagPointer), flagName, usage)
+}
+
+// listValue is a comma separated flag value, config file can have it as a list too
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = splitList(value)
+	return nil
+}
+
+func (l *listValue) Get() any {
+	return []string(*l)
+}
+
+func splitList(value string) (values []string) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
 func toEnvVarName(s string) string {
 	var re = regexp.MustCompile("([A-Z])")
 	snake := re.ReplaceAllString(s, "_$1")
 	return "NA_" + strings.ToUpper(strings.TrimLeft(snake, "_"))
 }
 
-func validate(name string, value string) {
-	if value == "" {
-		buf := new(bytes.Buffer)
-		flag.CommandLine.SetOutput(buf)
-		flag.PrintDefaults()
-		log.Logger().Error("Error. Param " + name + " is required.")
-		log.Logger().Info("Usage:\n" + buf.String())
-		os.Exit(0)
-	}
+func usage() string {
+	buf := new(bytes.Buffer)
+	flag.CommandLine.SetOutput(buf)
+	flag.PrintDefaults()
+	return buf.String()
+}
+
+// configErrors reports all validation errors together
+type configErrors []string
+
+func (e configErrors) Error() string {
+	return strings.Join(e, "; ")
 }
@@ -18,23 +18,26 @@ func TestParseConfiguration(t *testing.T) {
 			withEnv(map[string]string{
 				"NA_AMAZON_USER":     "amazonUserValue",
 				"NA_AMAZON_PASSWORD": "amazonPasswordValue",
-				"NA_ALEXA_SKILL_ID":  "alexaSkillIdValue",
-				"NA_STREAM_DOMAIN":   "navidrome.example.com",
+				"NA_ALEXA_SKILL_ID":  "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd",
+				"NA_STREAM_DOMAIN":   "https://navidrome.example.com",
 				"NA_API_KEY":         "apiKeyValue",
 			}, func() {
-				config := parseConfiguration()
+				config, err := parseConfiguration()
+				assert.NoError(t, err)
 				assert.Equal(t, "amazon.com", config.AmazonDomain)
 				assert.Equal(t, "amazonUserValue", config.AmazonUser)
 				assert.Equal(t, "amazonPasswordValue", config.AmazonPassword)
 				assert.Equal(t, "cookies.data", config.AmazonCookiePath)
-				assert.Equal(t, "alexaSkillIdValue", config.AlexaSkillId)
+				assert.Equal(t, "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd", config.AlexaSkillId)
 				assert.Equal(t, "navi stream", config.AlexaSkillName)
-				assert.Equal(t, "navidrome.example.com", config.StreamDomain)
+				assert.Equal(t, "https://navidrome.example.com", config.StreamDomain)
 				assert.Equal(t, "apiKeyValue", config.ApiKey)
 				assert.Equal(t, ":8080", config.ListenAddress)
 				assert.Equal(t, false, config.LogIncomingRequests)
 				assert.Equal(t, false, config.LogOutgoingRequests)
 				assert.Equal(t, false, config.LogStructured)
+				assert.Equal(t, "debug", config.LogLevel)
+				assert.Equal(t, "", config.TracingEndpoint)
 			})
 		})
 	})
@@ -43,23 +46,26 @@ func TestParseConfiguration(t *testing.T) {
 		withArgs([]string{"command",
 			"-amazonUser", "amazonUserValue",
 			"-amazonPassword", "amazonPasswordValue",
-			"-alexaSkillId", "alexaSkillIdValue",
-			"-streamDomain", "navidrome.example.com",
+			"-alexaSkillId", "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd",
+			"-streamDomain", "https://navidrome.example.com",
 			"-apiKey", "apiKeyValue",
 		}, func() {
-			config := parseConfiguration()
+			config, err := parseConfiguration()
+			assert.NoError(t, err)
 			assert.Equal(t, "amazon.com", config.AmazonDomain)
 			assert.Equal(t, "amazonUserValue", config.AmazonUser)
 			assert.Equal(t, "amazonPasswordValue", config.AmazonPassword)
 			assert.Equal(t, "cookies.data", config.AmazonCookiePath)
-			assert.Equal(t, "alexaSkillIdValue", config.AlexaSkillId)
+			assert.Equal(t, "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd", config.AlexaSkillId)
 			assert.Equal(t, "navi stream", config.AlexaSkillName)
-			assert.Equal(t, "navidrome.example.com", config.StreamDomain)
+			assert.Equal(t, "https://navidrome.example.com", config.StreamDomain)
 			assert.Equal(t, "apiKeyValue", config.ApiKey)
 			assert.Equal(t, ":8080", config.ListenAddress)
 			assert.Equal(t, false, config.LogIncomingRequests)
 			assert.Equal(t, false, config.LogOutgoingRequests)
 			assert.Equal(t, false, config.LogStructured)
+			assert.Equal(t, "debug", config.LogLevel)
+			assert.Equal(t, "", config.TracingEndpoint)
 
 		})
 	})
@@ -70,28 +76,37 @@ func TestParseConfiguration(t *testing.T) {
 			"-amazonUser", "amazonUserValue",
 			"-amazonPassword", "amazonPasswordValue",
 			"-amazonCookiePath", "amazonCookiePathValue",
-			"-alexaSkillId", "alexaSkillIdValue",
+			"-alexaSkillId", "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd",
 			"-alexaSkillName", "alexaSkillNameValue",
-			"-streamDomain", "navidrome.example.com",
+			"-streamDomain", "https://navidrome.example.com",
 			"-apiKey", "apiKeyValue",
 			"-listenAddress", "localhost:9090",
 			"-logIncomingRequests",
 			"-logOutgoingRequests",
 			"-logStructured",
+			"-logLevel", "info",
+			"-navidromeAuth",
+			"-navidromeURL", "http://localhost:4533",
+			"-tracingEndpoint", "http://localhost:4318",
 		}, func() {
-			config := parseConfiguration()
+			config, err := parseConfiguration()
+			assert.NoError(t, err)
 			assert.Equal(t, "amazon.example.com", config.AmazonDomain)
 			assert.Equal(t, "amazonUserValue", config.AmazonUser)
 			assert.Equal(t, "amazonPasswordValue", config.AmazonPassword)
 			assert.Equal(t, "amazonCookiePathValue", config.AmazonCookiePath)
-			assert.Equal(t, "alexaSkillIdValue", config.AlexaSkillId)
+			assert.Equal(t, "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd", config.AlexaSkillId)
 			assert.Equal(t, "alexaSkillNameValue", config.AlexaSkillName)
-			assert.Equal(t, "navidrome.example.com", config.StreamDomain)
+			assert.Equal(t, "https://navidrome.example.com", config.StreamDomain)
 			assert.Equal(t, "apiKeyValue", config.ApiKey)
 			assert.Equal(t, "localhost:9090", config.ListenAddress)
 			assert.Equal(t, true, config.LogIncomingRequests)
 			assert.Equal(t, true, config.LogOutgoingRequests)
 			assert.Equal(t, true, config.LogStructured)
+			assert.Equal(t, "info", config.LogLevel)
+			assert.Equal(t, true, config.NavidromeAuth)
+			assert.Equal(t, "http://localhost:4533", config.NavidromeURL)
+			assert.Equal(t, "http://localhost:4318", config.TracingEndpoint)
 		})
 	})
 
@@ -102,37 +117,48 @@ func TestParseConfiguration(t *testing.T) {
 				"NA_AMAZON_USER":           "amazonUserValue",
 				"NA_AMAZON_PASSWORD":       "amazonPasswordValue",
 				"NA_AMAZON_COOKIE_PATH":    "amazonCookiePathValue",
-				"NA_ALEXA_SKILL_ID":        "alexaSkillIdValue",
+				"NA_ALEXA_SKILL_ID":        "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd",
 				"NA_ALEXA_SKILL_NAME":      "alexaSkillNameValue",
-				"NA_STREAM_DOMAIN":         "navidrome.example.com",
+				"NA_STREAM_DOMAIN":         "https://navidrome.example.com",
 				"NA_API_KEY":               "apiKeyValue",
 				"NA_LISTEN_ADDRESS":        "localhost:9090",
 				"NA_LOG_INCOMING_REQUESTS": "true",
 				"NA_LOG_OUTGOING_REQUESTS": "true",
 				"NA_LOG_STRUCTURED":        "true",
+				"NA_LOG_LEVEL":             "warn",
+				"NA_TRACING_ENDPOINT":      "http://localhost:4318",
 			}, func() {
-				config := parseConfiguration()
+				config, err := parseConfiguration()
+				assert.NoError(t, err)
 				assert.Equal(t, "amazon.example.com", config.AmazonDomain)
 				assert.Equal(t, "amazonUserValue", config.AmazonUser)
 				assert.Equal(t, "amazonPasswordValue", config.AmazonPassword)
 				assert.Equal(t, "amazonCookiePathValue", config.AmazonCookiePath)
-				assert.Equal(t, "alexaSkillIdValue", config.AlexaSkillId)
+				assert.Equal(t, "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd", config.AlexaSkillId)
 				assert.Equal(t, "alexaSkillNameValue", config.AlexaSkillName)
-				assert.Equal(t, "navidrome.example.com", config.StreamDomain)
+				assert.Equal(t, "https://navidrome.example.com", config.StreamDomain)
 				assert.Equal(t, "apiKeyValue", config.ApiKey)
 				assert.Equal(t, "localhost:9090", config.ListenAddress)
 				assert.Equal(t, true, config.LogIncomingRequests)
 				assert.Equal(t, true, config.LogOutgoingRequests)
 				assert.Equal(t, true, config.LogStructured)
+				assert.Equal(t, "warn", config.LogLevel)
+				assert.Equal(t, "http://localhost:4318", config.TracingEndpoint)
 			})
 		})
 	})
 
-	t.Run("parse invalid config", func(t *testing.T) {
-		withArgs([]string{"command"}, func() {
-			assert.Panics(t, func() {
-				parseConfiguration()
-			})
+	t.Run("parse invalid config, reports all errors", func(t *testing.T) {
+		withArgs([]string{"command",
+			"-streamDomain", "navidrome.example.com",
+			"-alexaSkillId", "alexaSkillIdValue",
+			"-listenAddress", "9090",
+		}, func() {
+			_, err := parseConfiguration()
+			assert.EqualError(t, err, "apiKey or apiKeys is required; "+
+				"streamDomain must be an absolute http(s) URL, e.g. https://navidrome.example.com; "+
+				"alexaSkillId must look like amzn1.ask.skill.xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx; "+
+				"listenAddress must be [host]:port, e.g. :8080")
 		})
 	})
 
@@ -220,11 +246,6 @@ func TestToUpperSnakeCase(t *testing.T) {
 	assert.Equal(t, "NA_API_KEY", toEnvVarName("apiKey"))
 }
 
-func TestValidate(t *testing.T) {
-	assert.NotPanics(t, func() { validate("testVar", "value") }, "Should not panic if value is set")
-	assert.Panics(t, func() { validate("testVar", "") }, "Should panic if value is empty")
-}
-
 func withArgs(args []string, code func()) {
 	mutexArg.Lock()
 	savedArgs := os.Args
@@ -240,14 +261,22 @@ func withArgs(args []string, code func()) {
 
 func withEnv(envVars map[string]string, code func()) {
 	mutexEnv.Lock()
-	originalValues := make(map[string]string)
+	originalValues := make(map[string]*string)
 	for key, value := range envVars {
-		originalValues[key] = os.Getenv(key)
+		if originalValue, exists := os.LookupEnv(key); exists {
+			originalValues[key] = &originalValue
+		} else {
+			originalValues[key] = nil
+		}
 		_ = os.Setenv(key, value)
 	}
 	defer func() {
 		for key, originalValue := range originalValues {
-			_ = os.Setenv(key, originalValue)
+			if originalValue == nil {
+				_ = os.Unsetenv(key)
+			} else {
+				_ = os.Setenv(key, *originalValue)
+			}
 		}
 		mutexEnv.Unlock()
 	}()
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+type circuitState string
+
+const (
+	CircuitClosed   circuitState = "closed"
+	CircuitOpen     circuitState = "open"
+	CircuitHalfOpen circuitState = "half-open"
+)
+
+var ErrCircuitOpen = errors.New("Alexa circuit breaker is open, failing fast")
+
+type CircuitBreakerState struct {
+	State               circuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	OpenedAt            *time.Time   `json:"openedAt,omitempty"`
+}
+
+// CircuitBreaker stops calling Amazon after FailureThreshold consecutive transient failures,
+// after OpenTimeout a single probe call is let through (half-open) and its result closes or re-opens it,
+// other calls fail fast until then. A probe that never reports back is replaced after another OpenTimeout.
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	state            circuitState
+	failures         int
+	openedAt         time.Time
+	probeStartedAt   time.Time
+	now              func() time.Time
+	mutex            sync.Mutex
+}
+
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		OpenTimeout:      openTimeout,
+		state:            CircuitClosed,
+		now:              time.Now,
+	}
+}
+
+func (b *CircuitBreaker) Allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+	case CircuitHalfOpen:
+		if b.now().Sub(b.probeStartedAt) < b.OpenTimeout { // probe in flight
+			return ErrCircuitOpen
+		}
+	default:
+		return nil
+	}
+	b.probeStartedAt = b.now()
+	return nil
+}
+
+func (b *CircuitBreaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.state = CircuitClosed
+	b.failures = 0
+}
+
+func (b *CircuitBreaker) Failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+func (b *CircuitBreaker) State() CircuitBreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	state := CircuitBreakerState{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		state.OpenedAt = &openedAt
+	}
+	return state
+}
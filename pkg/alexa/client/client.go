@@ -1,12 +1,18 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/httpclient"
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/model"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/codes"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -16,10 +22,13 @@ const (
 )
 
 type IAlexaClient interface {
-	LogIn(relog bool) (err error)
-	PostSequenceCmd(command model.AlexaCmd) (err error)
-	GetDevices() (devices model.DevicesResponse, err error)
-	GetVolume() (devices model.VolumeResponse, err error)
+	LogIn(ctx context.Context, relog bool) (err error)
+	PostSequenceCmd(ctx context.Context, command model.AlexaCmd) (err error)
+	GetDevices(ctx context.Context) (devices model.DevicesResponse, err error)
+	GetVolume(ctx context.Context) (devices model.VolumeResponse, err error)
+	GetPlayerState(ctx context.Context, device model.DeviceTarget) (state model.PlayerStateResponse, err error)
+	GetCircuitBreakerState() CircuitBreakerState
+	GetSessionState() SessionState
 }
 
 type AlexaClient struct {
@@ -28,9 +37,38 @@ type AlexaClient struct {
 	baseDomain   string
 	user         string
 	password     string
-	csrf         string
-	retries      int
 	retriesMax   int
+	retryPolicy  *RetryPolicy
+	breaker      *CircuitBreaker
+	callTimeout  time.Duration // deadline for a single http call, retries and login are bounded by caller's context
+	session      session
+	lastSuccess  atomic.Int64 // unix nanos of last successful call, 0 if none
+	lastFailure  atomic.Int64 // unix nanos of last call that failed with amazon side error, 0 if none
+}
+
+// session is shared by concurrent calls, generation changes on every successful login,
+// so callers that got an auth error with an older session just retry instead of logging in again
+type session struct {
+	mutex      sync.Mutex
+	csrf       string
+	generation int
+	loggedInAt time.Time
+	login      *loginCall // in-progress login, other callers wait for its result
+}
+
+// SessionState is a snapshot for health reporting, zero times mean it did not happen yet
+type SessionState struct {
+	LoggedIn       bool                `json:"loggedIn"`
+	LoggedInAt     time.Time           `json:"loggedInAt"`     // login or loading saved cookies
+	CookiesSavedAt time.Time           `json:"cookiesSavedAt"` // last login with user and password
+	LastSuccessAt  time.Time           `json:"lastSuccessAt"`
+	LastFailureAt  time.Time           `json:"lastFailureAt"`
+	CircuitBreaker CircuitBreakerState `json:"circuitBreaker"`
+}
+
+type loginCall struct {
+	done chan struct{}
+	err  error
 }
 
 func NewAlexaClient(baseDomain string, user string, password string, cookieFile string) IAlexaClient {
@@ -41,6 +79,9 @@ func NewAlexaClient(baseDomain string, user string, password string, cookieFile
 		user:         user,
 		password:     password,
 		retriesMax:   1,
+		retryPolicy:  DefaultRetryPolicy(),
+		breaker:      NewCircuitBreaker(5, 30*time.Second),
+		callTimeout:  5 * time.Second,
 	}
 }
 
@@ -53,22 +94,74 @@ func NewAlexaClientWithHttpClient(baseDomain string, user string, password strin
 		user:         user,
 		password:     password,
 		retriesMax:   1,
+		retryPolicy:  DefaultRetryPolicy(),
+		breaker:      NewCircuitBreaker(5, 30*time.Second),
+		callTimeout:  5 * time.Second,
+	}
+}
+
+// LogIn runs at most one login at a time, concurrent callers wait for and share its result
+func (c *AlexaClient) LogIn(ctx context.Context, relog bool) (err error) {
+	return c.logInOnce(ctx, relog, -1)
+}
+
+// logInOnce skips login if session was already renewed since staleGeneration (-1 to always login)
+func (c *AlexaClient) logInOnce(ctx context.Context, relog bool, staleGeneration int) error {
+	c.session.mutex.Lock()
+	if staleGeneration >= 0 && c.session.generation != staleGeneration {
+		c.session.mutex.Unlock()
+		return nil
+	}
+	if call := c.session.login; call != nil {
+		c.session.mutex.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "Alexa.LogIn waiting for login")
+		}
+	}
+	call := &loginCall{done: make(chan struct{})}
+	c.session.login = call
+	c.session.mutex.Unlock()
+
+	// not cancelled with the caller, others may be waiting for it, each login step has its own deadline
+	fullLogin := relog || !c.cookieHelper.CookiesSaved()
+	csrf, err := c.logIn(context.WithoutCancel(ctx), relog)
+	observeLogin(relog, fullLogin, err)
+
+	c.session.mutex.Lock()
+	if err == nil {
+		c.session.csrf = csrf
+		c.session.generation++
+		c.session.loggedInAt = time.Now()
 	}
+	c.session.login = nil
+	call.err = err
+	c.session.mutex.Unlock()
+	close(call.done)
+	return err
 }
 
-func (c *AlexaClient) LogIn(relog bool) (err error) {
+func (c *AlexaClient) getSession() (csrf string, generation int) {
+	c.session.mutex.Lock()
+	defer c.session.mutex.Unlock()
+	return c.session.csrf, c.session.generation
+}
+
+func (c *AlexaClient) logIn(ctx context.Context, relog bool) (csrf string, err error) {
 	if relog || !c.cookieHelper.CookiesSaved() {
 		if relog {
 			c.client.ResetCookieJar()
 		}
 		if c.user == "" || c.password == "" {
-			return errors.New("Alexa.LogIn no saved cookies, user and password are required but empty")
+			return "", errors.New("Alexa.LogIn no saved cookies, user and password are required but empty")
 		}
 
 		// step 0: get login form
-		pageHtmlFromStep0, referer, err := getLoginForm(c.baseDomain, c.client)
+		pageHtmlFromStep0, referer, err := getLoginForm(ctx, c.callTimeout, c.baseDomain, c.client)
 		if err != nil {
-			return errors.Wrap(err, "Alexa.LogIn getting form failed")
+			return "", errors.Wrap(err, "Alexa.LogIn getting form failed")
 		}
 
 		// step 1: submit login form with email w/o password
@@ -76,9 +169,9 @@ func (c *AlexaClient) LogIn(relog bool) (err error) {
 		formDataForStep1 := c.cookieHelper.ExtractLoginFormInputs(formHtmlFromStep0)
 		formDataForStep1.Add("email", c.user)
 		formDataForStep1.Add("password", "")
-		pageHtmlFromStep1, err := submitLoginForm(c.baseDomain, referer, formDataForStep1, c.client)
+		pageHtmlFromStep1, err := submitLoginForm(ctx, c.callTimeout, c.baseDomain, referer, formDataForStep1, c.client)
 		if err != nil {
-			return errors.Wrap(err, "Alexa.LogIn submit step 1 login form failed")
+			return "", errors.Wrap(err, "Alexa.LogIn submit step 1 login form failed")
 		}
 
 		// step 2: submit login form with (hidden input in real form) email and password
@@ -86,63 +179,75 @@ func (c *AlexaClient) LogIn(relog bool) (err error) {
 		formDataForStep2 := c.cookieHelper.ExtractLoginFormInputs(formHtmlFromStep1)
 		formDataForStep2.Add("email", c.user)
 		formDataForStep2.Add("password", c.password)
-		_, err = submitLoginFormFinal(c.baseDomain, referer, formDataForStep2, c.client)
+		_, err = submitLoginFormFinal(ctx, c.callTimeout, c.baseDomain, referer, formDataForStep2, c.client)
 		if err != nil {
-			return errors.Wrap(err, "Alexa.LogIn submit step 2 login form failed")
+			return "", errors.Wrap(err, "Alexa.LogIn submit step 2 login form failed")
 		}
 
 		// get devices (sets csrf cookie) and save cookies
-		_, err = c.GetDevices()
-		if err != nil {
-			return errors.Wrap(err, "Alexa.LogIn getting devices failed")
+		if _, err = c.getDevices(ctx, true); err != nil {
+			return "", errors.Wrap(err, "Alexa.LogIn getting devices failed")
 		}
 		if err := c.cookieHelper.SaveCookies(c.client.GetCookieJar(), c.baseDomain); err != nil {
-			return errors.Wrap(err, "Alexa.LogIn saving cookies failed")
+			return "", errors.Wrap(err, "Alexa.LogIn saving cookies failed")
 		}
 	} else {
 		if err := c.cookieHelper.LoadCookies(c.client.GetCookieJar(), c.baseDomain); err != nil {
-			return errors.Wrap(err, "Alexa.LogIn loading cookies failed")
+			return "", errors.Wrap(err, "Alexa.LogIn loading cookies failed")
 		}
 	}
-	csrf := c.cookieHelper.ExtractCSRF(c.client.GetCookieJar(), c.baseDomain)
+	csrf = c.cookieHelper.ExtractCSRF(c.client.GetCookieJar(), c.baseDomain)
 	if csrf == "" {
-		return errors.New("Alexa.LogIn empty csrf cookie")
+		return "", errors.New("Alexa.LogIn empty csrf cookie")
 	}
-	c.csrf = csrf // sets csrf param
-	return nil
+	return csrf, nil
 }
 
-func (c *AlexaClient) PostSequenceCmd(command model.AlexaCmd) (err error) {
-	if err = c.retry(func() error {
+func (c *AlexaClient) PostSequenceCmd(ctx context.Context, command model.AlexaCmd) (err error) {
+	if err = c.retry(ctx, "PostSequenceCmd", RetryNotSent, func(ctx context.Context, csrf string) error {
 		apiUrl := fmt.Sprintf("https://alexa.%s/api/behaviors/preview", c.baseDomain)
-		return c.client.RestPOST(apiUrl, buildAppHeaders(c.csrf), command, nil)
+		return c.client.RestPOST(ctx, apiUrl, buildAppHeaders(csrf), command, nil)
 	}); err != nil {
 		return errors.Wrap(err, "Alexa.PostSequenceCmd failed")
 	}
 	return nil
 }
 
-func (c *AlexaClient) GetDevices() (devices model.DevicesResponse, err error) {
-	if err = c.retry(func() error {
-		apiUrl := fmt.Sprintf("https://alexa.%s/api/devices-v2/device?cached=false", c.baseDomain)
-		return c.client.RestGET(apiUrl, buildAppHeaders(c.csrf), &devices)
+func (c *AlexaClient) GetDevices(ctx context.Context) (devices model.DevicesResponse, err error) {
+	return c.getDevices(ctx, false)
+}
+
+func (c *AlexaClient) getDevices(ctx context.Context, loggingIn bool) (devices model.DevicesResponse, err error) {
+	if err = c.call(ctx, "GetDevices", loggingIn, RetryTransient, func(ctx context.Context, csrf string) error {
+		return c.client.RestGET(ctx, devicesURL(c.baseDomain), buildAppHeaders(csrf), &devices)
 	}); err != nil {
 		return devices, errors.Wrap(err, "Alexa.GetDevices failed")
 	}
 	return devices, nil
 }
 
-func (c *AlexaClient) GetVolume() (volume model.VolumeResponse, err error) {
-	if err = c.retry(func() error {
+func (c *AlexaClient) GetVolume(ctx context.Context) (volume model.VolumeResponse, err error) {
+	if err = c.retry(ctx, "GetVolume", RetryTransient, func(ctx context.Context, csrf string) error {
 		apiUrl := fmt.Sprintf("https://alexa.%s/api/devices/deviceType/dsn/audio/v1/allDeviceVolumes", c.baseDomain)
-		return c.client.RestGET(apiUrl, buildAppHeaders(c.csrf), &volume)
+		return c.client.RestGET(ctx, apiUrl, buildAppHeaders(csrf), &volume)
 	}); err != nil {
 		return volume, errors.Wrap(err, "Alexa.GetVolume failed")
 	}
 	return volume, nil
 }
 
-func getLoginForm(baseDomain string, client httpclient.IHttpClient) (pageHtml string, referer string, err error) {
+func (c *AlexaClient) GetPlayerState(ctx context.Context, device model.DeviceTarget) (state model.PlayerStateResponse, err error) {
+	if err = c.retry(ctx, "GetPlayerState", RetryTransient, func(ctx context.Context, csrf string) error {
+		apiUrl := fmt.Sprintf("https://alexa.%s/api/np/player?deviceSerialNumber=%s&deviceType=%s&screenWidth=1440",
+			c.baseDomain, url.QueryEscape(device.DeviceSerialNumber), url.QueryEscape(device.DeviceType))
+		return c.client.RestGET(ctx, apiUrl, buildAppHeaders(csrf), &state)
+	}); err != nil {
+		return state, errors.Wrap(err, "Alexa.GetPlayerState failed")
+	}
+	return state, nil
+}
+
+func getLoginForm(ctx context.Context, timeout time.Duration, baseDomain string, client httpclient.IHttpClient) (pageHtml string, referer string, err error) {
 	formUrl := "https://www." + baseDomain + "/ap/signin" +
 		"?openid.pape.max_auth_age=0" +
 		"&openid.identity=http%3A%2F%2Fspecs.openid.net%2Fauth%2F2.0%2Fidentifier_select" +
@@ -161,7 +266,9 @@ func getLoginForm(baseDomain string, client httpclient.IHttpClient) (pageHtml st
 		"&openid.oa2.client_id=" +
 		"&disableLoginPrepopulate=0" +
 		"&openid.ns=http%3A%2F%2Fspecs.openid.net%2Fauth%2F2.0" // params order matters ;(
-	response, err := client.SimpleGET(formUrl, buildWebViewHeaders(referer))
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	response, err := client.SimpleGET(ctx, formUrl, buildWebViewHeaders(referer))
 	if err != nil {
 		return "", "", errors.Wrap(err, "getting login form failed")
 	}
@@ -171,9 +278,11 @@ func getLoginForm(baseDomain string, client httpclient.IHttpClient) (pageHtml st
 	return response.Body, formUrl, nil
 }
 
-func submitLoginForm(baseDomain string, referer string, formData *url.Values, client httpclient.IHttpClient) (pageHtml string, err error) {
+func submitLoginForm(ctx context.Context, timeout time.Duration, baseDomain string, referer string, formData *url.Values, client httpclient.IHttpClient) (pageHtml string, err error) {
 	formUrl := fmt.Sprintf("https://www.%s/ap/signin", baseDomain)
-	response, err := client.SimplePOST(formUrl, buildWebViewHeaders(referer), formData)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	response, err := client.SimplePOST(ctx, formUrl, buildWebViewHeaders(referer), formData)
 	if err != nil {
 		return "", errors.Wrap(err, "submit failed")
 	}
@@ -183,9 +292,11 @@ func submitLoginForm(baseDomain string, referer string, formData *url.Values, cl
 	return response.Body, nil
 }
 
-func submitLoginFormFinal(baseDomain string, referer string, formData *url.Values, client httpclient.IHttpClient) (pageHtml string, err error) {
+func submitLoginFormFinal(ctx context.Context, timeout time.Duration, baseDomain string, referer string, formData *url.Values, client httpclient.IHttpClient) (pageHtml string, err error) {
 	formUrl := fmt.Sprintf("https://www.%s/ap/signin", baseDomain)
-	response, err := client.SimplePOST(formUrl, buildWebViewHeaders(referer), formData)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	response, err := client.SimplePOST(ctx, formUrl, buildWebViewHeaders(referer), formData)
 	if err != nil {
 		return "", errors.Wrap(err, "submit failed")
 	}
@@ -198,6 +309,10 @@ func submitLoginFormFinal(baseDomain string, referer string, formData *url.Value
 	return response.Body, nil
 }
 
+func devicesURL(baseDomain string) string {
+	return fmt.Sprintf("https://alexa.%s/api/devices-v2/device?cached=false", baseDomain)
+}
+
 func buildAppHeaders(csrf string) (headers *httpclient.Headers) {
 	return &httpclient.Headers{
 		{Key: "Accept", Value: "application/json; charset=utf-8"},
@@ -225,16 +340,83 @@ func buildWebViewHeaders(referer string) (headers *httpclient.Headers) {
 	return &headersCollection
 }
 
-func (c *AlexaClient) retry(retryBlock func() error) error {
-	err := retryBlock()
-	for httpclient.IsAuthError(err) && c.retries < c.retriesMax { // while auth error and have retries
-		c.retries++
-		if err = c.LogIn(true); err == nil { // re-login and call again
-			err = retryBlock()
+func (c *AlexaClient) GetCircuitBreakerState() CircuitBreakerState {
+	return c.breaker.State()
+}
+
+func (c *AlexaClient) GetSessionState() SessionState {
+	c.session.mutex.Lock()
+	loggedIn, loggedInAt := c.session.csrf != "", c.session.loggedInAt
+	c.session.mutex.Unlock()
+	return SessionState{
+		LoggedIn:       loggedIn,
+		LoggedInAt:     loggedInAt,
+		CookiesSavedAt: c.cookieHelper.CookiesSavedAt(),
+		LastSuccessAt:  unixNanoTime(c.lastSuccess.Load()),
+		LastFailureAt:  unixNanoTime(c.lastFailure.Load()),
+		CircuitBreaker: c.breaker.State(),
+	}
+}
+
+func unixNanoTime(unixNano int64) time.Time {
+	if unixNano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, unixNano)
+}
+
+// retry calls endpoint with backoff on errors accepted by retryable (not every call is idempotent) and re-login on auth errors
+func (c *AlexaClient) retry(ctx context.Context, endpoint string, retryable Retryable, retryBlock func(ctx context.Context, csrf string) error) error {
+	return c.call(ctx, endpoint, false, retryable, retryBlock)
+}
+
+// call made while loggingIn doesn't re-login on auth errors and bypasses the circuit breaker,
+// login runs within a call that already got through it (possibly as the half-open probe) and reports the outcome
+func (c *AlexaClient) call(ctx context.Context, endpoint string, loggingIn bool, retryable Retryable, retryBlock func(ctx context.Context, csrf string) error) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "Alexa."+endpoint)
+	defer func() {
+		observeCall(ctx, endpoint, err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+	if !loggingIn {
+		if err = c.breaker.Allow(); err != nil {
+			return err
 		}
 	}
+	var generation int
+	call := func() error {
+		var csrf string
+		csrf, generation = c.getSession()
+		callCtx, cancel := context.WithTimeout(ctx, c.callTimeout)
+		defer cancel()
+		defer observeCallDuration(endpoint, time.Now())
+		return retryBlock(callCtx, csrf)
+	}
+	err = c.retryPolicy.DoIf(ctx, retryable, call)
+	for retries := 0; !loggingIn && httpclient.IsAuthError(err) && retries < c.retriesMax; retries++ {
+		if err = c.logInOnce(ctx, true, generation); err == nil { // re-login (or wait for one in progress) and call again
+			err = c.retryPolicy.DoIf(ctx, retryable, call)
+		}
+	}
+	if ctx.Err() != nil { // caller went away or its deadline passed, says nothing about amazon
+		return err
+	}
 	if err == nil {
-		c.retries = 0
+		c.lastSuccess.Store(time.Now().UnixNano())
+	} else if !errors.Is(err, ErrCircuitOpen) {
+		c.lastFailure.Store(time.Now().UnixNano())
+	}
+	if loggingIn {
+		return err
+	}
+	if httpclient.IsTransientError(err) {
+		c.breaker.Failure()
+	} else if !errors.Is(err, ErrCircuitOpen) {
+		c.breaker.Success() // amazon responded, even if with an error
 	}
 	return err
 }
@@ -1,15 +1,21 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/httpclient"
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/model"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestAlexaClientLogIn(t *testing.T) {
@@ -30,9 +36,9 @@ func TestAlexaClientLogIn(t *testing.T) {
 		mockCookieHelper.On("ExtractCSRF", cookieJar, expectedDomain).Return("csrfToken")
 		mockHttpClient.On("RestGET", expectedDevicesCallURL, expectedHeaders("csrfToken"), &model.DevicesResponse{}).Return(noError())
 
-		err := alexaClient.LogIn(false)
+		err := alexaClient.LogIn(context.Background(), false)
 		require.NoError(t, err)
-		_, err = alexaClient.GetDevices() // verify csrf token is set after login
+		_, err = alexaClient.GetDevices(context.Background()) // verify csrf token is set after login
 		require.NoError(t, err)
 
 		mockCookieHelper.AssertExpectations(t)
@@ -55,9 +61,9 @@ func TestAlexaClientLogIn(t *testing.T) {
 		mockCookieHelper.On("ExtractCSRF", cookieJar, expectedDomain).Return("csrfToken")
 		mockHttpClient.On("RestGET", expectedDevicesCallURL, expectedHeaders("csrfToken"), &model.DevicesResponse{}).Return(noError())
 
-		err := alexaClient.LogIn(true)
+		err := alexaClient.LogIn(context.Background(), true)
 		require.NoError(t, err)
-		_, err = alexaClient.GetDevices() // verify csrf token is set after login
+		_, err = alexaClient.GetDevices(context.Background()) // verify csrf token is set after login
 		require.NoError(t, err)
 
 		mockCookieHelper.AssertExpectations(t)
@@ -74,7 +80,7 @@ func TestAlexaClientLogIn(t *testing.T) {
 		mockCookieHelper.On("LoadCookies", cookieJar, expectedDomain).Return(noError())
 		mockCookieHelper.On("ExtractCSRF", cookieJar, expectedDomain).Return("csrfToken")
 
-		err := alexaClient.LogIn(false)
+		err := alexaClient.LogIn(context.Background(), false)
 
 		require.NoError(t, err)
 		mockCookieHelper.AssertExpectations(t)
@@ -86,7 +92,7 @@ func TestAlexaClientLogIn(t *testing.T) {
 		mockCookieHelper.On("CookiesSaved").Return(false)
 		mockHttpClient.On("SimpleGET", expectedGetFormURL(), expectedGetFormHeaders()).Return(nil, expectedError)
 
-		err := alexaClient.LogIn(false)
+		err := alexaClient.LogIn(context.Background(), false)
 
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.LogIn getting form failed: getting login form failed: mock error")
@@ -100,7 +106,7 @@ func TestAlexaClientLogIn(t *testing.T) {
 		mockCookieHelper.On("CookiesSaved").Return(false)
 		mockHttpClient.On("SimpleGET", expectedGetFormURL(), expectedGetFormHeaders()).Return(expectedFormGetResponse, noError())
 
-		err := alexaClient.LogIn(false)
+		err := alexaClient.LogIn(context.Background(), false)
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.LogIn getting form failed: getting login form returned wrong status: 401")
 		mockCookieHelper.AssertExpectations(t)
@@ -124,7 +130,7 @@ func TestAlexaClientLogIn(t *testing.T) {
 		mockCookieHelper.On("ExtractLoginFormInputs", expectedStep0FormHtml).Return(expectedStep1FormData)
 		mockHttpClient.On("SimplePOST", expectedStep1FormPostURL, expectedPostFormHeaders(), expectedStep1FormData).Return(nil, expectedStep1Error)
 
-		err := alexaClient.LogIn(false)
+		err := alexaClient.LogIn(context.Background(), false)
 
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.LogIn submit step 1 login form failed: submit failed: mock error")
@@ -161,7 +167,7 @@ func TestAlexaClientLogIn(t *testing.T) {
 		mockCookieHelper.On("ExtractLoginFormInputs", expectedStep1FormHtml).Return(expectedStep2FormData)
 		mockHttpClient.On("SimplePOST", expectedStep2FormPostURL, expectedPostFormHeaders(), expectedStep2FormData).Return(expectedStep2FormPostResponse, noError())
 
-		err := alexaClient.LogIn(false)
+		err := alexaClient.LogIn(context.Background(), false)
 
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.LogIn submit step 2 login form failed: submit failed, wrong status: 200, successful login submit should be a redirect")
@@ -198,7 +204,7 @@ func TestAlexaClientLogIn(t *testing.T) {
 		mockCookieHelper.On("ExtractLoginFormInputs", expectedStep1FormHtml).Return(expectedStep2FormData)
 		mockHttpClient.On("SimplePOST", expectedStep2FormPostURL, expectedPostFormHeaders(), expectedStep2FormData).Return(expectedStep2FormPostResponse, noError())
 
-		err := alexaClient.LogIn(false)
+		err := alexaClient.LogIn(context.Background(), false)
 
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.LogIn submit step 2 login form failed: submit failed, try logining in from an app on the same network: wrong/redirect/url")
@@ -216,7 +222,7 @@ func TestAlexaClientLogIn(t *testing.T) {
 		loginStepsSuccess(mockHttpClient, mockCookieHelper)
 		mockHttpClient.On("RestGET", expectedDevicesCallURL, expectedHeaders(""), &model.DevicesResponse{}).Return(expectedError)
 
-		err := alexaClient.LogIn(false)
+		err := alexaClient.LogIn(context.Background(), false)
 
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.LogIn getting devices failed: Alexa.GetDevices failed: mock error")
@@ -238,7 +244,7 @@ func TestAlexaClientLogIn(t *testing.T) {
 		mockHttpClient.On("GetCookieJar").Return(cookieJar)
 		mockCookieHelper.On("SaveCookies", cookieJar, expectedDomain).Return(expectedError)
 
-		err := alexaClient.LogIn(false)
+		err := alexaClient.LogIn(context.Background(), false)
 
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.LogIn saving cookies failed: mock error")
@@ -261,7 +267,7 @@ func TestAlexaClientLogIn(t *testing.T) {
 		mockCookieHelper.On("SaveCookies", cookieJar, expectedDomain).Return(noError())
 		mockCookieHelper.On("ExtractCSRF", cookieJar, expectedDomain).Return("")
 
-		err := alexaClient.LogIn(false)
+		err := alexaClient.LogIn(context.Background(), false)
 
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.LogIn empty csrf cookie")
@@ -280,7 +286,7 @@ func TestAlexaClientAPIs(t *testing.T) {
 			On("RestPOST", expectedURL, expectedHeaders(""), expectedRequest, nil).
 			Return(noError())
 
-		err := alexaClient.PostSequenceCmd(expectedRequest)
+		err := alexaClient.PostSequenceCmd(context.Background(), expectedRequest)
 
 		require.NoError(t, err)
 		mockHttpClient.AssertExpectations(t)
@@ -293,7 +299,7 @@ func TestAlexaClientAPIs(t *testing.T) {
 		expectedError := errors.New("mock error")
 		mockHttpClient.On("RestPOST", expectedURL, expectedHeaders(""), expectedRequest, nil).Return(expectedError)
 
-		err := alexaClient.PostSequenceCmd(expectedRequest)
+		err := alexaClient.PostSequenceCmd(context.Background(), expectedRequest)
 
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.PostSequenceCmd failed: mock error")
@@ -312,7 +318,7 @@ func TestAlexaClientAPIs(t *testing.T) {
 			}).
 			Return(noError())
 
-		actualResponse, err := alexaClient.GetDevices()
+		actualResponse, err := alexaClient.GetDevices(context.Background())
 
 		require.NoError(t, err)
 		assert.Equal(t, expectedResponse, actualResponse)
@@ -325,7 +331,7 @@ func TestAlexaClientAPIs(t *testing.T) {
 		expectedError := errors.New("mock error")
 		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(expectedError)
 
-		_, err := alexaClient.GetDevices()
+		_, err := alexaClient.GetDevices(context.Background())
 
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.GetDevices failed: mock error")
@@ -363,7 +369,7 @@ func TestAlexaClientAPIs(t *testing.T) {
 			}).
 			Return(noError())
 
-		actualResponse, err := alexaClient.GetDevices()
+		actualResponse, err := alexaClient.GetDevices(context.Background())
 
 		require.NoError(t, err)
 		assert.Equal(t, expectedResponse, actualResponse)
@@ -384,7 +390,7 @@ func TestAlexaClientAPIs(t *testing.T) {
 		mockHttpClient.On("ResetCookieJar").Return()
 		mockHttpClient.On("SimpleGET", expectedGetFormURL(), expectedGetFormHeaders()).Return(nil, expectedError2)
 
-		_, err := alexaClient.GetDevices()
+		_, err := alexaClient.GetDevices(context.Background())
 
 		mockHttpClient.AssertExpectations(t)
 		mockCookieHelper.AssertExpectations(t)
@@ -405,7 +411,7 @@ func TestAlexaClientAPIs(t *testing.T) {
 			}).
 			Return(noError())
 
-		actualResponse, err := alexaClient.GetVolume()
+		actualResponse, err := alexaClient.GetVolume(context.Background())
 
 		require.NoError(t, err)
 		assert.Equal(t, expectedResponse, actualResponse)
@@ -418,7 +424,7 @@ func TestAlexaClientAPIs(t *testing.T) {
 		expectedError := errors.New("mock error")
 		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.VolumeResponse{}).Return(expectedError)
 
-		_, err := alexaClient.GetVolume()
+		_, err := alexaClient.GetVolume(context.Background())
 
 		require.Error(t, err)
 		assert.ErrorContains(t, err, "Alexa.GetVolume failed: mock error")
@@ -427,6 +433,169 @@ func TestAlexaClientAPIs(t *testing.T) {
 
 }
 
+func TestGetPlayerState(t *testing.T) {
+
+	t.Run("GetPlayerState, recorded response", func(t *testing.T) {
+		mockHttpClient, _, alexaClient := initClient()
+		expectedURL := "https://alexa.example.com/api/np/player?deviceSerialNumber=sn1&deviceType=dt1&screenWidth=1440"
+		recordedResponse, err := os.ReadFile("testdata/np_player.json")
+		require.NoError(t, err)
+		mockHttpClient.
+			On("RestGET", expectedURL, expectedHeaders(""), &model.PlayerStateResponse{}).
+			Run(func(args mock.Arguments) {
+				require.NoError(t, json.Unmarshal(recordedResponse, args.Get(2)))
+			}).
+			Return(noError())
+
+		state, err := alexaClient.GetPlayerState(context.Background(), model.DeviceTarget{DeviceType: "dt1", DeviceSerialNumber: "sn1", CustomerID: "cid1"})
+
+		require.NoError(t, err)
+		info := state.PlayerInfo
+		assert.Equal(t, "PLAYING", *info.State)
+		assert.Equal(t, "Id1", *info.MediaID)
+		assert.Equal(t, "Name1", *info.InfoText.Title)
+		assert.Equal(t, "Artist1", *info.InfoText.SubText1)
+		assert.Equal(t, "navi stream", *info.Provider.ProviderName)
+		assert.Equal(t, 42, *info.Progress.MediaProgress)
+		assert.Equal(t, 321, *info.Progress.MediaLength)
+		assert.Equal(t, 35, *info.Volume.Volume)
+		assert.False(t, info.Volume.Muted)
+		mockHttpClient.AssertExpectations(t)
+	})
+
+	t.Run("GetPlayerState, error", func(t *testing.T) {
+		mockHttpClient, _, alexaClient := initClient()
+		expectedURL := "https://alexa.example.com/api/np/player?deviceSerialNumber=sn1&deviceType=dt1&screenWidth=1440"
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.PlayerStateResponse{}).Return(errors.New("mock error"))
+
+		_, err := alexaClient.GetPlayerState(context.Background(), model.DeviceTarget{DeviceType: "dt1", DeviceSerialNumber: "sn1"})
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "Alexa.GetPlayerState failed: mock error")
+		mockHttpClient.AssertExpectations(t)
+	})
+}
+
+func TestAlexaClientRetries(t *testing.T) {
+	expectedURL := "https://alexa.example.com/api/devices-v2/device?cached=false"
+	unavailable := httpclient.NewHttpErrorWithStatus("mock unavailable", "503 Service Unavailable", 503)
+
+	t.Run("transient error is retried with backoff", func(t *testing.T) {
+		mockHttpClient, _, alexaClient := initClient()
+		policy, sleeps := testRetryPolicy(3)
+		alexaClient.(*AlexaClient).retryPolicy = policy
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(unavailable).Once()
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(noError()).Once()
+
+		_, err := alexaClient.GetDevices(context.Background())
+
+		require.NoError(t, err)
+		assert.Len(t, *sleeps, 1)
+		assert.Equal(t, CircuitClosed, alexaClient.GetCircuitBreakerState().State)
+		mockHttpClient.AssertExpectations(t)
+	})
+
+	t.Run("PostSequenceCmd, 5xx or timeout is not retried as command may have been executed", func(t *testing.T) {
+		timeout := httpclient.NewHttpError("mock timeout", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded})
+		for _, callErr := range []error{unavailable, timeout} {
+			mockHttpClient, _, alexaClient := initClient()
+			policy, sleeps := testRetryPolicy(3)
+			alexaClient.(*AlexaClient).retryPolicy = policy
+			command := model.AlexaCmd{BehaviorID: "mockCommand"}
+			mockHttpClient.On("RestPOST", "https://alexa.example.com/api/behaviors/preview", expectedHeaders(""), command, nil).Return(callErr)
+
+			err := alexaClient.PostSequenceCmd(context.Background(), command)
+
+			require.Error(t, err)
+			assert.Empty(t, *sleeps)
+			mockHttpClient.AssertNumberOfCalls(t, "RestPOST", 1)
+		}
+	})
+
+	t.Run("PostSequenceCmd, throttling or connect error is retried as command was not executed", func(t *testing.T) {
+		throttled := httpclient.NewHttpErrorWithStatus("mock throttled", "429 Too Many Requests", 429)
+		refused := httpclient.NewHttpError("mock refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+		for _, callErr := range []error{throttled, refused} {
+			mockHttpClient, _, alexaClient := initClient()
+			policy, sleeps := testRetryPolicy(3)
+			alexaClient.(*AlexaClient).retryPolicy = policy
+			command := model.AlexaCmd{BehaviorID: "mockCommand"}
+			mockHttpClient.On("RestPOST", "https://alexa.example.com/api/behaviors/preview", expectedHeaders(""), command, nil).Return(callErr).Once()
+			mockHttpClient.On("RestPOST", "https://alexa.example.com/api/behaviors/preview", expectedHeaders(""), command, nil).Return(noError()).Once()
+
+			err := alexaClient.PostSequenceCmd(context.Background(), command)
+
+			require.NoError(t, err)
+			assert.Len(t, *sleeps, 1)
+			mockHttpClient.AssertExpectations(t)
+		}
+	})
+
+	t.Run("circuit breaker opens after consecutive transient failures and fails fast", func(t *testing.T) {
+		mockHttpClient, _, alexaClient := initClient()
+		policy, _ := testRetryPolicy(2)
+		alexaClient.(*AlexaClient).retryPolicy = policy
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(unavailable)
+
+		for i := 0; i < 5; i++ {
+			_, err := alexaClient.GetDevices(context.Background())
+			assert.ErrorContains(t, err, "Alexa.GetDevices failed: mock unavailable")
+		}
+		_, err := alexaClient.GetDevices(context.Background())
+
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+		assert.Equal(t, CircuitOpen, alexaClient.GetCircuitBreakerState().State)
+		mockHttpClient.AssertNumberOfCalls(t, "RestGET", 10)
+	})
+
+	t.Run("non transient errors don't open circuit breaker", func(t *testing.T) {
+		mockHttpClient, _, alexaClient := initClient()
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(errors.New("mock error"))
+
+		for i := 0; i < 10; i++ {
+			_, _ = alexaClient.GetDevices(context.Background())
+		}
+
+		assert.Equal(t, CircuitClosed, alexaClient.GetCircuitBreakerState().State)
+		mockHttpClient.AssertNumberOfCalls(t, "RestGET", 10)
+	})
+}
+
+func TestAlexaClientContext(t *testing.T) {
+	expectedURL := "https://alexa.example.com/api/devices-v2/device?cached=false"
+
+	t.Run("each call gets a deadline and keeps caller context values", func(t *testing.T) {
+		mockHttpClient, _, alexaClient := initClient()
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(noError())
+		type ctxKey string
+		ctx := context.WithValue(context.Background(), ctxKey("RequestID"), "rq1")
+
+		_, err := alexaClient.GetDevices(ctx)
+
+		require.NoError(t, err)
+		deadline, hasDeadline := mockHttpClient.lastCtx.Deadline()
+		assert.True(t, hasDeadline)
+		assert.WithinDuration(t, time.Now().Add(5*time.Second), deadline, time.Second)
+		assert.Equal(t, "rq1", mockHttpClient.lastCtx.Value(ctxKey("RequestID")))
+	})
+
+	t.Run("cancelled caller stops retries and does not trip circuit breaker", func(t *testing.T) {
+		mockHttpClient, _, alexaClient := initClient()
+		ctx, cancel := context.WithCancel(context.Background())
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).
+			Run(func(args mock.Arguments) { cancel() }).
+			Return(httpclient.NewHttpErrorWithStatus("mock unavailable", "503 Service Unavailable", 503))
+
+		for i := 0; i < 10; i++ {
+			_, err := alexaClient.GetDevices(ctx)
+			assert.ErrorContains(t, err, "mock unavailable")
+		}
+
+		mockHttpClient.AssertNumberOfCalls(t, "RestGET", 10) // no retries
+		assert.Equal(t, CircuitClosed, alexaClient.GetCircuitBreakerState().State)
+	})
+}
+
 func initClient() (mockHttpClient *MockIHttpClient, mockCookieHelper *MockICookieHelper, alexaClient IAlexaClient) {
 	mockHttpClient = new(MockIHttpClient)
 	mockCookieHelper = new(MockICookieHelper)
@@ -539,6 +708,7 @@ func (m *MockCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
 
 type MockIHttpClient struct {
 	mock.Mock
+	lastCtx context.Context
 }
 
 func (m *MockIHttpClient) GetCookieJar() (jar http.CookieJar) {
@@ -550,7 +720,7 @@ func (m *MockIHttpClient) ResetCookieJar() {
 	m.Called()
 }
 
-func (m *MockIHttpClient) SimpleGET(url string, headers *httpclient.Headers) (*httpclient.Response, error) {
+func (m *MockIHttpClient) SimpleGET(_ context.Context, url string, headers *httpclient.Headers) (*httpclient.Response, error) {
 	args := m.Called(url, headers)
 	ret1 := args.Get(0)
 	ret2 := args.Error(1)
@@ -560,7 +730,7 @@ func (m *MockIHttpClient) SimpleGET(url string, headers *httpclient.Headers) (*h
 	return ret1.(*httpclient.Response), ret2
 }
 
-func (m *MockIHttpClient) SimplePOST(url string, headers *httpclient.Headers, formData *url.Values) (*httpclient.Response, error) {
+func (m *MockIHttpClient) SimplePOST(_ context.Context, url string, headers *httpclient.Headers, formData *url.Values) (*httpclient.Response, error) {
 	args := m.Called(url, headers, formData)
 	ret1 := args.Get(0)
 	ret2 := args.Error(1)
@@ -570,12 +740,13 @@ func (m *MockIHttpClient) SimplePOST(url string, headers *httpclient.Headers, fo
 	return ret1.(*httpclient.Response), ret2
 }
 
-func (m *MockIHttpClient) RestGET(url string, headers *httpclient.Headers, response interface{}) error {
+func (m *MockIHttpClient) RestGET(ctx context.Context, url string, headers *httpclient.Headers, response interface{}) error {
+	m.lastCtx = ctx
 	args := m.Called(url, headers, response)
 	return args.Error(0)
 }
 
-func (m *MockIHttpClient) RestPOST(url string, headers *httpclient.Headers, request interface{}, response interface{}) error {
+func (m *MockIHttpClient) RestPOST(_ context.Context, url string, headers *httpclient.Headers, request interface{}, response interface{}) error {
 	args := m.Called(url, headers, request, response)
 	return args.Error(0)
 }
@@ -589,6 +760,11 @@ func (m *MockICookieHelper) CookiesSaved() bool {
 	return args.Bool(0)
 }
 
+func (m *MockICookieHelper) CookiesSavedAt() time.Time {
+	args := m.Called()
+	return args.Get(0).(time.Time)
+}
+
 func (m *MockICookieHelper) SaveCookies(jar http.CookieJar, baseDomain string) error {
 	args := m.Called(jar, baseDomain)
 	return args.Error(0)
@@ -613,3 +789,44 @@ func (m *MockICookieHelper) ExtractLoginFormInputs(formHtml string) *url.Values
 	args := m.Called(formHtml)
 	return args.Get(0).(*url.Values)
 }
+
+func TestAlexaClientSessionState(t *testing.T) {
+	expectedURL := "https://alexa.example.com/api/devices-v2/device?cached=false"
+
+	t.Run("not logged in", func(t *testing.T) {
+		_, mockCookieHelper, alexaClient := initClient()
+		mockCookieHelper.On("CookiesSavedAt").Return(time.Time{})
+
+		state := alexaClient.GetSessionState()
+
+		assert.False(t, state.LoggedIn)
+		assert.True(t, state.LoggedInAt.IsZero())
+		assert.True(t, state.LastSuccessAt.IsZero())
+		assert.True(t, state.LastFailureAt.IsZero())
+		assert.Equal(t, CircuitClosed, state.CircuitBreaker.State)
+	})
+
+	t.Run("logged in with saved cookies, calls tracked", func(t *testing.T) {
+		mockHttpClient, mockCookieHelper, alexaClient := initClient()
+		cookieJar := new(MockCookieJar)
+		savedAt := time.Now().Add(-time.Hour)
+		mockCookieHelper.On("CookiesSaved").Return(true)
+		mockCookieHelper.On("CookiesSavedAt").Return(savedAt)
+		mockHttpClient.On("GetCookieJar").Return(cookieJar)
+		mockCookieHelper.On("LoadCookies", cookieJar, "example.com").Return(noError())
+		mockCookieHelper.On("ExtractCSRF", cookieJar, "example.com").Return("csrfToken")
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders("csrfToken"), &model.DevicesResponse{}).Return(noError()).Once()
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders("csrfToken"), &model.DevicesResponse{}).Return(errors.New("mock error")).Once()
+
+		require.NoError(t, alexaClient.LogIn(context.Background(), false))
+		_, _ = alexaClient.GetDevices(context.Background())
+		_, _ = alexaClient.GetDevices(context.Background())
+		state := alexaClient.GetSessionState()
+
+		assert.True(t, state.LoggedIn)
+		assert.WithinDuration(t, time.Now(), state.LoggedInAt, time.Second)
+		assert.Equal(t, savedAt, state.CookiesSavedAt)
+		assert.WithinDuration(t, time.Now(), state.LastSuccessAt, time.Second)
+		assert.False(t, state.LastFailureAt.Before(state.LastSuccessAt))
+	})
+}
@@ -7,10 +7,12 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type ICookieHelper interface {
 	CookiesSaved() (cookiesExist bool)
+	CookiesSavedAt() (savedAt time.Time) // zero if cookies are not saved
 	SaveCookies(jar http.CookieJar, baseDomain string) (err error)
 	LoadCookies(jar http.CookieJar, baseDomain string) (err error)
 	ExtractCSRF(jar http.CookieJar, baseDomain string) (csrf string)
@@ -39,6 +41,14 @@ func (c *CookieHelper) CookiesSaved() (cookiesExist bool) {
 	return !info.IsDir()
 }
 
+func (c *CookieHelper) CookiesSavedAt() (savedAt time.Time) {
+	info, err := os.Stat(c.filePath)
+	if err != nil || info.IsDir() {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
 func (c *CookieHelper) SaveCookies(jar http.CookieJar, baseDomain string) (err error) {
 	cookieFile, err := os.Create(c.filePath)
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestCookieHelper(t *testing.T) {
@@ -28,6 +29,17 @@ func TestCookieHelper(t *testing.T) {
 		})
 	})
 
+	t.Run("CookiesSavedAt", func(t *testing.T) {
+		assert.True(t, NewCookieHelper("does not exist").CookiesSavedAt().IsZero())
+
+		tempFile := createTempFile(t)
+		defer os.Remove(tempFile.Name())
+		savedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+		require.NoError(t, os.Chtimes(tempFile.Name(), savedAt, savedAt))
+
+		assert.Equal(t, savedAt, NewCookieHelper(tempFile.Name()).CookiesSavedAt())
+	})
+
 	t.Run("Save and Load cookies", func(t *testing.T) {
 		tempFile := createTempFile(t)
 		defer os.Remove(tempFile.Name())
@@ -1,10 +1,18 @@
 package httpclient
 
-import "github.com/pkg/errors"
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
 
 type HttpError struct {
 	Status     string
 	StatusCode int
+	RetryAfter time.Duration // from Retry-After header of 429/503 responses, 0 if not set
 	message    string
 	cause      error
 }
@@ -24,6 +32,15 @@ func NewHttpErrorWithStatus(message string, status string, statusCode int) error
 	})
 }
 
+func NewHttpErrorWithRetryAfter(message string, status string, statusCode int, retryAfter time.Duration) error {
+	return errors.WithStack(&HttpError{
+		message:    message,
+		Status:     status,
+		StatusCode: statusCode,
+		RetryAfter: retryAfter,
+	})
+}
+
 func (he *HttpError) Error() string {
 	if he.cause != nil {
 		return he.message + ": " + he.cause.Error()
@@ -38,3 +55,54 @@ func IsAuthError(err error) bool {
 	var httpError *HttpError
 	return errors.As(err, &httpError) && httpError.StatusCode == 401
 }
+
+// IsTransientError is true for errors that may go away on retry: throttling, server side and network errors,
+// cancelled calls (caller went away) are not
+func IsTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var httpError *HttpError
+	if errors.As(err, &httpError) && (httpError.StatusCode == 429 || httpError.StatusCode >= 500) {
+		return true
+	}
+	var netError net.Error
+	return errors.As(err, &netError)
+}
+
+func IsThrottledError(err error) bool {
+	var httpError *HttpError
+	return errors.As(err, &httpError) && httpError.StatusCode == 429
+}
+
+// IsConnectError is true if connecting failed (name resolution, dial) before the request was sent,
+// so Amazon could not have acted on it
+func IsConnectError(err error) bool {
+	var opError *net.OpError
+	if errors.As(err, &opError) && opError.Op == "dial" {
+		return true
+	}
+	var dnsError *net.DNSError
+	return errors.As(err, &dnsError)
+}
+
+func GetRetryAfter(err error) time.Duration {
+	var httpError *HttpError
+	if errors.As(err, &httpError) {
+		return httpError.RetryAfter
+	}
+	return 0
+}
+
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
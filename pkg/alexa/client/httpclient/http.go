@@ -2,12 +2,19 @@ package httpclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -25,22 +32,24 @@ type Response struct {
 }
 
 type IHttpClient interface {
-	RestGET(url string, rqHeaders *Headers, rs any) (err error)          // rs by ref (no generic in types)
-	RestPOST(url string, rqHeaders *Headers, rq any, rs any) (err error) // rs by ref (no generic in types)
-	SimpleGET(url string, rqHeaders *Headers) (rs *Response, err error)
-	SimplePOST(url string, rqHeaders *Headers, formData *url.Values) (rs *Response, err error)
+	RestGET(ctx context.Context, url string, rqHeaders *Headers, rs any) (err error)          // rs by ref (no generic in types)
+	RestPOST(ctx context.Context, url string, rqHeaders *Headers, rq any, rs any) (err error) // rs by ref (no generic in types)
+	SimpleGET(ctx context.Context, url string, rqHeaders *Headers) (rs *Response, err error)
+	SimplePOST(ctx context.Context, url string, rqHeaders *Headers, formData *url.Values) (rs *Response, err error)
 	GetCookieJar() (jar http.CookieJar)
 	ResetCookieJar()
 }
 
 type HttpClient struct {
 	*http.Client
+	jar            *resettableJar
 	requestLogger  func(rq *http.Request, rqBody []byte)
 	responseLogger func(rq *http.Request, rqBody []byte, rs *http.Response, rsBody []byte, err error, start time.Time)
 }
 
 func NewHttpClient() *HttpClient {
 	client := &HttpClient{
+		jar:            &resettableJar{},
 		requestLogger:  func(rq *http.Request, rqBody []byte) {},
 		responseLogger: func(rq *http.Request, rqBody []byte, rs *http.Response, rsBody []byte, err error, start time.Time) {},
 		Client: &http.Client{
@@ -51,12 +60,13 @@ func NewHttpClient() *HttpClient {
 		},
 	}
 	client.ResetCookieJar()
+	client.Client.Jar = client.jar
 	return client
 }
 
+// ResetCookieJar clears cookies, safe to call while other calls are in flight
 func (httpClient *HttpClient) ResetCookieJar() {
-	jar, _ := cookiejar.New(nil)
-	httpClient.Client.Jar = jar
+	httpClient.jar.reset()
 }
 
 func (httpClient *HttpClient) WithTimeout(duration time.Duration) *HttpClient {
@@ -74,8 +84,8 @@ func (httpClient *HttpClient) WithResponseLogger(f func(rq *http.Request, rqBody
 	return httpClient
 }
 
-func (httpClient *HttpClient) RestGET(url string, rqHeaders *Headers, rs any) (err error) {
-	rsBytes, _, err := httpClient.runHttpRequest("GET", url, rqHeaders, nil)
+func (httpClient *HttpClient) RestGET(ctx context.Context, url string, rqHeaders *Headers, rs any) (err error) {
+	rsBytes, _, err := httpClient.runHttpRequest(ctx, "GET", url, rqHeaders, nil)
 	if err != nil {
 		return err
 	}
@@ -88,12 +98,12 @@ func (httpClient *HttpClient) RestGET(url string, rqHeaders *Headers, rs any) (e
 	return nil
 }
 
-func (httpClient *HttpClient) RestPOST(url string, rqHeaders *Headers, rq any, rs any) (err error) {
+func (httpClient *HttpClient) RestPOST(ctx context.Context, url string, rqHeaders *Headers, rq any, rs any) (err error) {
 	rqBytes, err := json.Marshal(rq)
 	if err != nil {
 		return NewHttpError("error marshalling rq", err)
 	}
-	rsBytes, _, err := httpClient.runHttpRequest("POST", url, rqHeaders, rqBytes)
+	rsBytes, _, err := httpClient.runHttpRequest(ctx, "POST", url, rqHeaders, rqBytes)
 	if err != nil {
 		return err
 	}
@@ -106,8 +116,8 @@ func (httpClient *HttpClient) RestPOST(url string, rqHeaders *Headers, rq any, r
 	return nil
 }
 
-func (httpClient *HttpClient) SimpleGET(url string, rqHeaders *Headers) (rs *Response, err error) {
-	responseBytes, httpResponse, err := httpClient.runHttpRequest("GET", url, rqHeaders, nil)
+func (httpClient *HttpClient) SimpleGET(ctx context.Context, url string, rqHeaders *Headers) (rs *Response, err error) {
+	responseBytes, httpResponse, err := httpClient.runHttpRequest(ctx, "GET", url, rqHeaders, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -118,8 +128,8 @@ func (httpClient *HttpClient) SimpleGET(url string, rqHeaders *Headers) (rs *Res
 	}, nil
 }
 
-func (httpClient *HttpClient) SimplePOST(url string, rqHeaders *Headers, formData *url.Values) (rs *Response, err error) {
-	responseBytes, httpResponse, err := httpClient.runHttpRequest("POST", url, rqHeaders, []byte(formData.Encode()))
+func (httpClient *HttpClient) SimplePOST(ctx context.Context, url string, rqHeaders *Headers, formData *url.Values) (rs *Response, err error) {
+	responseBytes, httpResponse, err := httpClient.runHttpRequest(ctx, "POST", url, rqHeaders, []byte(formData.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -135,17 +145,20 @@ func (httpClient *HttpClient) GetCookieJar() http.CookieJar {
 }
 
 func (httpClient *HttpClient) runHttpRequest(
+	ctx context.Context,
 	rqMethod string,
 	rqURL string,
 	rqHeaders *Headers,
 	rqBody []byte,
 ) (rsBody []byte, rs *http.Response, err error) {
 	startTime := time.Now()
+	ctx, span := startClientSpan(ctx, rqMethod, rqURL)
+	defer func() { endClientSpan(span, rs, err) }()
 	var rq *http.Request
 	if rqBody == nil {
-		rq, err = http.NewRequest(rqMethod, rqURL, nil)
+		rq, err = http.NewRequestWithContext(ctx, rqMethod, rqURL, nil)
 	} else {
-		rq, err = http.NewRequest(rqMethod, rqURL, bytes.NewBuffer(rqBody))
+		rq, err = http.NewRequestWithContext(ctx, rqMethod, rqURL, bytes.NewBuffer(rqBody))
 	}
 	if err != nil {
 		return nil, nil, NewHttpError("error creating http request", err)
@@ -164,7 +177,8 @@ func (httpClient *HttpClient) runHttpRequest(
 		return nil, nil, err
 	}
 	if rs.StatusCode >= 400 {
-		err = NewHttpErrorWithStatus("error status code "+strconv.Itoa(rs.StatusCode), rs.Status, rs.StatusCode)
+		err = NewHttpErrorWithRetryAfter("error status code "+strconv.Itoa(rs.StatusCode), rs.Status, rs.StatusCode,
+			parseRetryAfter(rs.Header.Get("Retry-After"), time.Now()))
 		httpClient.responseLogger(rq, rqBody, rs, nil, nil, startTime) // err not propagated
 		return nil, rs, err
 	}
@@ -179,6 +193,53 @@ func (httpClient *HttpClient) runHttpRequest(
 	return rsBody, rs, nil
 }
 
+// startClientSpan names span by host only, urls have ids in path and query
+func startClientSpan(ctx context.Context, method string, rqURL string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(method)}
+	name := method
+	if parsedURL, err := url.Parse(rqURL); err == nil {
+		name = method + " " + parsedURL.Host
+		attributes = append(attributes, semconv.ServerAddress(parsedURL.Host), semconv.URLPath(parsedURL.Path))
+	}
+	return tracing.Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+func endClientSpan(span trace.Span, rs *http.Response, err error) {
+	if rs != nil {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rs.StatusCode))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// resettableJar swaps underlying jar on reset instead of replacing http.Client.Jar used by concurrent calls
+type resettableJar struct {
+	jar   http.CookieJar
+	mutex sync.RWMutex
+}
+
+func (j *resettableJar) reset() {
+	jar, _ := cookiejar.New(nil)
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.jar = jar
+}
+
+func (j *resettableJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	j.jar.SetCookies(u, cookies)
+}
+
+func (j *resettableJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.jar.Cookies(u)
+}
+
 func nopClose(closer io.Closer) {
 	_ = closer.Close()
 }
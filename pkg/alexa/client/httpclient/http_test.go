@@ -1,12 +1,14 @@
 package httpclient
 
 import (
+	"context"
 	"fmt"
 	"github.com/h2non/gock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
@@ -33,7 +35,7 @@ func TestSimpleGET(t *testing.T) {
 			BodyString("simple body1")
 		defer gock.Off()
 
-		rs, err := client.SimpleGET("http://dummy/url?param=test1", &Headers{
+		rs, err := client.SimpleGET(context.Background(), "http://dummy/url?param=test1", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		})
 
@@ -52,7 +54,7 @@ func TestSimpleGET(t *testing.T) {
 			AddHeader("Location", "https://redirect?with=param")
 		defer gock.Off()
 
-		rs, err := client.SimpleGET("http://dummy/url?param=test2", &Headers{
+		rs, err := client.SimpleGET(context.Background(), "http://dummy/url?param=test2", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		})
 
@@ -69,7 +71,7 @@ func TestSimpleGET(t *testing.T) {
 			ReplyError(errors.New("mock error"))
 		defer gock.Off()
 
-		rs, err := client.SimpleGET("http://dummy/url?param=test3", nil)
+		rs, err := client.SimpleGET(context.Background(), "http://dummy/url?param=test3", nil)
 
 		assert.Nil(t, rs)
 		assert.Error(t, err)
@@ -94,7 +96,7 @@ func TestSimplePOST(t *testing.T) {
 		formData := &url.Values{}
 		formData.Add("key 1", "value 1")
 		formData.Add("key 2", "value 2")
-		rs, err := client.SimplePOST("http://dummy/url?param=test1", headers, formData)
+		rs, err := client.SimplePOST(context.Background(), "http://dummy/url?param=test1", headers, formData)
 
 		require.NoError(t, err)
 		assert.NotNil(t, rs)
@@ -113,7 +115,7 @@ func TestSimplePOST(t *testing.T) {
 		formData := &url.Values{}
 		formData.Add("key 1", "value 1")
 		formData.Add("key 2", "value 2")
-		rs, err := client.SimplePOST("http://dummy/url?param=test2", headers, formData)
+		rs, err := client.SimplePOST(context.Background(), "http://dummy/url?param=test2", headers, formData)
 
 		assert.Nil(t, rs)
 		assert.Error(t, err)
@@ -133,7 +135,7 @@ func TestRestGET(t *testing.T) {
 		defer gock.Off()
 
 		var rs TestRS
-		err := client.RestGET("http://dummy/url?param=test1", &Headers{
+		err := client.RestGET(context.Background(), "http://dummy/url?param=test1", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, &rs)
 
@@ -153,7 +155,7 @@ func TestRestGET(t *testing.T) {
 		defer gock.Off()
 
 		var rs TestRS
-		err := client.RestGET("http://dummy/url?param=test2", &Headers{
+		err := client.RestGET(context.Background(), "http://dummy/url?param=test2", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, &rs)
 
@@ -168,7 +170,7 @@ func TestRestGET(t *testing.T) {
 			Reply(200)
 		defer gock.Off()
 
-		err := client.RestGET("http://dummy/url?param=test3", &Headers{
+		err := client.RestGET(context.Background(), "http://dummy/url?param=test3", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, nil)
 
@@ -183,7 +185,7 @@ func TestRestGET(t *testing.T) {
 		defer gock.Off()
 
 		var rs TestRS
-		err := client.RestGET("http://dummy/url?param=test4", &Headers{
+		err := client.RestGET(context.Background(), "http://dummy/url?param=test4", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, &rs)
 
@@ -199,6 +201,75 @@ func TestRestGET(t *testing.T) {
 	})
 }
 
+func TestRetryableErrors(t *testing.T) {
+	client := NewHttpClient()
+
+	t.Run("429 Too Many Requests with Retry-After is a transient error", func(t *testing.T) {
+		gock.New("http://dummy").Get("/url").Reply(429).SetHeader("Retry-After", "7")
+		defer gock.Off()
+
+		err := client.RestGET(context.Background(), "http://dummy/url", nil, nil)
+
+		assert.True(t, IsTransientError(err))
+		assert.False(t, IsAuthError(err))
+		assert.Equal(t, 7*time.Second, GetRetryAfter(err))
+	})
+
+	t.Run("503 Service Unavailable is a transient error", func(t *testing.T) {
+		gock.New("http://dummy").Get("/url").Reply(503)
+		defer gock.Off()
+
+		err := client.RestGET(context.Background(), "http://dummy/url", nil, nil)
+
+		assert.True(t, IsTransientError(errors.Wrap(err, "wrapped")))
+		assert.Equal(t, time.Duration(0), GetRetryAfter(err))
+	})
+
+	t.Run("network error is a transient error", func(t *testing.T) {
+		gock.New("http://dummy").Get("/url").ReplyError(&url.Error{Op: "Get", URL: "http://dummy/url", Err: errors.New("timeout")})
+		defer gock.Off()
+
+		err := client.RestGET(context.Background(), "http://dummy/url", nil, nil)
+
+		assert.True(t, IsTransientError(err))
+	})
+
+	t.Run("400 Bad Request and 401 Unauthorized are not transient errors", func(t *testing.T) {
+		assert.False(t, IsTransientError(NewHttpErrorWithStatus("bad", "400 Bad Request", 400)))
+		assert.False(t, IsTransientError(NewHttpErrorWithStatus("auth", "401 Unauthorized", 401)))
+		assert.False(t, IsTransientError(NewHttpError("error unmarshalling rs", errors.New("json"))))
+	})
+
+	t.Run("refused connection is a connect error, response timeout is not", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer slow.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		refused := client.RestGET(context.Background(), closed.URL, nil, nil)
+		timedOut := client.RestGET(ctx, slow.URL, nil, nil)
+
+		assert.True(t, IsConnectError(refused))
+		assert.True(t, IsTransientError(timedOut))
+		assert.False(t, IsConnectError(timedOut))
+		assert.True(t, IsThrottledError(NewHttpErrorWithStatus("throttled", "429 Too Many Requests", 429)))
+		assert.False(t, IsThrottledError(NewHttpErrorWithStatus("unavailable", "503 Service Unavailable", 503)))
+	})
+
+	t.Run("Retry-After header parsing", func(t *testing.T) {
+		now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+		assert.Equal(t, 30*time.Second, parseRetryAfter("Mon, 01 Jan 2024 12:00:30 GMT", now))
+		assert.Equal(t, time.Duration(0), parseRetryAfter("Mon, 01 Jan 2024 11:00:00 GMT", now))
+		assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+		assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	})
+}
+
 func TestRestPOST(t *testing.T) {
 	client := NewHttpClient()
 
@@ -213,7 +284,7 @@ func TestRestPOST(t *testing.T) {
 
 		rq := TestRQ{RqField1: "rqVal1", RqField2: 567}
 		var rs TestRS
-		err := client.RestPOST("http://dummy/url?param=test1", &Headers{
+		err := client.RestPOST(context.Background(), "http://dummy/url?param=test1", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, rq, &rs)
 
@@ -235,7 +306,7 @@ func TestRestPOST(t *testing.T) {
 
 		var rs TestRS
 		rq := TestRQ{RqField1: "rqVal1", RqField2: 567}
-		err := client.RestPOST("http://dummy/url?param=test2", &Headers{
+		err := client.RestPOST(context.Background(), "http://dummy/url?param=test2", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, rq, &rs)
 
@@ -247,7 +318,7 @@ func TestRestPOST(t *testing.T) {
 		badRequest := func() {}
 
 		var rs TestRS
-		err := client.RestPOST("http://dummy/url?param=test2", &Headers{
+		err := client.RestPOST(context.Background(), "http://dummy/url?param=test2", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, badRequest, &rs)
 
@@ -264,7 +335,7 @@ func TestRestPOST(t *testing.T) {
 		defer gock.Off()
 
 		rq := TestRQ{RqField1: "rqVal1", RqField2: 567}
-		err := client.RestPOST("http://dummy/url?param=test1", &Headers{
+		err := client.RestPOST(context.Background(), "http://dummy/url?param=test1", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, rq, nil)
 
@@ -281,7 +352,7 @@ func TestRestPOST(t *testing.T) {
 
 		var rs TestRS
 		rq := TestRQ{RqField1: "rqVal1", RqField2: 567}
-		err := client.RestPOST("http://dummy/url?param=test1", &Headers{
+		err := client.RestPOST(context.Background(), "http://dummy/url?param=test1", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, rq, &rs)
 
@@ -335,7 +406,7 @@ func TestRequestLogging(t *testing.T) {
 
 		var rs TestRS
 		rq := TestRQ{RqField1: "rqVal1", RqField2: 567}
-		err := client.RestPOST("http://dummy/url?param=test1", &Headers{
+		err := client.RestPOST(context.Background(), "http://dummy/url?param=test1", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, rq, &rs)
 
@@ -366,7 +437,7 @@ func TestRequestLogging(t *testing.T) {
 
 		var rs TestRS
 		rq := TestRQ{RqField1: "rqVal1", RqField2: 567}
-		err := client.RestPOST("http://dummy/url?param=test1", &Headers{
+		err := client.RestPOST(context.Background(), "http://dummy/url?param=test1", &Headers{
 			{Key: "X-Header1", Value: "header-value-1"},
 		}, rq, &rs)
 
@@ -375,3 +446,50 @@ func TestRequestLogging(t *testing.T) {
 		assert.Equal(t, `error doing http call: Post "http://dummy/url?param=test1": mock error`, lastRsErr.Error())
 	})
 }
+
+func TestContextCancellation(t *testing.T) {
+	gock.Off()
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select { // hung upstream
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	t.Run("call is aborted when context deadline passes", func(t *testing.T) {
+		var loggedRq *http.Request
+		var loggedErr error
+		client := NewHttpClient().WithResponseLogger(func(rq *http.Request, rqBody []byte, rs *http.Response, rsBody []byte, err error, start time.Time) {
+			loggedRq, loggedErr = rq, err
+		})
+		type ctxKey string
+		ctx := context.WithValue(context.Background(), ctxKey("RequestID"), "rq1")
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+
+		err := client.RestGET(ctx, server.URL, nil, nil)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, IsTransientError(err))
+		assert.Less(t, time.Since(start), 5*time.Second)
+		assert.Equal(t, err, loggedErr)
+		assert.Equal(t, "rq1", loggedRq.Context().Value(ctxKey("RequestID"))) // request context is passed to loggers
+	})
+
+	t.Run("cancelled call is not a transient error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+		}()
+
+		err := NewHttpClient().RestGET(ctx, server.URL, nil, nil)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, IsTransientError(err))
+	})
+}
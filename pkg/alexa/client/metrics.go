@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/httpclient"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+const (
+	callResultSuccess   = "success"
+	callResultAuth      = "auth_error"
+	callResultTransient = "transient_error"
+	callResultError     = "error"
+	callResultOpen      = "circuit_open"
+	callResultCancelled = "cancelled"
+)
+
+var (
+	alexaCallsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alexa_calls_total",
+			Help: "Count of Alexa API calls by result, after retries and re-login",
+		},
+		[]string{"endpoint", "result"},
+	)
+
+	alexaCallDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "alexa_call_duration_seconds",
+			Help:    "Duration of single Alexa API http calls",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"endpoint"},
+	)
+
+	alexaLoginsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alexa_logins_total",
+			Help: "Count of Alexa login attempts, login is a first login with user and password, relogin is a login with user and password after auth errors, cookies is loading saved cookies",
+		},
+		[]string{"type"},
+	)
+
+	alexaLoginFailuresTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "alexa_login_failures_total",
+			Help: "Count of failed Alexa login attempts",
+		},
+		[]string{"type"},
+	)
+
+	sessionAgeDesc = prometheus.NewDesc("alexa_session_age_seconds",
+		"Seconds since csrf and cookies were obtained by login or loaded from saved cookies, -1 if not logged in yet", nil, nil)
+
+	lastSuccessAgeDesc = prometheus.NewDesc("alexa_last_success_age_seconds",
+		"Seconds since last successful Alexa API call, -1 if none yet", nil, nil)
+)
+
+// sessionCollector reads session gauges from client state at scrape time
+type sessionCollector struct {
+	client IAlexaClient
+}
+
+// NewSessionCollector returns collector of client session gauges, to be registered once per client
+func NewSessionCollector(client IAlexaClient) prometheus.Collector {
+	return &sessionCollector{client: client}
+}
+
+func (c *sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sessionAgeDesc
+	ch <- lastSuccessAgeDesc
+}
+
+func (c *sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	state := c.client.GetSessionState()
+	ch <- prometheus.MustNewConstMetric(sessionAgeDesc, prometheus.GaugeValue, secondsSince(state.LoggedInAt))
+	ch <- prometheus.MustNewConstMetric(lastSuccessAgeDesc, prometheus.GaugeValue, secondsSince(state.LastSuccessAt))
+}
+
+func secondsSince(at time.Time) float64 {
+	if at.IsZero() {
+		return -1
+	}
+	return time.Since(at).Seconds()
+}
+
+func loginType(relog bool, fullLogin bool) string {
+	switch {
+	case relog:
+		return "relogin"
+	case fullLogin:
+		return "login"
+	default:
+		return "cookies"
+	}
+}
+
+func observeLogin(relog bool, fullLogin bool, err error) {
+	alexaLoginsTotal.WithLabelValues(loginType(relog, fullLogin)).Inc()
+	if err != nil {
+		alexaLoginFailuresTotal.WithLabelValues(loginType(relog, fullLogin)).Inc()
+	}
+}
+
+func observeCallDuration(endpoint string, start time.Time) {
+	alexaCallDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+}
+
+func observeCall(ctx context.Context, endpoint string, err error) {
+	alexaCallsTotal.WithLabelValues(endpoint, callResult(ctx, err)).Inc()
+}
+
+func callResult(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return callResultSuccess
+	case errors.Is(err, ErrCircuitOpen):
+		return callResultOpen
+	case ctx.Err() != nil:
+		return callResultCancelled
+	case httpclient.IsAuthError(err):
+		return callResultAuth
+	case httpclient.IsTransientError(err):
+		return callResultTransient
+	default:
+		return callResultError
+	}
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/httpclient"
+	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/model"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// metrics are global, tests check deltas
+
+func TestAlexaClientMetrics(t *testing.T) {
+	expectedURL := "https://alexa.example.com/api/devices-v2/device?cached=false"
+
+	t.Run("call results and durations per endpoint", func(t *testing.T) {
+		mockHttpClient, mockCookieHelper, alexaClient := initClient()
+		alexaClient.(*AlexaClient).retryPolicy, _ = testRetryPolicy(2)
+		mockCookieHelper.On("CookiesSavedAt").Return(time.Time{})
+		unavailable := httpclient.NewHttpErrorWithStatus("mock unavailable", "503 Service Unavailable", 503)
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(noError()).Once()
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(unavailable).Twice()
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(errors.New("mock error")).Once()
+		successBefore := testutil.ToFloat64(alexaCallsTotal.WithLabelValues("GetDevices", callResultSuccess))
+		transientBefore := testutil.ToFloat64(alexaCallsTotal.WithLabelValues("GetDevices", callResultTransient))
+		errorBefore := testutil.ToFloat64(alexaCallsTotal.WithLabelValues("GetDevices", callResultError))
+		durationsBefore := histogramCount(t, "GetDevices")
+
+		_, _ = alexaClient.GetDevices(context.Background())
+		_, _ = alexaClient.GetDevices(context.Background())
+		_, _ = alexaClient.GetDevices(context.Background())
+
+		assert.Equal(t, successBefore+1, testutil.ToFloat64(alexaCallsTotal.WithLabelValues("GetDevices", callResultSuccess)))
+		assert.Equal(t, transientBefore+1, testutil.ToFloat64(alexaCallsTotal.WithLabelValues("GetDevices", callResultTransient)))
+		assert.Equal(t, errorBefore+1, testutil.ToFloat64(alexaCallsTotal.WithLabelValues("GetDevices", callResultError)))
+		assert.Equal(t, durationsBefore+4, histogramCount(t, "GetDevices")) // every http call, including retries
+		assert.InDelta(t, 0, sessionGauges(t, alexaClient)["alexa_last_success_age_seconds"], 1)
+	})
+
+	t.Run("first login with user and password", func(t *testing.T) {
+		mockHttpClient, mockCookieHelper, alexaClient := initClient()
+		cookieJar := new(MockCookieJar)
+		mockCookieHelper.On("CookiesSaved").Return(false)
+		loginStepsSuccess(mockHttpClient, mockCookieHelper)
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(noError())
+		mockHttpClient.On("GetCookieJar").Return(cookieJar)
+		mockCookieHelper.On("SaveCookies", cookieJar, "example.com").Return(noError())
+		mockCookieHelper.On("ExtractCSRF", cookieJar, "example.com").Return("csrfToken")
+		loginsBefore := testutil.ToFloat64(alexaLoginsTotal.WithLabelValues("login"))
+		reloginsBefore := testutil.ToFloat64(alexaLoginsTotal.WithLabelValues("relogin"))
+
+		err := alexaClient.LogIn(context.Background(), false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, loginsBefore+1, testutil.ToFloat64(alexaLoginsTotal.WithLabelValues("login")))
+		assert.Equal(t, reloginsBefore, testutil.ToFloat64(alexaLoginsTotal.WithLabelValues("relogin")))
+	})
+
+	t.Run("re-login attempts and session age", func(t *testing.T) {
+		alexaClient := newFakeAlexaClient(newFakeHttpClient(0, ""))
+		loginsBefore := testutil.ToFloat64(alexaLoginsTotal.WithLabelValues("relogin"))
+		failuresBefore := testutil.ToFloat64(alexaLoginFailuresTotal.WithLabelValues("relogin"))
+
+		_, err := alexaClient.GetDevices(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, loginsBefore+1, testutil.ToFloat64(alexaLoginsTotal.WithLabelValues("relogin")))
+		assert.Equal(t, failuresBefore, testutil.ToFloat64(alexaLoginFailuresTotal.WithLabelValues("relogin")))
+		assert.InDelta(t, 0, sessionGauges(t, alexaClient)["alexa_session_age_seconds"], 1)
+	})
+
+	t.Run("re-login failures", func(t *testing.T) {
+		alexaClient := newFakeAlexaClient(newFakeHttpClient(0, "https://www.example.com/ap/cvf/approval"))
+		failuresBefore := testutil.ToFloat64(alexaLoginFailuresTotal.WithLabelValues("relogin"))
+
+		_, err := alexaClient.GetDevices(context.Background())
+
+		assert.Error(t, err)
+		assert.Equal(t, failuresBefore+1, testutil.ToFloat64(alexaLoginFailuresTotal.WithLabelValues("relogin")))
+	})
+
+	t.Run("age gauges", func(t *testing.T) {
+		_, mockCookieHelper, alexaClient := initClient()
+		mockCookieHelper.On("CookiesSavedAt").Return(time.Time{})
+
+		assert.Equal(t, map[string]float64{
+			"alexa_session_age_seconds":      -1,
+			"alexa_last_success_age_seconds": -1,
+		}, sessionGauges(t, alexaClient))
+		assert.InDelta(t, 60, secondsSince(time.Now().Add(-time.Minute)), 1)
+	})
+}
+
+func sessionGauges(t *testing.T, alexaClient IAlexaClient) map[string]float64 {
+	t.Helper()
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(NewSessionCollector(alexaClient)))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	gauges := map[string]float64{}
+	for _, family := range families {
+		gauges[family.GetName()] = family.GetMetric()[0].GetGauge().GetValue()
+	}
+	return gauges
+}
+
+func histogramCount(t *testing.T, endpoint string) uint64 {
+	t.Helper()
+	metric := &dto.Metric{}
+	require.NoError(t, alexaCallDuration.WithLabelValues(endpoint).(prometheus.Histogram).Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
@@ -5,27 +5,36 @@ import (
 	"strconv"
 )
 
+const (
+	nodeTypeSequence  = "com.amazon.alexa.behaviors.model.Sequence"
+	nodeTypeSerial    = "com.amazon.alexa.behaviors.model.SerialNode"
+	nodeTypeParallel  = "com.amazon.alexa.behaviors.model.ParallelNode"
+	nodeTypeOperation = "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode"
+)
+
 type OperationPayload struct {
-	DeviceType         string `json:"deviceType"`
-	DeviceSerialNumber string `json:"deviceSerialNumber"`
-	CustomerID         string `json:"customerId"`
+	DeviceType         string `json:"deviceType,omitempty"`
+	DeviceSerialNumber string `json:"deviceSerialNumber,omitempty"`
+	CustomerID         string `json:"customerId,omitempty"`
 	Locale             string `json:"locale,omitempty"`
 	Text               string `json:"text,omitempty"`
 	TextToSpeak        string `json:"textToSpeak,omitempty"`
 	SoundStringID      string `json:"soundStringId,omitempty"`
 	Value              string `json:"value,omitempty"`
+	WaitTimeInSeconds  int    `json:"waitTimeInSeconds,omitempty"`
 }
 
-type StartNode struct {
-	Type             string           `json:"@type"`
-	NodeType         string           `json:"type"`
-	SkillID          string           `json:"skillId,omitempty"`
-	OperationPayload OperationPayload `json:"operationPayload"`
+type Node struct {
+	Type             string            `json:"@type"`
+	NodeType         string            `json:"type,omitempty"`
+	SkillID          string            `json:"skillId,omitempty"`
+	OperationPayload *OperationPayload `json:"operationPayload,omitempty"`
+	NodesToExecute   []Node            `json:"nodesToExecute,omitempty"`
 }
 
 type Sequence struct {
-	Type      string    `json:"@type"`
-	StartNode StartNode `json:"startNode"`
+	Type      string `json:"@type"`
+	StartNode Node   `json:"startNode"`
 }
 
 type AlexaCmd struct {
@@ -34,19 +43,21 @@ type AlexaCmd struct {
 	Status       string `json:"status"`
 }
 
+type DeviceTarget struct {
+	DeviceType         string
+	DeviceSerialNumber string
+	CustomerID         string
+}
+
 func BuildTextCommandCmd(
 	text string,
 	locale string,
 	deviceType string,
 	deviceSerialNumber string,
 	mediaOwnerCustomerID string) AlexaCmd {
-	return buildAlexaCmd("Alexa.TextCommand", "amzn1.ask.1p.tellalexa", OperationPayload{
-		DeviceType:         deviceType,
-		DeviceSerialNumber: deviceSerialNumber,
-		CustomerID:         mediaOwnerCustomerID,
-		Locale:             locale,
-		Text:               text,
-	})
+	return NewSequenceBuilder().
+		AddTextCommand(text, locale, DeviceTarget{deviceType, deviceSerialNumber, mediaOwnerCustomerID}).
+		Build()
 }
 
 func BuildSpeakCmd(
@@ -55,13 +66,9 @@ func BuildSpeakCmd(
 	deviceType string,
 	deviceSerialNumber string,
 	mediaOwnerCustomerID string) AlexaCmd {
-	return buildAlexaCmd("Alexa.Speak", "", OperationPayload{
-		DeviceType:         deviceType,
-		DeviceSerialNumber: deviceSerialNumber,
-		CustomerID:         mediaOwnerCustomerID,
-		Locale:             locale,
-		TextToSpeak:        text,
-	})
+	return NewSequenceBuilder().
+		AddSpeak(text, locale, DeviceTarget{deviceType, deviceSerialNumber, mediaOwnerCustomerID}).
+		Build()
 }
 
 func BuildVolumeCmd(
@@ -70,24 +77,124 @@ func BuildVolumeCmd(
 	deviceType string,
 	deviceSerialNumber string,
 	mediaOwnerCustomerID string) AlexaCmd {
-	return buildAlexaCmd("Alexa.DeviceControls.Volume", "", OperationPayload{
-		DeviceType:         deviceType,
-		DeviceSerialNumber: deviceSerialNumber,
-		CustomerID:         mediaOwnerCustomerID,
+	return NewSequenceBuilder().
+		AddVolume(volume, locale, DeviceTarget{deviceType, deviceSerialNumber, mediaOwnerCustomerID}).
+		Build()
+}
+
+func NewTextCommandNode(text string, locale string, target DeviceTarget) Node {
+	return newOperationNode("Alexa.TextCommand", "amzn1.ask.1p.tellalexa", &OperationPayload{
+		DeviceType:         target.DeviceType,
+		DeviceSerialNumber: target.DeviceSerialNumber,
+		CustomerID:         target.CustomerID,
+		Locale:             locale,
+		Text:               text,
+	})
+}
+
+func NewSpeakNode(text string, locale string, target DeviceTarget) Node {
+	return newOperationNode("Alexa.Speak", "", &OperationPayload{
+		DeviceType:         target.DeviceType,
+		DeviceSerialNumber: target.DeviceSerialNumber,
+		CustomerID:         target.CustomerID,
+		Locale:             locale,
+		TextToSpeak:        text,
+	})
+}
+
+func NewVolumeNode(volume int, locale string, target DeviceTarget) Node {
+	return newOperationNode("Alexa.DeviceControls.Volume", "", &OperationPayload{
+		DeviceType:         target.DeviceType,
+		DeviceSerialNumber: target.DeviceSerialNumber,
+		CustomerID:         target.CustomerID,
 		Locale:             locale,
 		Value:              strconv.Itoa(volume),
 	})
 }
 
-func buildAlexaCmd(commandType string, skillID string, commandPayload OperationPayload) AlexaCmd {
+func NewWaitNode(seconds int) Node {
+	return newOperationNode("Alexa.System.Wait", "", &OperationPayload{
+		WaitTimeInSeconds: seconds,
+	})
+}
+
+func newOperationNode(commandType string, skillID string, payload *OperationPayload) Node {
+	return Node{
+		Type:             nodeTypeOperation,
+		NodeType:         commandType,
+		SkillID:          skillID,
+		OperationPayload: payload,
+	}
+}
+
+// builder
+
+type SequenceBuilder struct {
+	nodeType string
+	nodes    []Node
+}
+
+// NewSequenceBuilder creates a builder that executes added nodes one after another.
+func NewSequenceBuilder() *SequenceBuilder {
+	return &SequenceBuilder{nodeType: nodeTypeSerial, nodes: make([]Node, 0)}
+}
+
+// NewParallelSequenceBuilder creates a builder that executes added nodes at the same time.
+func NewParallelSequenceBuilder() *SequenceBuilder {
+	return &SequenceBuilder{nodeType: nodeTypeParallel, nodes: make([]Node, 0)}
+}
+
+func (b *SequenceBuilder) AddNode(node Node) *SequenceBuilder {
+	b.nodes = append(b.nodes, node)
+	return b
+}
+
+func (b *SequenceBuilder) AddSequence(sequence *SequenceBuilder) *SequenceBuilder {
+	return b.AddNode(sequence.BuildNode())
+}
+
+func (b *SequenceBuilder) AddTextCommand(text string, locale string, target DeviceTarget) *SequenceBuilder {
+	return b.AddNode(NewTextCommandNode(text, locale, target))
+}
+
+func (b *SequenceBuilder) AddSpeak(text string, locale string, target DeviceTarget) *SequenceBuilder {
+	return b.AddNode(NewSpeakNode(text, locale, target))
+}
+
+func (b *SequenceBuilder) AddVolume(volume int, locale string, target DeviceTarget) *SequenceBuilder {
+	return b.AddNode(NewVolumeNode(volume, locale, target))
+}
+
+func (b *SequenceBuilder) AddWait(seconds int) *SequenceBuilder {
+	return b.AddNode(NewWaitNode(seconds))
+}
+
+// AddForEachDevice adds a parallel node with a sub-sequence per target, built by the add callback.
+func (b *SequenceBuilder) AddForEachDevice(targets []DeviceTarget, add func(sequence *SequenceBuilder, target DeviceTarget)) *SequenceBuilder {
+	parallel := NewParallelSequenceBuilder()
+	for _, target := range targets {
+		sequence := NewSequenceBuilder()
+		add(sequence, target)
+		parallel.AddSequence(sequence)
+	}
+	return b.AddSequence(parallel)
+}
+
+// BuildNode returns a single added node as is, otherwise wraps added nodes into a serial or parallel node.
+func (b *SequenceBuilder) BuildNode() Node {
+	if len(b.nodes) == 1 {
+		return b.nodes[0]
+	}
+	return Node{
+		Type:           b.nodeType,
+		NodesToExecute: b.nodes,
+	}
+}
+
+func (b *SequenceBuilder) Build() AlexaCmd {
 	seq := Sequence{
-		Type: "com.amazon.alexa.behaviors.model.Sequence",
-		StartNode: StartNode{
-			Type:             "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
-			NodeType:         commandType,
-			SkillID:          skillID,
-			OperationPayload: commandPayload,
-		},
+		Type:      nodeTypeSequence,
+		StartNode: b.BuildNode(),
 	}
 	seqJSON, _ := json.Marshal(seq)
 	return AlexaCmd{
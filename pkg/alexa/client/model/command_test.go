@@ -81,3 +81,217 @@ func TestCommandBuilders(t *testing.T) {
 	})
 
 }
+
+func TestSequenceBuilder(t *testing.T) {
+
+	t.Run("Test serial sequence with volume, wait and text command", func(t *testing.T) {
+		expectedSequence := `{
+			"@type": "com.amazon.alexa.behaviors.model.Sequence",
+			"startNode": {
+				"@type": "com.amazon.alexa.behaviors.model.SerialNode",
+				"nodesToExecute": [
+					{
+						"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+						"type": "Alexa.DeviceControls.Volume",
+						"operationPayload": {
+							"deviceType": "dt",
+							"deviceSerialNumber": "ds",
+							"customerId": "cid",
+							"locale": "en-US",
+							"value": "30"
+						}
+					},
+					{
+						"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+						"type": "Alexa.System.Wait",
+						"operationPayload": {
+							"waitTimeInSeconds": 2
+						}
+					},
+					{
+						"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+						"type": "Alexa.TextCommand",
+						"skillId": "amzn1.ask.1p.tellalexa",
+						"operationPayload": {
+							"deviceType": "dt",
+							"deviceSerialNumber": "ds",
+							"customerId": "cid",
+							"locale": "en-US",
+							"text": "ask skill to play"
+						}
+					}
+				]
+			}
+		}`
+
+		target := DeviceTarget{DeviceType: "dt", DeviceSerialNumber: "ds", CustomerID: "cid"}
+		cmd := NewSequenceBuilder().
+			AddVolume(30, "en-US", target).
+			AddWait(2).
+			AddTextCommand("ask skill to play", "en-US", target).
+			Build()
+
+		assert.Equal(t, "PREVIEW", cmd.BehaviorID)
+		assert.Equal(t, "ENABLED", cmd.Status)
+		assert.JSONEq(t, expectedSequence, cmd.SequenceJSON)
+	})
+
+	t.Run("Test parallel sequence speaking on several devices", func(t *testing.T) {
+		expectedSequence := `{
+			"@type": "com.amazon.alexa.behaviors.model.Sequence",
+			"startNode": {
+				"@type": "com.amazon.alexa.behaviors.model.ParallelNode",
+				"nodesToExecute": [
+					{
+						"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+						"type": "Alexa.Speak",
+						"operationPayload": {
+							"deviceType": "dt1",
+							"deviceSerialNumber": "ds1",
+							"customerId": "cid",
+							"locale": "en-US",
+							"textToSpeak": "meow"
+						}
+					},
+					{
+						"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+						"type": "Alexa.Speak",
+						"operationPayload": {
+							"deviceType": "dt2",
+							"deviceSerialNumber": "ds2",
+							"customerId": "cid",
+							"locale": "en-US",
+							"textToSpeak": "meow"
+						}
+					}
+				]
+			}
+		}`
+
+		cmd := NewSequenceBuilder().
+			AddForEachDevice([]DeviceTarget{
+				{DeviceType: "dt1", DeviceSerialNumber: "ds1", CustomerID: "cid"},
+				{DeviceType: "dt2", DeviceSerialNumber: "ds2", CustomerID: "cid"},
+			}, func(sequence *SequenceBuilder, target DeviceTarget) {
+				sequence.AddSpeak("meow", "en-US", target)
+			}).
+			Build()
+
+		assert.JSONEq(t, expectedSequence, cmd.SequenceJSON)
+	})
+
+	t.Run("Test nested serial sequences per device inside a parallel node", func(t *testing.T) {
+		expectedSequence := `{
+			"@type": "com.amazon.alexa.behaviors.model.Sequence",
+			"startNode": {
+				"@type": "com.amazon.alexa.behaviors.model.ParallelNode",
+				"nodesToExecute": [
+					{
+						"@type": "com.amazon.alexa.behaviors.model.SerialNode",
+						"nodesToExecute": [
+							{
+								"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+								"type": "Alexa.DeviceControls.Volume",
+								"operationPayload": {
+									"deviceType": "dt1",
+									"deviceSerialNumber": "ds1",
+									"customerId": "cid",
+									"locale": "en-US",
+									"value": "10"
+								}
+							},
+							{
+								"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+								"type": "Alexa.Speak",
+								"operationPayload": {
+									"deviceType": "dt1",
+									"deviceSerialNumber": "ds1",
+									"customerId": "cid",
+									"locale": "en-US",
+									"textToSpeak": "hello"
+								}
+							}
+						]
+					},
+					{
+						"@type": "com.amazon.alexa.behaviors.model.SerialNode",
+						"nodesToExecute": [
+							{
+								"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+								"type": "Alexa.DeviceControls.Volume",
+								"operationPayload": {
+									"deviceType": "dt2",
+									"deviceSerialNumber": "ds2",
+									"customerId": "cid",
+									"locale": "en-US",
+									"value": "10"
+								}
+							},
+							{
+								"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+								"type": "Alexa.Speak",
+								"operationPayload": {
+									"deviceType": "dt2",
+									"deviceSerialNumber": "ds2",
+									"customerId": "cid",
+									"locale": "en-US",
+									"textToSpeak": "hello"
+								}
+							}
+						]
+					}
+				]
+			}
+		}`
+
+		cmd := NewSequenceBuilder().
+			AddForEachDevice([]DeviceTarget{
+				{DeviceType: "dt1", DeviceSerialNumber: "ds1", CustomerID: "cid"},
+				{DeviceType: "dt2", DeviceSerialNumber: "ds2", CustomerID: "cid"},
+			}, func(sequence *SequenceBuilder, target DeviceTarget) {
+				sequence.AddVolume(10, "en-US", target).AddSpeak("hello", "en-US", target)
+			}).
+			Build()
+
+		assert.JSONEq(t, expectedSequence, cmd.SequenceJSON)
+	})
+
+	t.Run("Test explicit parallel builder nested into serial", func(t *testing.T) {
+		expectedSequence := `{
+			"@type": "com.amazon.alexa.behaviors.model.Sequence",
+			"startNode": {
+				"@type": "com.amazon.alexa.behaviors.model.SerialNode",
+				"nodesToExecute": [
+					{
+						"@type": "com.amazon.alexa.behaviors.model.ParallelNode",
+						"nodesToExecute": [
+							{
+								"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+								"type": "Alexa.System.Wait",
+								"operationPayload": {"waitTimeInSeconds": 1}
+							},
+							{
+								"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+								"type": "Alexa.System.Wait",
+								"operationPayload": {"waitTimeInSeconds": 3}
+							}
+						]
+					},
+					{
+						"@type": "com.amazon.alexa.behaviors.model.OpaquePayloadOperationNode",
+						"type": "Alexa.System.Wait",
+						"operationPayload": {"waitTimeInSeconds": 5}
+					}
+				]
+			}
+		}`
+
+		cmd := NewSequenceBuilder().
+			AddSequence(NewParallelSequenceBuilder().AddWait(1).AddWait(3)).
+			AddWait(5).
+			Build()
+
+		assert.JSONEq(t, expectedSequence, cmd.SequenceJSON)
+	})
+
+}
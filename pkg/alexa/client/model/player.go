@@ -0,0 +1,54 @@
+package model
+
+type PlayerStateResponse struct {
+	PlayerInfo PlayerInfo `json:"playerInfo"`
+}
+
+type PlayerInfo struct {
+	InfoText       *PlayerInfoText `json:"infoText"`
+	MainArt        *PlayerArt      `json:"mainArt"`
+	MediaID        *string         `json:"mediaId"`
+	PlayingInLemur bool            `json:"playingInLemur"`
+	Progress       *PlayerProgress `json:"progress"`
+	Provider       *PlayerProvider `json:"provider"`
+	QueueID        *string         `json:"queueId"`
+	State          *string         `json:"state"` // PLAYING/PAUSED/IDLE, nil if nothing was played
+	Volume         *PlayerVolume   `json:"volume"`
+}
+
+type PlayerInfoText struct {
+	Header         *string `json:"header"`
+	HeaderSubtext1 *string `json:"headerSubtext1"`
+	MultiLineMode  bool    `json:"multiLineMode"`
+	SubText1       *string `json:"subText1"`
+	SubText2       *string `json:"subText2"`
+	Title          *string `json:"title"`
+}
+
+type PlayerArt struct {
+	AltText   *string `json:"altText"`
+	ArtType   *string `json:"artType"`
+	ContentID *string `json:"contentId"`
+	URL       *string `json:"url"`
+}
+
+type PlayerProgress struct {
+	AllowScrubbing bool `json:"allowScrubbing"`
+	MediaLength    *int `json:"mediaLength"`   // seconds
+	MediaProgress  *int `json:"mediaProgress"` // seconds
+	ShowTiming     bool `json:"showTiming"`
+	Visible        bool `json:"visible"`
+}
+
+type PlayerProvider struct {
+	ArtOverlay          *string `json:"artOverlay"`
+	FallbackAltText     *string `json:"fallbackAltText"`
+	ProviderDisplayName *string `json:"providerDisplayName"`
+	ProviderName        *string `json:"providerName"`
+}
+
+type PlayerVolume struct {
+	AlertVolume *int `json:"alertVolume"`
+	Muted       bool `json:"muted"`
+	Volume      *int `json:"volume"`
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/httpclient"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy retries transient errors (429, 5xx, network) with exponential backoff and jitter,
+// Retry-After from Amazon is honored as long as it is within MaxDelay, otherwise the call fails right away
+type RetryPolicy struct {
+	MaxAttempts int // including the first call
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	sleep       func(ctx context.Context, delay time.Duration)
+	random      func() float64
+}
+
+func NewRetryPolicy(maxAttempts int, baseDelay time.Duration, maxDelay time.Duration) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   baseDelay,
+		MaxDelay:    maxDelay,
+		sleep:       sleepContext,
+		random:      rand.Float64,
+	}
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return NewRetryPolicy(3, 500*time.Millisecond, 5*time.Second)
+}
+
+// Retryable decides which errors of an endpoint are retried
+type Retryable func(err error) bool
+
+var (
+	// RetryTransient is for idempotent calls, any transient error is retried
+	RetryTransient Retryable = httpclient.IsTransientError
+	// RetryNotSent is for non-idempotent calls, a 5xx or timeout may come after Amazon already acted on the call,
+	// so only throttled calls and calls that never reached Amazon are retried
+	RetryNotSent Retryable = func(err error) bool {
+		return httpclient.IsThrottledError(err) || httpclient.IsConnectError(err)
+	}
+)
+
+// Do retries transient errors, stops retrying when ctx is done, returning the last call error
+func (p *RetryPolicy) Do(ctx context.Context, block func() error) (err error) {
+	return p.DoIf(ctx, RetryTransient, block)
+}
+
+// DoIf retries errors accepted by retryable
+func (p *RetryPolicy) DoIf(ctx context.Context, retryable Retryable, block func() error) (err error) {
+	for attempt := 1; ; attempt++ {
+		if err = block(); err == nil || !retryable(err) || attempt >= p.MaxAttempts {
+			return err
+		}
+		delay, ok := p.delay(attempt, err)
+		if !ok {
+			return err
+		}
+		p.sleep(ctx, delay)
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// delay for the next attempt: Retry-After if set, otherwise base * 2^(attempt-1) capped by MaxDelay,
+// with "equal jitter" (half fixed, half random) so concurrent callers don't retry in lockstep
+func (p *RetryPolicy) delay(attempt int, err error) (delay time.Duration, ok bool) {
+	if retryAfter := httpclient.GetRetryAfter(err); retryAfter > 0 {
+		return retryAfter, retryAfter <= p.MaxDelay
+	}
+	delay = p.BaseDelay << (attempt - 1)
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	return delay/2 + time.Duration(p.random()*float64(delay/2)), true
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/httpclient"
+	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+
+	t.Run("transient errors are retried with exponential backoff", func(t *testing.T) {
+		policy, sleeps := testRetryPolicy(4)
+		calls := 0
+
+		err := policy.Do(context.Background(), func() error {
+			calls++
+			if calls < 4 {
+				return httpclient.NewHttpErrorWithStatus("mock error", "503 Service Unavailable", 503)
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 4, calls)
+		assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}, *sleeps)
+	})
+
+	t.Run("backoff is capped by max delay and jittered", func(t *testing.T) {
+		policy, _ := testRetryPolicy(10)
+		policy.random = func() float64 { return 0.5 }
+
+		delay, ok := policy.delay(2, errors.New("mock error"))
+		assert.True(t, ok)
+		assert.Equal(t, 150*time.Millisecond, delay) // 200ms / 2 + 0.5 * 200ms / 2
+
+		delay, ok = policy.delay(8, errors.New("mock error"))
+		assert.True(t, ok)
+		assert.Equal(t, 750*time.Millisecond, delay) // capped at 1s
+
+		delay, ok = policy.delay(100, errors.New("mock error"))
+		assert.True(t, ok)
+		assert.Equal(t, 750*time.Millisecond, delay) // shift overflow
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		policy, sleeps := testRetryPolicy(3)
+		calls := 0
+
+		err := policy.Do(context.Background(), func() error {
+			calls++
+			return httpclient.NewHttpErrorWithStatus("mock error", "429 Too Many Requests", 429)
+		})
+
+		assert.ErrorContains(t, err, "mock error")
+		assert.Equal(t, 3, calls)
+		assert.Len(t, *sleeps, 2)
+	})
+
+	t.Run("non transient errors are not retried", func(t *testing.T) {
+		for _, err := range []error{
+			errors.New("mock error"),
+			httpclient.NewHttpErrorWithStatus("mock auth error", "401 Unauthorized", 401),
+			httpclient.NewHttpErrorWithStatus("mock error", "400 Bad Request", 400),
+		} {
+			policy, sleeps := testRetryPolicy(3)
+			calls := 0
+
+			result := policy.Do(context.Background(), func() error {
+				calls++
+				return err
+			})
+
+			assert.Equal(t, err, result)
+			assert.Equal(t, 1, calls)
+			assert.Empty(t, *sleeps)
+		}
+	})
+
+	t.Run("Retry-After is honored", func(t *testing.T) {
+		policy, sleeps := testRetryPolicy(3)
+		calls := 0
+
+		err := policy.Do(context.Background(), func() error {
+			calls++
+			if calls == 1 {
+				return httpclient.NewHttpErrorWithRetryAfter("mock error", "429 Too Many Requests", 429, 700*time.Millisecond)
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Duration{700 * time.Millisecond}, *sleeps)
+	})
+
+	t.Run("Retry-After above max delay fails right away", func(t *testing.T) {
+		policy, sleeps := testRetryPolicy(3)
+		calls := 0
+
+		err := policy.Do(context.Background(), func() error {
+			calls++
+			return httpclient.NewHttpErrorWithRetryAfter("mock error", "503 Service Unavailable", 503, time.Minute)
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Empty(t, *sleeps)
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+
+	t.Run("opens after threshold consecutive failures and fails fast", func(t *testing.T) {
+		breaker, _ := testCircuitBreaker()
+
+		breaker.Failure()
+		breaker.Failure()
+		assert.NoError(t, breaker.Allow())
+		assert.Equal(t, CircuitClosed, breaker.State().State)
+		breaker.Failure()
+
+		assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+		state := breaker.State()
+		assert.Equal(t, CircuitOpen, state.State)
+		assert.Equal(t, 3, state.ConsecutiveFailures)
+		assert.NotNil(t, state.OpenedAt)
+	})
+
+	t.Run("success resets failures", func(t *testing.T) {
+		breaker, _ := testCircuitBreaker()
+
+		breaker.Failure()
+		breaker.Failure()
+		breaker.Success()
+		breaker.Failure()
+		breaker.Failure()
+
+		assert.NoError(t, breaker.Allow())
+		assert.Equal(t, 2, breaker.State().ConsecutiveFailures)
+	})
+
+	t.Run("half-open after timeout, closes on success", func(t *testing.T) {
+		breaker, now := testCircuitBreaker()
+		breaker.Failure()
+		breaker.Failure()
+		breaker.Failure()
+
+		*now = now.Add(time.Minute)
+
+		assert.NoError(t, breaker.Allow())
+		assert.Equal(t, CircuitHalfOpen, breaker.State().State)
+		breaker.Success()
+		assert.Equal(t, CircuitBreakerState{State: CircuitClosed}, breaker.State())
+	})
+
+	t.Run("half-open lets a single probe through", func(t *testing.T) {
+		breaker, now := testCircuitBreaker()
+		breaker.Failure()
+		breaker.Failure()
+		breaker.Failure()
+
+		*now = now.Add(time.Minute)
+
+		assert.NoError(t, breaker.Allow()) // probe
+		assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+		assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+		*now = now.Add(10 * time.Second)
+		assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+		breaker.Success()
+		assert.NoError(t, breaker.Allow())
+		assert.NoError(t, breaker.Allow())
+	})
+
+	t.Run("half-open probe that never reports back is replaced after timeout", func(t *testing.T) {
+		breaker, now := testCircuitBreaker()
+		breaker.Failure()
+		breaker.Failure()
+		breaker.Failure()
+
+		*now = now.Add(time.Minute)
+		assert.NoError(t, breaker.Allow()) // probe, caller went away
+		*now = now.Add(time.Minute)
+
+		assert.NoError(t, breaker.Allow())
+		assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+	})
+
+	t.Run("half-open after timeout, re-opens on failure", func(t *testing.T) {
+		breaker, now := testCircuitBreaker()
+		breaker.Failure()
+		breaker.Failure()
+		breaker.Failure()
+
+		*now = now.Add(time.Minute)
+
+		assert.NoError(t, breaker.Allow())
+		breaker.Failure()
+		assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+		assert.Equal(t, *now, *breaker.State().OpenedAt)
+	})
+}
+
+func TestAlexaClientCircuitBreaker(t *testing.T) {
+
+	t.Run("half-open probe hits 401, re-login goes through with the probe and closes it", func(t *testing.T) {
+		mockHttpClient, mockCookieHelper, alexaClient := initClient()
+		breaker, now := testCircuitBreaker()
+		alexaClient.(*AlexaClient).breaker = breaker
+		breaker.Failure()
+		breaker.Failure()
+		breaker.Failure()
+		*now = now.Add(time.Minute)
+		cookieJar := new(MockCookieJar)
+		expectedURL := "https://alexa.example.com/api/devices-v2/device?cached=false"
+		unauthorized := httpclient.NewHttpErrorWithStatus("mock auth error", "401 Unauthorized", 401)
+
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(unauthorized).Once()
+		mockHttpClient.On("ResetCookieJar").Return()
+		loginStepsSuccess(mockHttpClient, mockCookieHelper)
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders(""), &model.DevicesResponse{}).Return(noError()).Once() // login
+		mockHttpClient.On("GetCookieJar").Return(cookieJar)
+		mockCookieHelper.On("SaveCookies", cookieJar, "example.com").Return(noError())
+		mockCookieHelper.On("ExtractCSRF", cookieJar, "example.com").Return("csrfToken")
+		mockHttpClient.On("RestGET", expectedURL, expectedHeaders("csrfToken"), &model.DevicesResponse{}).Return(noError()).Once()
+
+		_, err := alexaClient.GetDevices(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, CircuitBreakerState{State: CircuitClosed}, alexaClient.GetCircuitBreakerState())
+		mockHttpClient.AssertExpectations(t)
+	})
+
+	t.Run("login while open is not blocked and does not report to circuit breaker", func(t *testing.T) {
+		mockHttpClient, mockCookieHelper, alexaClient := initClient()
+		breaker, _ := testCircuitBreaker()
+		alexaClient.(*AlexaClient).breaker = breaker
+		breaker.Failure()
+		breaker.Failure()
+		breaker.Failure()
+		cookieJar := new(MockCookieJar)
+
+		mockCookieHelper.On("CookiesSaved").Return(false)
+		loginStepsSuccess(mockHttpClient, mockCookieHelper)
+		mockHttpClient.On("RestGET", "https://alexa.example.com/api/devices-v2/device?cached=false", expectedHeaders(""), &model.DevicesResponse{}).Return(noError())
+		mockHttpClient.On("GetCookieJar").Return(cookieJar)
+		mockCookieHelper.On("SaveCookies", cookieJar, "example.com").Return(noError())
+		mockCookieHelper.On("ExtractCSRF", cookieJar, "example.com").Return("csrfToken")
+
+		err := alexaClient.LogIn(context.Background(), false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, CircuitOpen, alexaClient.GetCircuitBreakerState().State)
+	})
+}
+
+func testRetryPolicy(maxAttempts int) (policy *RetryPolicy, sleeps *[]time.Duration) {
+	sleeps = &[]time.Duration{}
+	policy = NewRetryPolicy(maxAttempts, 100*time.Millisecond, time.Second)
+	policy.sleep = func(ctx context.Context, delay time.Duration) { *sleeps = append(*sleeps, delay) }
+	policy.random = func() float64 { return 1 }
+	return policy, sleeps
+}
+
+func testCircuitBreaker() (breaker *CircuitBreaker, now *time.Time) {
+	current := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	breaker = NewCircuitBreaker(3, 30*time.Second)
+	breaker.now = func() time.Time { return current }
+	return breaker, &current
+}
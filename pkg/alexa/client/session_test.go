@@ -0,0 +1,223 @@
+package client
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/httpclient"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// run with -race, concurrent callers share one http client and session
+
+func TestAlexaClientConcurrentLogIn(t *testing.T) {
+	const callers = 10
+
+	t.Run("concurrent auth errors run a single login", func(t *testing.T) {
+		fakeHttpClient := newFakeHttpClient(callers, "")
+		alexaClient := newFakeAlexaClient(fakeHttpClient)
+
+		errs := callConcurrently(callers, func() error {
+			_, err := alexaClient.GetDevices(context.Background())
+			return err
+		})
+
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 1, fakeHttpClient.logins())
+		assert.Equal(t, []string{"csrf"}, fakeHttpClient.acceptedCSRFs())
+	})
+
+	t.Run("waiting callers get login error", func(t *testing.T) {
+		fakeHttpClient := newFakeHttpClient(callers, "https://www.example.com/ap/cvf/approval")
+		alexaClient := newFakeAlexaClient(fakeHttpClient)
+
+		errs := callConcurrently(callers, func() error {
+			_, err := alexaClient.GetDevices(context.Background())
+			return err
+		})
+
+		for _, err := range errs {
+			assert.ErrorContains(t, err, "Alexa.LogIn submit step 2 login form failed")
+		}
+		assert.Equal(t, 1, fakeHttpClient.logins())
+	})
+
+	t.Run("concurrent LogIn and calls", func(t *testing.T) {
+		fakeHttpClient := newFakeHttpClient(0, "")
+		alexaClient := newFakeAlexaClient(fakeHttpClient)
+
+		errs := callConcurrently(callers, func() error {
+			if err := alexaClient.LogIn(context.Background(), true); err != nil {
+				return err
+			}
+			_, err := alexaClient.GetVolume(context.Background())
+			return err
+		})
+
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+		assert.LessOrEqual(t, fakeHttpClient.logins(), callers)
+	})
+
+	t.Run("waiting caller gives up on its context", func(t *testing.T) {
+		fakeHttpClient := newFakeHttpClient(0, "")
+		fakeHttpClient.loginBlock = make(chan struct{})
+		alexaClient := newFakeAlexaClient(fakeHttpClient)
+
+		leaderErr := make(chan error)
+		go func() { leaderErr <- alexaClient.LogIn(context.Background(), true) }()
+		<-fakeHttpClient.loginStarted
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := alexaClient.LogIn(ctx, true)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		close(fakeHttpClient.loginBlock)
+		assert.NoError(t, <-leaderErr)
+		assert.Equal(t, 1, fakeHttpClient.logins())
+	})
+}
+
+func callConcurrently(n int, call func() error) []error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = call()
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func newFakeAlexaClient(fakeHttpClient *fakeHttpClient) IAlexaClient {
+	return NewAlexaClientWithHttpClient("example.com", "testUser", "testPassword", &fakeCookieHelper{}, fakeHttpClient)
+}
+
+// fakeHttpClient rejects calls with 401 until a login completes, login waits until expected callers got their 401
+type fakeHttpClient struct {
+	mutex         sync.Mutex
+	loggedIn      bool
+	loginCount    int
+	authErrors    int
+	csrfs         map[string]bool
+	waitFor       int           // number of 401s before login proceeds, to have callers pile up
+	allRejected   chan struct{} // closed after waitFor 401s
+	loginStarted  chan struct{}
+	loginBlock    chan struct{} // optional, login waits for it
+	loginRedirect string        // final login redirect, wrong one fails login
+}
+
+func newFakeHttpClient(waitFor int, loginRedirect string) *fakeHttpClient {
+	if loginRedirect == "" {
+		loginRedirect = "https://www.example.com/ap/maplanding"
+	}
+	fake := &fakeHttpClient{
+		csrfs:         map[string]bool{},
+		waitFor:       waitFor,
+		allRejected:   make(chan struct{}),
+		loginStarted:  make(chan struct{}, 1),
+		loginRedirect: loginRedirect,
+	}
+	if waitFor == 0 {
+		close(fake.allRejected)
+	}
+	return fake
+}
+
+func (f *fakeHttpClient) logins() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.loginCount
+}
+
+func (f *fakeHttpClient) acceptedCSRFs() (csrfs []string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for csrf := range f.csrfs {
+		csrfs = append(csrfs, csrf)
+	}
+	return csrfs
+}
+
+func (f *fakeHttpClient) GetCookieJar() http.CookieJar {
+	return nil
+}
+
+func (f *fakeHttpClient) ResetCookieJar() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.loggedIn = false
+}
+
+func (f *fakeHttpClient) SimpleGET(_ context.Context, _ string, _ *httpclient.Headers) (*httpclient.Response, error) {
+	f.mutex.Lock()
+	f.loginCount++
+	f.mutex.Unlock()
+	select {
+	case f.loginStarted <- struct{}{}:
+	default:
+	}
+	<-f.allRejected
+	time.Sleep(20 * time.Millisecond) // let rejected callers reach the login
+	if f.loginBlock != nil {
+		<-f.loginBlock
+	}
+	return &httpclient.Response{Status: 200, Body: "form"}, nil
+}
+
+func (f *fakeHttpClient) SimplePOST(_ context.Context, _ string, _ *httpclient.Headers, formData *url.Values) (*httpclient.Response, error) {
+	if formData.Get("password") == "" {
+		return &httpclient.Response{Status: 200, Body: "form"}, nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.loggedIn = strings.Contains(f.loginRedirect, "maplanding")
+	return &httpclient.Response{Status: 302, Redirect: f.loginRedirect}, nil
+}
+
+func (f *fakeHttpClient) RestGET(_ context.Context, _ string, headers *httpclient.Headers, _ interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if !f.loggedIn {
+		f.authErrors++
+		if f.authErrors == f.waitFor {
+			close(f.allRejected)
+		}
+		return httpclient.NewHttpErrorWithStatus("unauthorized", "401 Unauthorized", 401)
+	}
+	for _, header := range *headers {
+		if header.Key == "csrf" && header.Value != "" {
+			f.csrfs[header.Value] = true
+		}
+	}
+	return nil
+}
+
+func (f *fakeHttpClient) RestPOST(ctx context.Context, url string, headers *httpclient.Headers, _ interface{}, response interface{}) error {
+	return f.RestGET(ctx, url, headers, response)
+}
+
+type fakeCookieHelper struct{}
+
+func (f *fakeCookieHelper) CookiesSaved() bool                           { return false }
+func (f *fakeCookieHelper) CookiesSavedAt() time.Time                    { return time.Time{} }
+func (f *fakeCookieHelper) SaveCookies(_ http.CookieJar, _ string) error { return nil }
+func (f *fakeCookieHelper) LoadCookies(_ http.CookieJar, _ string) error { return nil }
+func (f *fakeCookieHelper) ExtractCSRF(_ http.CookieJar, _ string) string {
+	return "csrf"
+}
+func (f *fakeCookieHelper) ExtractLoginForm(pageHtml string) string { return pageHtml }
+func (f *fakeCookieHelper) ExtractLoginFormInputs(_ string) *url.Values {
+	return &url.Values{}
+}
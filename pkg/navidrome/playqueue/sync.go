@@ -0,0 +1,89 @@
+package playqueue
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/subsonic"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"slices"
+	"sync"
+	"time"
+)
+
+type IPlayQueueSync interface {
+	Changed()
+}
+
+// Sync saves the queue as Navidrome play queue of the configured user, so other Subsonic clients continue where Echo stopped.
+// Saves run in background, only the latest queue is saved if Navidrome is slower than queue changes.
+type Sync struct {
+	queue   *model.Queue
+	client  subsonic.ISubsonicClient
+	timeout time.Duration
+	mu      sync.Mutex
+	pending chan snapshot
+}
+
+type snapshot struct {
+	songIds  []string
+	current  string
+	position int // ms
+}
+
+func NewSync(queue *model.Queue, client subsonic.ISubsonicClient, timeout time.Duration) *Sync {
+	s := &Sync{queue: queue, client: client, timeout: timeout, pending: make(chan snapshot, 1)}
+	go s.run()
+	return s
+}
+
+// Changed schedules save of the queue, called by whoever changed it so snapshot sees the change
+func (s *Sync) Changed() {
+	latest := takeSnapshot(s.queue)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.pending: // replaced by latest
+	default:
+	}
+	s.pending <- latest
+}
+
+func (s *Sync) run() {
+	var saved snapshot
+	first := true
+	for latest := range s.pending {
+		if !first && saved.equal(latest) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		err := s.client.SavePlayQueue(ctx, latest.songIds, latest.current, latest.position)
+		cancel()
+		if err != nil {
+			log.Logger().Warn("Saving Navidrome play queue failed", "error", err)
+			continue
+		}
+		log.Logger().Debug("Saved Navidrome play queue", "songs", len(latest.songIds), "current", latest.current, "position", latest.position)
+		saved, first = latest, false
+	}
+}
+
+// takeSnapshot keeps Navidrome songs only, imported stream URLs are not in the library
+func takeSnapshot(queue *model.Queue) snapshot {
+	var taken snapshot
+	taken.songIds = make([]string, 0, len(queue.Songs))
+	for i, song := range queue.Songs {
+		if song.External() {
+			continue
+		}
+		taken.songIds = append(taken.songIds, song.Id)
+		if i == queue.QueuePosition {
+			taken.current = song.Id
+			taken.position = queue.TrackPosition
+		}
+	}
+	return taken
+}
+
+func (s snapshot) equal(other snapshot) bool {
+	return s.current == other.current && s.position == other.position && slices.Equal(s.songIds, other.songIds)
+}
@@ -0,0 +1,112 @@
+package playqueue
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/subsonic"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSyncChanged(t *testing.T) {
+	log.InitWithLogger(slog.Default())
+
+	t.Run("Changed, saves Navidrome songs with current and position", func(t *testing.T) {
+		client := newMockSubsonicClient(nil)
+		queue := model.NewQueue()
+		queue.Songs = []model.Song{{Id: "Id1", Stream: "/stream/Id1"}, {Id: "url-1", Stream: "https://radio.example.com/stream"}, {Id: "Id2", Stream: "/stream/Id2"}}
+		queue.QueuePosition = 2
+		queue.TrackPosition = 12345
+
+		NewSync(queue, client, time.Second).Changed()
+
+		assert.Equal(t, snapshot{songIds: []string{"Id1", "Id2"}, current: "Id2", position: 12345}, client.next(t))
+	})
+
+	t.Run("Changed, saves without current if it is a stream URL", func(t *testing.T) {
+		client := newMockSubsonicClient(nil)
+		queue := model.NewQueue()
+		queue.Songs = []model.Song{{Id: "Id1", Stream: "/stream/Id1"}, {Id: "url-1", Stream: "https://radio.example.com/stream"}}
+		queue.QueuePosition = 1
+		queue.TrackPosition = 12345
+
+		NewSync(queue, client, time.Second).Changed()
+
+		assert.Equal(t, snapshot{songIds: []string{"Id1"}}, client.next(t))
+	})
+
+	t.Run("Changed, skips unchanged queue and retries after failure", func(t *testing.T) {
+		client := newMockSubsonicClient(errors.New("save failed"))
+		queue := model.NewQueue()
+		queue.Songs = []model.Song{{Id: "Id1", Stream: "/stream/Id1"}}
+		sync := NewSync(queue, client, time.Second)
+
+		sync.Changed()
+		client.next(t) // failed
+		client.err = nil
+		sync.Changed()
+		client.next(t) // saved
+		sync.Changed() // same as saved
+		queue.TrackPosition = 10
+		sync.Changed()
+
+		assert.Equal(t, snapshot{songIds: []string{"Id1"}, current: "Id1", position: 10}, client.next(t))
+	})
+
+	t.Run("Changed, saves latest queue if Navidrome is slow", func(t *testing.T) {
+		client := newMockSubsonicClient(nil)
+		client.block = make(chan struct{})
+		queue := model.NewQueue()
+		queue.Songs = []model.Song{{Id: "Id1", Stream: "/stream/Id1"}, {Id: "Id2", Stream: "/stream/Id2"}, {Id: "Id3", Stream: "/stream/Id3"}}
+		sync := NewSync(queue, client, time.Second)
+
+		sync.Changed()
+		client.next(t) // save in progress
+		for position := 0; position < 3; position++ {
+			queue.QueuePosition = position
+			sync.Changed()
+		}
+		close(client.block)
+
+		assert.Equal(t, "Id3", client.next(t).current)
+		select {
+		case extra := <-client.saved:
+			t.Fatalf("unexpected save %v", extra)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+type mockSubsonicClient struct {
+	subsonic.ISubsonicClient // only play queue is used
+	err                      error
+	block                    chan struct{}
+	saved                    chan snapshot
+}
+
+func newMockSubsonicClient(err error) *mockSubsonicClient {
+	return &mockSubsonicClient{err: err, saved: make(chan snapshot, 10)}
+}
+
+func (m *mockSubsonicClient) SavePlayQueue(_ context.Context, songIds []string, current string, position int) error {
+	err := m.err
+	m.saved <- snapshot{songIds: songIds, current: current, position: position}
+	if m.block != nil {
+		<-m.block
+	}
+	return err
+}
+
+func (m *mockSubsonicClient) next(t *testing.T) snapshot {
+	select {
+	case saved := <-m.saved:
+		return saved
+	case <-time.After(time.Second):
+		t.Fatal("play queue not saved")
+		return snapshot{}
+	}
+}
@@ -0,0 +1,345 @@
+package subsonic
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	errorNotFound = 70
+
+	apiVersion = "1.16.1"
+	clientName = "navidrome-alexa"
+	maxSize    = 10 * 1024 * 1024
+	// maxQueryLength is longest query sent in URL, longer song id lists are form posted
+	// (OpenSubsonic formPost extension, Navidrome supports it)
+	maxQueryLength = 4000
+)
+
+type ISubsonicClient interface {
+	Search(ctx context.Context, query string, count int) (songs []Song, err error)
+	CreatePlaylist(ctx context.Context, playlistId string, name string, songIds []string) (playlist *Playlist, err error)
+	UpdatePlaylist(ctx context.Context, playlistId string, songIds []string) (err error)
+	GetPlayQueue(ctx context.Context) (playQueue *PlayQueue, err error)
+	SavePlayQueue(ctx context.Context, songIds []string, current string, position int) (err error)
+	Ping(ctx context.Context, user string, token string, salt string) (err error)
+	CoverArt(ctx context.Context, id string, size int) (image []byte, contentType string, err error)
+	StreamPath(id string) string
+	CoverPath(id string) string
+	Authenticate(path string) string
+}
+
+// SubsonicClient calls Navidrome Subsonic API with token authentication of a configured user
+type SubsonicClient struct {
+	navidromeURL atomic.Pointer[string]
+	user         string
+	password     string
+	client       *http.Client
+}
+
+type Song struct {
+	Id       string `json:"id"`
+	Title    string `json:"title"`
+	Album    string `json:"album"`
+	Artist   string `json:"artist"`
+	Duration int    `json:"duration"` // seconds
+	CoverArt string `json:"coverArt"`
+}
+
+type Playlist struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	SongCount int    `json:"songCount"`
+}
+
+// PlayQueue is per-user queue Subsonic clients save to continue playback on another client
+type PlayQueue struct {
+	Entry    []Song `json:"entry"`
+	Current  string `json:"current"`  // song id
+	Position int    `json:"position"` // ms in current song
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return "subsonic error " + strconv.Itoa(e.Code) + ": " + e.Message
+}
+
+type response struct {
+	Response struct {
+		Status        string `json:"status"`
+		Error         *Error `json:"error"`
+		SearchResult3 struct {
+			Song []Song `json:"song"`
+		} `json:"searchResult3"`
+		Playlist  *Playlist  `json:"playlist"`
+		PlayQueue *PlayQueue `json:"playQueue"`
+	} `json:"subsonic-response"`
+}
+
+func NewSubsonicClient(navidromeURL string, user string, password string, timeout time.Duration) *SubsonicClient {
+	client := &SubsonicClient{
+		user:     user,
+		password: password,
+		client:   &http.Client{Timeout: timeout},
+	}
+	client.SetURL(navidromeURL)
+	return client
+}
+
+// URL is Navidrome base URL calls go to
+func (c *SubsonicClient) URL() string {
+	return *c.navidromeURL.Load()
+}
+
+// SetURL points calls to another Navidrome base URL, calls in flight finish with the old one
+func (c *SubsonicClient) SetURL(navidromeURL string) {
+	c.navidromeURL.Store(&navidromeURL)
+}
+
+// Search finds songs by title, album or artist
+func (c *SubsonicClient) Search(ctx context.Context, query string, count int) ([]Song, error) {
+	rs, err := c.call(ctx, "search3", url.Values{
+		"query":       {query},
+		"songCount":   {strconv.Itoa(count)},
+		"albumCount":  {"0"},
+		"artistCount": {"0"},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "SubsonicClient.Search failed")
+	}
+	return rs.Response.SearchResult3.Song, nil
+}
+
+// CreatePlaylist creates playlist with songs, or replaces songs of playlistId if set
+func (c *SubsonicClient) CreatePlaylist(ctx context.Context, playlistId string, name string, songIds []string) (*Playlist, error) {
+	params := url.Values{"songId": songIds}
+	if playlistId != "" {
+		params.Set("playlistId", playlistId)
+	} else {
+		params.Set("name", name)
+	}
+	rs, err := c.callSongIds(ctx, "createPlaylist", params)
+	if err != nil {
+		return nil, errors.Wrap(err, "SubsonicClient.CreatePlaylist failed")
+	}
+	if rs.Response.Playlist == nil { // servers before API 1.14 return no playlist
+		return &Playlist{Id: playlistId, Name: name, SongCount: len(songIds)}, nil
+	}
+	return rs.Response.Playlist, nil
+}
+
+// UpdatePlaylist appends songs to playlist
+func (c *SubsonicClient) UpdatePlaylist(ctx context.Context, playlistId string, songIds []string) error {
+	if _, err := c.callSongIds(ctx, "updatePlaylist", url.Values{"playlistId": {playlistId}, "songIdToAdd": songIds}); err != nil {
+		return errors.Wrap(err, "SubsonicClient.UpdatePlaylist failed")
+	}
+	return nil
+}
+
+// GetPlayQueue returns saved play queue of the user, nil if there is none
+func (c *SubsonicClient) GetPlayQueue(ctx context.Context) (*PlayQueue, error) {
+	rs, err := c.call(ctx, "getPlayQueue", url.Values{})
+	var subsonicErr *Error
+	if errors.As(err, &subsonicErr) && subsonicErr.Code == errorNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "SubsonicClient.GetPlayQueue failed")
+	}
+	return rs.Response.PlayQueue, nil
+}
+
+// SavePlayQueue replaces saved play queue of the user, current is omitted if empty
+func (c *SubsonicClient) SavePlayQueue(ctx context.Context, songIds []string, current string, position int) error {
+	params := url.Values{"id": songIds}
+	if current != "" {
+		params.Set("current", current)
+		params.Set("position", strconv.Itoa(position))
+	}
+	if _, err := c.callSongIds(ctx, "savePlayQueue", params); err != nil {
+		return errors.Wrap(err, "SubsonicClient.SavePlayQueue failed")
+	}
+	return nil
+}
+
+// Ping checks credentials of another Navidrome user (token is md5 of password and salt), not the configured one.
+// Rejected credentials are returned as *Error
+func (c *SubsonicClient) Ping(ctx context.Context, user string, token string, salt string) error {
+	query := url.Values{
+		"u": {user},
+		"t": {token},
+		"s": {salt},
+		"v": {apiVersion},
+		"c": {clientName},
+	}
+	if _, err := c.do(ctx, "ping.view", query, false); err != nil {
+		return errors.Wrap(err, "SubsonicClient.Ping failed")
+	}
+	return nil
+}
+
+// CoverArt fetches cover image with configured user credentials, so it can be served to clients without them
+func (c *SubsonicClient) CoverArt(ctx context.Context, id string, size int) ([]byte, string, error) {
+	query := c.authQuery(url.Values{"id": {id}, "size": {strconv.Itoa(size)}})
+	query.Set("f", "json") // errors come back as json instead of an image
+	rq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL()+"/rest/getCoverArt?"+query.Encode(), nil)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "SubsonicClient.CoverArt creating request failed")
+	}
+	rs, err := c.client.Do(rq)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "SubsonicClient.CoverArt failed")
+	}
+	defer rs.Body.Close()
+	if rs.StatusCode != http.StatusOK {
+		return nil, "", errors.New("SubsonicClient.CoverArt failed, status " + strconv.Itoa(rs.StatusCode))
+	}
+	image, err := io.ReadAll(io.LimitReader(rs.Body, maxSize))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "SubsonicClient.CoverArt reading image failed")
+	}
+	contentType := rs.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "application/json") {
+		var parsed response
+		if err = json.Unmarshal(image, &parsed); err == nil && parsed.Response.Error != nil {
+			return nil, "", errors.Wrap(parsed.Response.Error, "SubsonicClient.CoverArt failed")
+		}
+		return nil, "", errors.New("SubsonicClient.CoverArt failed, not an image")
+	}
+	return image, contentType, nil
+}
+
+// StreamPath is stream path relative to Navidrome URL, without credentials so it can be shown and exported
+func (c *SubsonicClient) StreamPath(id string) string {
+	return "/rest/stream?" + url.Values{"id": {id}}.Encode()
+}
+
+// CoverPath is cover art path relative to Navidrome URL, without credentials
+func (c *SubsonicClient) CoverPath(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "/rest/getCoverArt?" + url.Values{"id": {id}, "size": {"300"}}.Encode()
+}
+
+// Authenticate adds token credentials to relative Subsonic path, used only for URLs handed to Alexa.
+// Paths that already carry credentials (queue posted by Navidrome UI) are returned as is
+func (c *SubsonicClient) Authenticate(path string) string {
+	parsed, err := url.Parse(path)
+	if err != nil || parsed.IsAbs() || parsed.Query().Has("u") {
+		return path
+	}
+	parsed.RawQuery = c.authQuery(parsed.Query()).Encode()
+	return parsed.String()
+}
+
+// StripCredentials removes credentials (user, token, salt, password, api key) from relative Subsonic path,
+// so paths posted by Navidrome UI can be exported. Other paths and absolute URLs are returned as is
+func StripCredentials(path string) string {
+	parsed, err := url.Parse(path)
+	if err != nil || parsed.IsAbs() {
+		return path
+	}
+	query := parsed.Query()
+	stripped := false
+	for _, param := range []string{"u", "t", "s", "p", "apiKey"} {
+		if query.Has(param) {
+			query.Del(param)
+			stripped = true
+		}
+	}
+	if !stripped {
+		return path
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+func (c *SubsonicClient) call(ctx context.Context, endpoint string, params url.Values) (*response, error) {
+	return c.do(ctx, endpoint, c.authQuery(params), false)
+}
+
+// callSongIds is call with song id list, form posted when it doesn't fit into URL
+func (c *SubsonicClient) callSongIds(ctx context.Context, endpoint string, params url.Values) (*response, error) {
+	return c.do(ctx, endpoint, c.authQuery(params), true)
+}
+
+// do sends query that already has credentials
+func (c *SubsonicClient) do(ctx context.Context, endpoint string, query url.Values, allowPost bool) (*response, error) {
+	query.Set("f", "json")
+	encoded := query.Encode()
+	var rq *http.Request
+	var err error
+	if allowPost && len(encoded) > maxQueryLength {
+		rq, err = http.NewRequestWithContext(ctx, http.MethodPost, c.URL()+"/rest/"+endpoint, strings.NewReader(encoded))
+		if err == nil {
+			rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		rq, err = http.NewRequestWithContext(ctx, http.MethodGet, c.URL()+"/rest/"+endpoint+"?"+encoded, nil)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request failed")
+	}
+	rs, err := c.client.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Body.Close()
+	if rs.StatusCode != http.StatusOK {
+		return nil, errors.New(endpoint + " failed, status " + strconv.Itoa(rs.StatusCode))
+	}
+	body, err := io.ReadAll(io.LimitReader(rs.Body, maxSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading response failed")
+	}
+	var parsed response
+	if err = json.Unmarshal(body, &parsed); err != nil {
+		return nil, errors.Wrap(err, "parsing response failed")
+	}
+	if parsed.Response.Status != "ok" {
+		if parsed.Response.Error != nil {
+			return nil, parsed.Response.Error
+		}
+		return nil, errors.New(endpoint + " failed, status " + parsed.Response.Status)
+	}
+	return &parsed, nil
+}
+
+// authQuery adds user and token (md5 of password and random salt) to params
+func (c *SubsonicClient) authQuery(params url.Values) url.Values {
+	salt := newSalt()
+	token := md5.Sum([]byte(c.password + salt))
+	query := url.Values{
+		"u": {c.user},
+		"t": {hex.EncodeToString(token[:])},
+		"s": {salt},
+		"v": {apiVersion},
+		"c": {clientName},
+	}
+	for key, values := range params {
+		query[key] = values
+	}
+	return query
+}
+
+func newSalt() string {
+	salt := make([]byte, 8)
+	_, _ = rand.Read(salt)
+	return hex.EncodeToString(salt)
+}
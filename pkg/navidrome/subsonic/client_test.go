@@ -0,0 +1,341 @@
+package subsonic
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSubsonicClientSearch(t *testing.T) {
+
+	t.Run("Search, returns songs and authenticates with token", func(t *testing.T) {
+		var query url.Values
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/rest/search3", r.URL.Path)
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.NoError(t, r.ParseForm())
+			query = r.Form
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "searchResult3": {"song": [
+				{"id": "Id1", "title": "Title1", "album": "Album1", "artist": "Artist1", "duration": 123, "coverArt": "al-1"}
+			]}}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		songs, err := client.Search(context.Background(), "query", 10)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Song{{Id: "Id1", Title: "Title1", Album: "Album1", Artist: "Artist1", Duration: 123, CoverArt: "al-1"}}, songs)
+		assert.Equal(t, "query", query.Get("query"))
+		assert.Equal(t, "10", query.Get("songCount"))
+		assert.Equal(t, "json", query.Get("f"))
+		assert.Equal(t, "user", query.Get("u"))
+		token := md5.Sum([]byte("password" + query.Get("s")))
+		assert.Equal(t, hex.EncodeToString(token[:]), query.Get("t"))
+	})
+
+	t.Run("Search, returns subsonic error", func(t *testing.T) {
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "failed", "error": {"code": 40, "message": "Wrong username or password"}}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "wrong", time.Second)
+		_, err := client.Search(context.Background(), "query", 10)
+
+		assert.EqualError(t, err, "SubsonicClient.Search failed: subsonic error 40: Wrong username or password")
+	})
+
+	t.Run("Search, returns error on http status", func(t *testing.T) {
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		_, err := client.Search(context.Background(), "query", 10)
+
+		assert.EqualError(t, err, "SubsonicClient.Search failed: search3 failed, status 502")
+	})
+
+}
+
+func TestSubsonicClientPlaylists(t *testing.T) {
+
+	t.Run("CreatePlaylist, creates named playlist", func(t *testing.T) {
+		var form url.Values
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/rest/createPlaylist", r.URL.Path)
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.NoError(t, r.ParseForm())
+			form = r.Form
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "playlist": {"id": "pl-1", "name": "Party", "songCount": 2}}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		playlist, err := client.CreatePlaylist(context.Background(), "", "Party", []string{"Id1", "Id2"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &Playlist{Id: "pl-1", Name: "Party", SongCount: 2}, playlist)
+		assert.Equal(t, "Party", form.Get("name"))
+		assert.Equal(t, "", form.Get("playlistId"))
+		assert.Equal(t, []string{"Id1", "Id2"}, form["songId"])
+	})
+
+	t.Run("CreatePlaylist, replaces songs of existing playlist", func(t *testing.T) {
+		var form url.Values
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.NoError(t, r.ParseForm())
+			form = r.Form
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok"}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		playlist, err := client.CreatePlaylist(context.Background(), "pl-1", "", []string{"Id1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &Playlist{Id: "pl-1", SongCount: 1}, playlist)
+		assert.Equal(t, "pl-1", form.Get("playlistId"))
+		assert.Equal(t, "", form.Get("name"))
+	})
+
+	t.Run("UpdatePlaylist, appends songs", func(t *testing.T) {
+		var form url.Values
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/rest/updatePlaylist", r.URL.Path)
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.NoError(t, r.ParseForm())
+			form = r.Form
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "failed", "error": {"code": 70, "message": "Playlist not found"}}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		err := client.UpdatePlaylist(context.Background(), "pl-2", []string{"Id1", "Id2"})
+
+		assert.EqualError(t, err, "SubsonicClient.UpdatePlaylist failed: subsonic error 70: Playlist not found")
+		assert.Equal(t, "pl-2", form.Get("playlistId"))
+		assert.Equal(t, []string{"Id1", "Id2"}, form["songIdToAdd"])
+	})
+
+	t.Run("CreatePlaylist, form posts song ids that don't fit into URL", func(t *testing.T) {
+		songIds := make([]string, 500)
+		for i := range songIds {
+			songIds[i] = "song-id-" + strconv.Itoa(i)
+		}
+		var form url.Values
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "", r.URL.RawQuery)
+			assert.NoError(t, r.ParseForm())
+			form = r.PostForm
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok"}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		_, err := client.CreatePlaylist(context.Background(), "", "Friday", songIds)
+
+		assert.NoError(t, err)
+		assert.Equal(t, songIds, form["songId"])
+		assert.Equal(t, "user", form.Get("u"))
+	})
+
+}
+
+func TestSubsonicClientPlayQueue(t *testing.T) {
+
+	t.Run("GetPlayQueue, returns saved queue", func(t *testing.T) {
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/rest/getPlayQueue", r.URL.Path)
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok", "playQueue": {
+				"entry": [{"id": "Id1", "title": "Title1"}, {"id": "Id2", "title": "Title2"}],
+				"current": "Id2", "position": 12345, "changedBy": "DSub"
+			}}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		playQueue, err := client.GetPlayQueue(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, &PlayQueue{Entry: []Song{{Id: "Id1", Title: "Title1"}, {Id: "Id2", Title: "Title2"}}, Current: "Id2", Position: 12345}, playQueue)
+	})
+
+	t.Run("GetPlayQueue, returns nil without saved queue", func(t *testing.T) {
+		for _, body := range []string{
+			`{"subsonic-response": {"status": "ok"}}`,
+			`{"subsonic-response": {"status": "failed", "error": {"code": 70, "message": "Not found"}}}`,
+		} {
+			navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(body))
+			}))
+
+			client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+			playQueue, err := client.GetPlayQueue(context.Background())
+
+			assert.NoError(t, err)
+			assert.Nil(t, playQueue)
+			navidrome.Close()
+		}
+	})
+
+	t.Run("SavePlayQueue, saves songs with current and position", func(t *testing.T) {
+		var form url.Values
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/rest/savePlayQueue", r.URL.Path)
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.NoError(t, r.ParseForm())
+			form = r.Form
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok"}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		err := client.SavePlayQueue(context.Background(), []string{"Id1", "Id2"}, "Id2", 12345)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Id1", "Id2"}, form["id"])
+		assert.Equal(t, "Id2", form.Get("current"))
+		assert.Equal(t, "12345", form.Get("position"))
+	})
+
+}
+
+func TestSubsonicClientPing(t *testing.T) {
+
+	t.Run("Ping, authenticates with given user credentials", func(t *testing.T) {
+		var query url.Values
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/rest/ping.view", r.URL.Path)
+			query = r.URL.Query()
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "ok"}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		err := client.Ping(context.Background(), "other", "token", "salt")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "other", query.Get("u"))
+		assert.Equal(t, "token", query.Get("t"))
+		assert.Equal(t, "salt", query.Get("s"))
+	})
+
+	t.Run("Ping, returns subsonic error for wrong credentials", func(t *testing.T) {
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "failed", "error": {"code": 40, "message": "Wrong username or password"}}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		err := client.Ping(context.Background(), "other", "token", "salt")
+
+		var subsonicErr *Error
+		assert.True(t, errors.As(err, &subsonicErr))
+		assert.Equal(t, 40, subsonicErr.Code)
+	})
+
+}
+
+func TestSubsonicClientCoverArt(t *testing.T) {
+
+	t.Run("CoverArt, fetches image with configured user credentials", func(t *testing.T) {
+		var query url.Values
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/rest/getCoverArt", r.URL.Path)
+			query = r.URL.Query()
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write([]byte("image"))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		image, contentType, err := client.CoverArt(context.Background(), "al-1", 300)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("image"), image)
+		assert.Equal(t, "image/jpeg", contentType)
+		assert.Equal(t, "al-1", query.Get("id"))
+		assert.Equal(t, "300", query.Get("size"))
+		assert.Equal(t, "user", query.Get("u"))
+		token := md5.Sum([]byte("password" + query.Get("s")))
+		assert.Equal(t, hex.EncodeToString(token[:]), query.Get("t"))
+	})
+
+	t.Run("CoverArt, returns subsonic error", func(t *testing.T) {
+		navidrome := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"subsonic-response": {"status": "failed", "error": {"code": 70, "message": "Artwork not found"}}}`))
+		}))
+		defer navidrome.Close()
+
+		client := NewSubsonicClient(navidrome.URL, "user", "password", time.Second)
+		_, _, err := client.CoverArt(context.Background(), "al-1", 300)
+
+		assert.EqualError(t, err, "SubsonicClient.CoverArt failed: subsonic error 70: Artwork not found")
+	})
+
+}
+
+func TestSubsonicClientPaths(t *testing.T) {
+	client := NewSubsonicClient("http://navidrome", "user", "password", time.Second)
+
+	t.Run("StreamPath, is relative without credentials", func(t *testing.T) {
+		assert.Equal(t, "/rest/stream?id=Id1", client.StreamPath("Id1"))
+	})
+
+	t.Run("CoverPath, is relative without credentials", func(t *testing.T) {
+		assert.Equal(t, "/rest/getCoverArt?id=al-1&size=300", client.CoverPath("al-1"))
+	})
+
+	t.Run("CoverPath, is empty without cover art", func(t *testing.T) {
+		assert.Equal(t, "", client.CoverPath(""))
+	})
+}
+
+func TestSubsonicClientAuthenticate(t *testing.T) {
+	client := NewSubsonicClient("http://navidrome", "user", "password", time.Second)
+
+	t.Run("Authenticate, adds token credentials to path", func(t *testing.T) {
+		path, err := url.Parse(client.Authenticate("/rest/stream?id=Id1"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/rest/stream", path.Path)
+		assert.Equal(t, "Id1", path.Query().Get("id"))
+		assert.Equal(t, "user", path.Query().Get("u"))
+		salt := path.Query().Get("s")
+		token := md5.Sum([]byte("password" + salt))
+		assert.NotEmpty(t, salt)
+		assert.Equal(t, hex.EncodeToString(token[:]), path.Query().Get("t"))
+	})
+
+	t.Run("Authenticate, keeps path that has credentials", func(t *testing.T) {
+		assert.Equal(t, "/rest/stream?id=Id1&u=other&t=t&s=s", client.Authenticate("/rest/stream?id=Id1&u=other&t=t&s=s"))
+	})
+
+	t.Run("Authenticate, keeps absolute URL", func(t *testing.T) {
+		assert.Equal(t, "https://radio.example.com/stream", client.Authenticate("https://radio.example.com/stream"))
+	})
+
+	t.Run("StripCredentials, removes credentials from path", func(t *testing.T) {
+		assert.Equal(t, "/rest/stream?c=NavidromeUI&id=Id1", StripCredentials("/rest/stream?id=Id1&u=admin&t=token&s=salt&c=NavidromeUI"))
+		assert.Equal(t, "/rest/stream?id=Id1", StripCredentials("/rest/stream?id=Id1&u=admin&p=enc:123"))
+	})
+
+	t.Run("StripCredentials, keeps path without credentials and absolute URL", func(t *testing.T) {
+		assert.Equal(t, "/rest/stream?id=Id1", StripCredentials("/rest/stream?id=Id1"))
+		assert.Equal(t, "https://radio.example.com/stream?u=user", StripCredentials("https://radio.example.com/stream?u=user"))
+	})
+}
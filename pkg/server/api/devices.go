@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	alexaClient "github.com/ahimgit/navidrome-alexa/pkg/alexa/client"
+	alexaModel "github.com/ahimgit/navidrome-alexa/pkg/alexa/client/model"
+	"sync"
+	"time"
+)
+
+// DeviceCache keeps the last devices list from Alexa for a short time,
+// so device lookups for every player command don't hit Amazon APIs.
+// Concurrent lookups of a stale list share one fetch, lock is not held while it runs
+type DeviceCache struct {
+	AlexaClient alexaClient.IAlexaClient
+	TTL         time.Duration
+	devices     alexaModel.DevicesResponse
+	updatedAt   time.Time
+	fetching    *devicesFetch // in progress fetch, nil if none
+	mutex       sync.Mutex
+}
+
+type devicesFetch struct {
+	done    chan struct{} // closed when devices and err are set
+	devices alexaModel.DevicesResponse
+	err     error
+}
+
+func NewDeviceCache(alexaClient alexaClient.IAlexaClient, ttl time.Duration) *DeviceCache {
+	return &DeviceCache{
+		AlexaClient: alexaClient,
+		TTL:         ttl,
+	}
+}
+
+func (cache *DeviceCache) GetDevices(ctx context.Context) (devices alexaModel.DevicesResponse, err error) {
+	cache.mutex.Lock()
+	if !cache.updatedAt.IsZero() && time.Since(cache.updatedAt) < cache.TTL {
+		defer cache.mutex.Unlock()
+		return cache.devices, nil
+	}
+	fetch := cache.fetching
+	if fetch != nil { // wait for fetch started by another lookup
+		cache.mutex.Unlock()
+		select {
+		case <-fetch.done:
+			return fetch.devices, fetch.err
+		case <-ctx.Done():
+			return devices, ctx.Err()
+		}
+	}
+	fetch = &devicesFetch{done: make(chan struct{})}
+	cache.fetching = fetch
+	cache.mutex.Unlock()
+
+	fetch.devices, fetch.err = cache.AlexaClient.GetDevices(ctx)
+	cache.mutex.Lock()
+	if fetch.err == nil {
+		cache.devices = fetch.devices
+		cache.updatedAt = time.Now()
+	}
+	cache.fetching = nil
+	cache.mutex.Unlock()
+	close(fetch.done)
+	return fetch.devices, fetch.err
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeviceCache(t *testing.T) {
+
+	t.Run("GetDevices, concurrent lookups share one fetch", func(t *testing.T) {
+		release := make(chan time.Time)
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").WaitUntil(release).Return(commandDevices(), noError())
+		cache := NewDeviceCache(mockAlexaClient, time.Minute)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				devices, err := cache.GetDevices(context.Background())
+				assert.NoError(t, err)
+				assert.Equal(t, commandDevices(), devices)
+			}()
+		}
+		assert.Eventually(t, cache.isFetching, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond) // let the others wait for the fetch
+		close(release)
+		wg.Wait()
+
+		mockAlexaClient.AssertNumberOfCalls(t, "GetDevices", 1)
+	})
+
+	t.Run("GetDevices, waiting lookup is not blocked past its context", func(t *testing.T) {
+		release := make(chan time.Time)
+		defer close(release)
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").WaitUntil(release).Return(commandDevices(), noError())
+		cache := NewDeviceCache(mockAlexaClient, time.Minute)
+		go func() { _, _ = cache.GetDevices(context.Background()) }()
+		assert.Eventually(t, cache.isFetching, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := cache.GetDevices(ctx)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("GetDevices, failed fetch is not cached", func(t *testing.T) {
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(model.DevicesResponse{}, errors.New("mock error")).Once()
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		cache := NewDeviceCache(mockAlexaClient, time.Minute)
+
+		_, err := cache.GetDevices(context.Background())
+		assert.EqualError(t, err, "mock error")
+		devices, err := cache.GetDevices(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, commandDevices(), devices)
+		_, _ = cache.GetDevices(context.Background())
+
+		mockAlexaClient.AssertNumberOfCalls(t, "GetDevices", 2)
+	})
+
+}
+
+func (cache *DeviceCache) isFetching() bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.fetching != nil
+}
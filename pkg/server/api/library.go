@@ -0,0 +1,76 @@
+package api
+
+import (
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/subsonic"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+const searchCount = 50
+const coverSize = 300
+
+// LibraryAPI searches Navidrome library to build queues without Navidrome UI, Subsonic is nil if Navidrome access is not configured
+type LibraryAPI struct {
+	Subsonic subsonic.ISubsonicClient
+}
+
+func NewLibraryAPI(subsonicClient subsonic.ISubsonicClient) *LibraryAPI {
+	return &LibraryAPI{Subsonic: subsonicClient}
+}
+
+func (api *LibraryAPI) GetLibrary(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "success", "enabled": api.Subsonic != nil})
+}
+
+func (api *LibraryAPI) GetSearch(c *gin.Context) {
+	if api.Subsonic == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "message": "Navidrome access is not configured"})
+		return
+	}
+	query := c.Query("query")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "query is required"})
+		return
+	}
+	songs, err := api.Subsonic.Search(c.Request.Context(), query, searchCount)
+	if err != nil {
+		log.GetRequestContextLogger(c).Error("GetSearch failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "songs": mapSongs(api.Subsonic, songs)})
+}
+
+// GetCover serves Navidrome cover art, clients get it without Navidrome credentials
+func (api *LibraryAPI) GetCover(c *gin.Context) {
+	if api.Subsonic == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "message": "Navidrome access is not configured"})
+		return
+	}
+	image, contentType, err := api.Subsonic.CoverArt(c.Request.Context(), c.Param("id"), coverSize)
+	if err != nil {
+		log.GetRequestContextLogger(c).Error("GetCover failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, contentType, image)
+}
+
+func mapSongs(subsonicClient subsonic.ISubsonicClient, songs []subsonic.Song) []model.Song {
+	mapped := make([]model.Song, len(songs))
+	for i, song := range songs {
+		mapped[i] = model.Song{
+			Id:       song.Id,
+			Name:     song.Title,
+			Album:    song.Album,
+			Artist:   song.Artist,
+			Duration: song.Duration * 1000,
+			Cover:    subsonicClient.CoverPath(song.CoverArt),
+			Stream:   subsonicClient.StreamPath(song.Id),
+		}
+	}
+	return mapped
+}
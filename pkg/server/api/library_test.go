@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/subsonic"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tests"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLibraryAPIGetLibrary(t *testing.T) {
+
+	t.Run("GetLibrary, enabled with Navidrome access", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/"))
+
+		NewLibraryAPI(&MockSubsonicClient{}).GetLibrary(mockGinContext)
+
+		assert.JSONEq(t, `{"status": "success", "enabled": true}`, responseRecorder.Body.String())
+	})
+
+	t.Run("GetLibrary, disabled without Navidrome access", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/"))
+
+		NewLibraryAPI(nil).GetLibrary(mockGinContext)
+
+		assert.JSONEq(t, `{"status": "success", "enabled": false}`, responseRecorder.Body.String())
+	})
+
+}
+
+func TestLibraryAPIGetSearch(t *testing.T) {
+
+	t.Run("GetSearch, maps songs to queue songs", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/?query=Title"))
+		mockSubsonicClient := &MockSubsonicClient{songs: []subsonic.Song{
+			{Id: "Id1", Title: "Title1", Album: "Album1", Artist: "Artist1", Duration: 123, CoverArt: "al-1"},
+			{Id: "Id2", Title: "Title2", Album: "Album2", Artist: "Artist2", Duration: 10},
+		}}
+
+		NewLibraryAPI(mockSubsonicClient).GetSearch(mockGinContext)
+
+		assert.Equal(t, "Title", mockSubsonicClient.query)
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "success", "songs": [
+			{"id": "Id1", "name": "Title1", "album": "Album1", "artist": "Artist1", "duration": 123000, "cover": "/cover/al-1", "stream": "/stream/Id1"},
+			{"id": "Id2", "name": "Title2", "album": "Album2", "artist": "Artist2", "duration": 10000, "cover": "", "stream": "/stream/Id2"}
+		]}`, responseRecorder.Body.String())
+	})
+
+	t.Run("GetSearch, requires query", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/"))
+
+		NewLibraryAPI(&MockSubsonicClient{}).GetSearch(mockGinContext)
+
+		assert.Equal(t, 400, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "error", "message": "query is required"}`, responseRecorder.Body.String())
+	})
+
+	t.Run("GetSearch, unavailable without Navidrome access", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/?query=Title"))
+
+		NewLibraryAPI(nil).GetSearch(mockGinContext)
+
+		assert.Equal(t, 503, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "error", "message": "Navidrome access is not configured"}`, responseRecorder.Body.String())
+	})
+
+	t.Run("GetSearch, returns search error", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/?query=Title"))
+
+		NewLibraryAPI(&MockSubsonicClient{err: errors.New("search failed")}).GetSearch(mockGinContext)
+
+		assert.Equal(t, 500, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "error", "message": "search failed"}`, responseRecorder.Body.String())
+	})
+
+}
+
+func TestLibraryAPIGetCover(t *testing.T) {
+
+	t.Run("GetCover, serves cover art", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/"))
+		mockGinContext.Params = gin.Params{{Key: "id", Value: "al-1"}}
+		mockSubsonicClient := &MockSubsonicClient{}
+
+		NewLibraryAPI(mockSubsonicClient).GetCover(mockGinContext)
+
+		assert.Equal(t, "al-1:300", mockSubsonicClient.query)
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Equal(t, "image/jpeg", responseRecorder.Header().Get("Content-Type"))
+		assert.Equal(t, "image", responseRecorder.Body.String())
+	})
+
+	t.Run("GetCover, unavailable without Navidrome access", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/"))
+
+		NewLibraryAPI(nil).GetCover(mockGinContext)
+
+		assert.Equal(t, 503, responseRecorder.Code)
+	})
+
+	t.Run("GetCover, returns cover error", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/"))
+		mockGinContext.Params = gin.Params{{Key: "id", Value: "al-1"}}
+
+		NewLibraryAPI(&MockSubsonicClient{err: errors.New("cover failed")}).GetCover(mockGinContext)
+
+		assert.Equal(t, 500, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "error", "message": "cover failed"}`, responseRecorder.Body.String())
+	})
+
+}
+
+type MockSubsonicClient struct {
+	mu        sync.Mutex
+	query     string
+	songs     []subsonic.Song
+	results   map[string][]subsonic.Song // songs by query, if set
+	playlist  *subsonic.Playlist
+	playQueue *subsonic.PlayQueue
+	calls     []string // playlist calls as method:playlistId:name:songIds
+	err       error
+}
+
+func (m *MockSubsonicClient) Search(_ context.Context, query string, _ int) ([]subsonic.Song, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.query = query
+	if m.results != nil {
+		return m.results[query], m.err
+	}
+	return m.songs, m.err
+}
+
+func (m *MockSubsonicClient) CreatePlaylist(_ context.Context, playlistId string, name string, songIds []string) (*subsonic.Playlist, error) {
+	m.calls = append(m.calls, "CreatePlaylist:"+playlistId+":"+name+":"+strings.Join(songIds, ","))
+	return m.playlist, m.err
+}
+
+func (m *MockSubsonicClient) UpdatePlaylist(_ context.Context, playlistId string, songIds []string) error {
+	m.calls = append(m.calls, "UpdatePlaylist:"+playlistId+"::"+strings.Join(songIds, ","))
+	return m.err
+}
+
+func (m *MockSubsonicClient) GetPlayQueue(_ context.Context) (*subsonic.PlayQueue, error) {
+	return m.playQueue, m.err
+}
+
+func (m *MockSubsonicClient) SavePlayQueue(_ context.Context, songIds []string, current string, position int) error {
+	m.calls = append(m.calls, "SavePlayQueue:"+strings.Join(songIds, ",")+":"+current+":"+strconv.Itoa(position))
+	return m.err
+}
+
+func (m *MockSubsonicClient) Ping(_ context.Context, _ string, _ string, _ string) error {
+	return m.err
+}
+
+func (m *MockSubsonicClient) CoverArt(_ context.Context, id string, size int) ([]byte, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.query = id + ":" + strconv.Itoa(size)
+	return []byte("image"), "image/jpeg", m.err
+}
+
+func (m *MockSubsonicClient) StreamPath(id string) string {
+	return "/stream/" + id
+}
+
+func (m *MockSubsonicClient) CoverPath(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "/cover/" + id
+}
+
+func (m *MockSubsonicClient) Authenticate(path string) string {
+	return path + "&u=user"
+}
+
+type MockPlayQueueSync struct {
+	changed int
+}
+
+func (m *MockPlayQueueSync) Changed() {
+	m.changed++
+}
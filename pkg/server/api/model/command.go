@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+type commandStatus string
+
+const (
+	CommandStatusQueued    commandStatus = "QUEUED"
+	CommandStatusInFlight  commandStatus = "IN_FLIGHT"
+	CommandStatusCompleted commandStatus = "COMPLETED"
+	CommandStatusFailed    commandStatus = "FAILED"
+)
+
+type CommandStatus struct {
+	ID                 string        `json:"id"`
+	DeviceSerialNumber string        `json:"deviceSerialNumber"`
+	Command            string        `json:"command"`
+	Count              int           `json:"count"` // number of requests coalesced into this command
+	Status             commandStatus `json:"status"`
+	Error              string        `json:"error,omitempty"`
+	QueuedAt           time.Time     `json:"queuedAt"`
+	StartedAt          *time.Time    `json:"startedAt,omitempty"`
+	CompletedAt        *time.Time    `json:"completedAt,omitempty"`
+}
+
+type CommandsResponse struct {
+	Commands []CommandStatus `json:"commands"`
+}
+
+func (s *CommandStatus) IsDone() bool {
+	return s.Status == CommandStatusCompleted || s.Status == CommandStatusFailed
+}
@@ -5,8 +5,30 @@ type DevicesResponse struct {
 }
 
 type PlayerDevice struct {
-	Name                  string `json:"name,omitempty"`
-	DeviceOwnerCustomerId string `json:"deviceOwnerCustomerId"`
-	DeviceType            string `json:"deviceType"`
-	SerialNumber          string `json:"serialNumber"`
+	Name                   string         `json:"name,omitempty"`
+	DeviceOwnerCustomerId  string         `json:"deviceOwnerCustomerId"`
+	DeviceType             string         `json:"deviceType"`
+	SerialNumber           string         `json:"serialNumber"`
+	Members                []PlayerDevice `json:"members,omitempty"` // set for multiroom groups, commands are fanned out to members
+	Online                 bool           `json:"online"`
+	AudioPlayer            bool           `json:"audioPlayer"` // can play skill audio, group is an audio player if it has any members
+	DeviceFamily           string         `json:"deviceFamily,omitempty"`
+	DeviceTypeFriendlyName string         `json:"deviceTypeFriendlyName,omitempty"`
+	SoftwareVersion        string         `json:"softwareVersion,omitempty"`
+	Language               string         `json:"language,omitempty"`
+	BatteryLevel           *int           `json:"batteryLevel,omitempty"`
+	Charging               *bool          `json:"charging,omitempty"`
+}
+
+func (d *PlayerDevice) IsGroup() bool {
+	return len(d.Members) > 0
+}
+
+func (r *DevicesResponse) FindBySerialNumber(serialNumber string) *PlayerDevice {
+	for i := range r.Devices {
+		if r.Devices[i].SerialNumber == serialNumber {
+			return &r.Devices[i]
+		}
+	}
+	return nil
 }
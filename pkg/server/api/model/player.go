@@ -0,0 +1,16 @@
+package model
+
+type PlayerStateResponse struct {
+	DeviceSerialNumber string     `json:"deviceSerialNumber"`
+	State              string     `json:"state"`       // as reported by device: PLAYING/PAUSED/IDLE
+	OwnPlayback        *bool      `json:"ownPlayback"` // device is playing from our skill, null if provider can't be told
+	Provider           string     `json:"provider,omitempty"`
+	Title              string     `json:"title,omitempty"`
+	Artist             string     `json:"artist,omitempty"`
+	Album              string     `json:"album,omitempty"` // as displayed by alexa, may include artist
+	Progress           int        `json:"progress"`        // ms
+	Duration           int        `json:"duration"`        // ms
+	Volume             *int       `json:"volume,omitempty"`
+	Muted              bool       `json:"muted"`
+	QueueState         queueState `json:"queueState"` // queue state as last reported by skill callbacks
+}
@@ -1,5 +1,12 @@
 package model
 
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
 type Song struct {
 	Id       string `json:"id"`
 	Name     string `json:"name"`
@@ -10,6 +17,31 @@ type Song struct {
 	Stream   string `json:"stream"`
 }
 
+// External song streams from its own absolute URL (imported stream URL) instead of Navidrome on streamDomain
+func (s *Song) External() bool {
+	return isAbsolute(s.Stream)
+}
+
+// StreamURL is Stream relative to streamDomain, or Stream itself if it is absolute
+func (s *Song) StreamURL(streamDomain string) string {
+	if s.External() {
+		return s.Stream
+	}
+	return streamDomain + s.Stream
+}
+
+// CoverURL is Cover relative to streamDomain, or Cover itself if it is absolute or empty
+func (s *Song) CoverURL(streamDomain string) string {
+	if s.Cover == "" || isAbsolute(s.Cover) {
+		return s.Cover
+	}
+	return streamDomain + s.Cover
+}
+
+func isAbsolute(link string) bool {
+	return strings.HasPrefix(link, "https://") || strings.HasPrefix(link, "http://")
+}
+
 type queueState string
 
 const (
@@ -24,6 +56,18 @@ type Queue struct {
 	Songs         []Song     `json:"queue"`
 	Shuffle       bool       `json:"shuffle"`
 	Repeat        bool       `json:"repeat"`
+
+	leader          string       // skill device id whose callbacks move the queue, first device to start playing
+	playRequestedAt atomic.Int64 // unix nanos of last REST play command, taken by skill on playback start
+	mutex           sync.Mutex   // held while reading or changing fields above: by API handlers, skill handler, command scheduler and queue metrics
+}
+
+func (q *Queue) Lock() {
+	q.mutex.Lock()
+}
+
+func (q *Queue) Unlock() {
+	q.mutex.Unlock()
 }
 
 func NewQueue() *Queue {
@@ -51,6 +95,7 @@ func (q *Queue) HasPrev() bool {
 func (q *Queue) Prev() *Song {
 	if q.HasPrev() {
 		q.QueuePosition--
+		q.TrackPosition = 0 // new track plays from start
 		return q.Current()
 	}
 	return nil
@@ -59,6 +104,7 @@ func (q *Queue) Prev() *Song {
 func (q *Queue) Next() *Song {
 	if q.HasNext() {
 		q.QueuePosition++
+		q.TrackPosition = 0
 		return q.Current()
 	}
 	return nil
@@ -74,3 +120,35 @@ func (q *Queue) PeekNext() *Song {
 func (q *Queue) Current() *Song {
 	return &q.Songs[q.QueuePosition]
 }
+
+// IsFollower reports whether device plays along with another device leading the queue (multiroom group member),
+// its callbacks must not move the queue or every member would advance it
+func (q *Queue) IsFollower(device string) bool {
+	return q.leader != "" && q.leader != device
+}
+
+// Lead makes device the leader if there is none
+func (q *Queue) Lead(device string) {
+	if q.leader == "" {
+		q.leader = device
+	}
+}
+
+// ReleaseLeader lets the next device to start playing lead, when play is requested or leader stops
+func (q *Queue) ReleaseLeader() {
+	q.leader = ""
+}
+
+// MarkPlayRequested remembers when play was requested to measure time until Alexa reports playback started
+func (q *Queue) MarkPlayRequested(at time.Time) {
+	q.playRequestedAt.Store(at.UnixNano())
+}
+
+// TakePlayRequested returns and clears pending play request time, so it is matched to one playback start only
+func (q *Queue) TakePlayRequested() (at time.Time, ok bool) {
+	requestedAt := q.playRequestedAt.Swap(0)
+	if requestedAt == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, requestedAt), true
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestQueueConstructor(t *testing.T) {
@@ -43,6 +44,36 @@ func TestQueueNavigation(t *testing.T) {
 	assert.Nil(t, queue.PeekNext())
 	assert.Nil(t, queue.Next()) // no more elements
 
+	queue.TrackPosition = 123
 	assert.Equal(t, &queue.Songs[1], queue.Prev()) // go back one element
 	assert.Equal(t, &queue.Songs[1], queue.Current())
+	assert.Equal(t, 0, queue.TrackPosition) // from start
+}
+
+func TestQueuePlayRequested(t *testing.T) {
+	queue := NewQueue()
+	_, ok := queue.TakePlayRequested()
+	assert.False(t, ok)
+
+	requestedAt := time.Now()
+	queue.MarkPlayRequested(requestedAt)
+	actualRequestedAt, ok := queue.TakePlayRequested()
+	assert.True(t, ok)
+	assert.True(t, requestedAt.Equal(actualRequestedAt))
+
+	_, ok = queue.TakePlayRequested() // matched once only
+	assert.False(t, ok)
+}
+
+func TestSongStreamURL(t *testing.T) {
+	assert.Equal(t, "https://music.example.com/rest/stream?id=1", (&Song{Stream: "/rest/stream?id=1"}).StreamURL("https://music.example.com"))
+	assert.Equal(t, "https://radio.example.com/stream", (&Song{Stream: "https://radio.example.com/stream"}).StreamURL("https://music.example.com"))
+	assert.True(t, (&Song{Stream: "https://radio.example.com/stream"}).External())
+	assert.False(t, (&Song{Stream: "/rest/stream?id=1"}).External())
+}
+
+func TestSongCoverURL(t *testing.T) {
+	assert.Equal(t, "https://music.example.com/rest/getCoverArt?id=1", (&Song{Cover: "/rest/getCoverArt?id=1"}).CoverURL("https://music.example.com"))
+	assert.Equal(t, "https://radio.example.com/cover.jpg", (&Song{Cover: "https://radio.example.com/cover.jpg"}).CoverURL("https://music.example.com"))
+	assert.Equal(t, "", (&Song{}).CoverURL("https://music.example.com"))
 }
@@ -6,7 +6,8 @@ type VolumeResponse struct {
 
 type VolumeRequest struct {
 	Device PlayerDevice `json:"device"`
-	Volume int          `json:"volume"`
+	Volume *int         `json:"volume,omitempty"` // absolute volume 0-100
+	Step   *int         `json:"step,omitempty"`   // relative change -100..100, applied to current device volume
 }
 
 type DeviceVolume struct {
@@ -14,3 +15,12 @@ type DeviceVolume struct {
 	Muted              bool   `json:"muted"`
 	Volume             int    `json:"volume"`
 }
+
+func (r *VolumeResponse) FindByDeviceSerialNumber(serialNumber string) *DeviceVolume {
+	for i := range r.Volumes {
+		if r.Volumes[i].DeviceSerialNumber == serialNumber {
+			return &r.Volumes[i]
+		}
+	}
+	return nil
+}
@@ -1,24 +1,58 @@
 package api
 
 import (
+	"context"
 	alexaClient "github.com/ahimgit/navidrome-alexa/pkg/alexa/client"
 	alexaModel "github.com/ahimgit/navidrome-alexa/pkg/alexa/client/model"
 	apiModel "github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
 	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type PlayerAPI struct {
-	SkillName   string
-	AlexaClient alexaClient.IAlexaClient
+	skillName    atomic.Pointer[string] // swapped on config reload
+	displayName  atomic.Pointer[string] // skill name Alexa reports as provider, empty if not configured
+	AlexaClient  alexaClient.IAlexaClient
+	DeviceCache  *DeviceCache
+	Scheduler    *CommandScheduler
+	Queue        *apiModel.Queue
+	CommandLinks *tracing.CommandLinks // optional, links skill requests to commands that caused them
 }
 
-func NewPlayerAPI(alexaClient alexaClient.IAlexaClient, skillName string) *PlayerAPI {
-	return &PlayerAPI{
-		SkillName:   skillName,
+func NewPlayerAPI(alexaClient alexaClient.IAlexaClient, queue *apiModel.Queue, skillName string) *PlayerAPI {
+	playerAPI := &PlayerAPI{
 		AlexaClient: alexaClient,
+		Queue:       queue,
+		DeviceCache: NewDeviceCache(alexaClient, time.Minute),
+		Scheduler:   NewCommandScheduler(500*time.Millisecond, 100),
 	}
+	playerAPI.SetSkillName(skillName)
+	playerAPI.SetSkillDisplayName("")
+	return playerAPI
+}
+
+func (playerAPI *PlayerAPI) SkillName() string {
+	return *playerAPI.skillName.Load()
+}
+
+func (playerAPI *PlayerAPI) SetSkillName(skillName string) {
+	playerAPI.skillName.Store(&skillName)
+}
+
+func (playerAPI *PlayerAPI) SkillDisplayName() string {
+	return *playerAPI.displayName.Load()
+}
+
+func (playerAPI *PlayerAPI) SetSkillDisplayName(displayName string) {
+	playerAPI.displayName.Store(&displayName)
 }
 
 func (playerAPI *PlayerAPI) PostPlay(c *gin.Context) {
@@ -38,28 +72,136 @@ func (playerAPI *PlayerAPI) PostPrev(c *gin.Context) {
 }
 
 func (playerAPI *PlayerAPI) GetDevices(c *gin.Context) {
-	devices, err := playerAPI.AlexaClient.GetDevices()
+	devices, ok := playerAPI.getDevices(c)
+	if !ok {
+		return
+	}
+	audioOnly := queryBool(c, "audioOnly", true)
+	onlineOnly := queryBool(c, "onlineOnly", false)
+	c.JSON(http.StatusOK, filterDevices(devices, audioOnly, onlineOnly))
+}
+
+func (playerAPI *PlayerAPI) GetDevice(c *gin.Context) {
+	devices, ok := playerAPI.getDevices(c)
+	if !ok {
+		return
+	}
+	serialNumber := c.Param("serial")
+	device := devices.FindBySerialNumber(serialNumber)
+	if device == nil {
+		log.GetRequestContextLogger(c).Warn("GetDevice, device not found", "serialNumber", serialNumber)
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "Device not found"})
+		return
+	}
+	c.JSON(http.StatusOK, device)
+}
+
+// GetPlayerState reads what the device is actually doing, skill callbacks are not sent
+// when playback is interrupted by alexa itself (timers, other music). Queue state is returned as is, reading never changes it
+func (playerAPI *PlayerAPI) GetPlayerState(c *gin.Context) {
+	devices, ok := playerAPI.getDevices(c)
+	if !ok {
+		return
+	}
+	serialNumber := c.Param("serial")
+	device := devices.FindBySerialNumber(serialNumber)
+	if device == nil {
+		log.GetRequestContextLogger(c).Warn("GetPlayerState, device not found", "serialNumber", serialNumber)
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "Device not found"})
+		return
+	}
+	if device.IsGroup() {
+		log.GetRequestContextLogger(c).Warn("GetPlayerState, device is a group", "serialNumber", serialNumber)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Device " + device.Name + " is a group, query its members instead"})
+		return
+	}
+	playerState, err := playerAPI.AlexaClient.GetPlayerState(log.CreateLoggerContext(c), toDeviceTarget(*device))
 	if err != nil {
-		log.GetRequestContextLogger(c).Error("GetDevices failed", "error", err)
+		log.GetRequestContextLogger(c).Error("GetPlayerState failed", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
-	if len(devices.Devices) == 0 {
+	response := mapPlayerStateResponse(serialNumber, playerState, playerAPI.SkillName(), playerAPI.SkillDisplayName())
+	playerAPI.Queue.Lock()
+	response.QueueState = playerAPI.Queue.State
+	playerAPI.Queue.Unlock()
+	c.JSON(http.StatusOK, response)
+}
+
+func (playerAPI *PlayerAPI) getDevices(c *gin.Context) (devices apiModel.DevicesResponse, ok bool) {
+	alexaDevices, err := playerAPI.DeviceCache.GetDevices(log.CreateLoggerContext(c))
+	if err != nil {
+		log.GetRequestContextLogger(c).Error("GetDevices failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return devices, false
+	}
+	if len(alexaDevices.Devices) == 0 {
 		log.GetRequestContextLogger(c).Warn("GetDevices, no devices on the account")
 		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "No devices on the account"})
-		return
+		return devices, false
+	}
+	return mapDevicesResponse(alexaDevices), true
+}
+
+// resolveDevice looks up the requested device to reject commands to offline or non-audio devices,
+// for multiroom groups only online members are kept
+func (playerAPI *PlayerAPI) resolveDevice(c *gin.Context, requested apiModel.PlayerDevice) (device apiModel.PlayerDevice, ok bool) {
+	alexaDevices, err := playerAPI.DeviceCache.GetDevices(log.CreateLoggerContext(c))
+	if err != nil { // don't block commands if we can't check, alexa will reject them if device is gone
+		log.GetRequestContextLogger(c).Warn("Unable to check device status, sending command as is", "error", err)
+		return requested, true
+	}
+	devices := mapDevicesResponse(alexaDevices)
+	found := devices.FindBySerialNumber(requested.SerialNumber)
+	if found == nil {
+		log.GetRequestContextLogger(c).Error("Device not found", "serialNumber", requested.SerialNumber)
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "Device not found"})
+		return device, false
+	}
+	device = *found
+	if device.IsGroup() {
+		onlineMembers := make([]apiModel.PlayerDevice, 0, len(device.Members))
+		for _, member := range device.Members {
+			if member.Online {
+				onlineMembers = append(onlineMembers, member)
+			}
+		}
+		device.Members = onlineMembers
+		device.Online = len(onlineMembers) > 0
+	}
+	if !device.AudioPlayer {
+		log.GetRequestContextLogger(c).Error("Device can't play audio", "serialNumber", device.SerialNumber, "name", device.Name)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Device " + device.Name + " can't play audio"})
+		return device, false
 	}
-	c.JSON(http.StatusOK, mapDevicesResponse(devices))
+	if !device.Online {
+		log.GetRequestContextLogger(c).Error("Device is offline", "serialNumber", device.SerialNumber, "name", device.Name)
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "message": "Device " + device.Name + " is offline"})
+		return device, false
+	}
+	return device, true
 }
 
+// GetVolume returns volumes of all devices, or of a single one if serial query param is set
 func (playerAPI *PlayerAPI) GetVolume(c *gin.Context) {
-	volume, err := playerAPI.AlexaClient.GetVolume()
+	volume, err := playerAPI.AlexaClient.GetVolume(log.CreateLoggerContext(c))
 	if err != nil {
 		log.GetRequestContextLogger(c).Error("GetVolume failed", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, mapVolumeResponse(volume))
+	volumes := mapVolumeResponse(volume)
+	if serialNumber := c.Query("serial"); serialNumber != "" {
+		deviceVolume := volumes.FindByDeviceSerialNumber(serialNumber)
+		if deviceVolume == nil {
+			log.GetRequestContextLogger(c).Warn("GetVolume, device not found", "serialNumber", serialNumber)
+			c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "Device volume not found"})
+			return
+		}
+		c.JSON(http.StatusOK, deviceVolume)
+		return
+	}
+	c.JSON(http.StatusOK, volumes)
 }
 
 func (playerAPI *PlayerAPI) PostVolume(c *gin.Context) {
@@ -69,57 +211,332 @@ func (playerAPI *PlayerAPI) PostVolume(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
-	if err := playerAPI.AlexaClient.PostSequenceCmd(alexaModel.BuildVolumeCmd(
-		volumeRequest.Volume, "en-US",
-		volumeRequest.Device.DeviceType,
-		volumeRequest.Device.SerialNumber,
-		volumeRequest.Device.DeviceOwnerCustomerId),
-	); err != nil {
-		log.GetRequestContextLogger(c).Error("PostVolume failed", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+	if err := validateVolumeRequest(volumeRequest); err != nil {
+		log.GetRequestContextLogger(c).Error("PostVolume invalid request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	device, ok := playerAPI.resolveDevice(c, volumeRequest.Device)
+	if !ok {
+		return
+	}
+	ctx := commandContext(c)
+	if volumeRequest.Volume != nil { // absolute volume, latest queued value wins
+		volume := *volumeRequest.Volume
+		playerAPI.scheduleCommand(c, device, "volume", "volume", "volume updated", func(count int) error {
+			return playerAPI.AlexaClient.PostSequenceCmd(ctx, buildDeviceCmd(device,
+				func(sequence *alexaModel.SequenceBuilder, target alexaModel.DeviceTarget) {
+					sequence.AddVolume(volume, "en-US", target)
+				}))
+		})
+		return
+	}
+	step := *volumeRequest.Step // relative volume, same steps are added up, read current volume when executed
+	playerAPI.scheduleCommand(c, device, "volume", "volume "+strconv.Itoa(step), "volume updated", func(count int) error {
+		currentVolumes, err := playerAPI.AlexaClient.GetVolume(ctx)
+		if err != nil {
+			return err
+		}
+		volumes := mapVolumeResponse(currentVolumes)
+		known, unknown := splitByKnownVolume(device, volumes)
+		if known == nil {
+			return &volumeUnknownError{devices: unknown}
+		}
+		err = playerAPI.AlexaClient.PostSequenceCmd(ctx, buildDeviceCmd(*known,
+			func(sequence *alexaModel.SequenceBuilder, target alexaModel.DeviceTarget) {
+				current := volumes.FindByDeviceSerialNumber(target.DeviceSerialNumber).Volume
+				sequence.AddVolume(clampVolume(current+step*count), "en-US", target)
+			}))
+		if err == nil && len(unknown) > 0 { // other group members were stepped
+			return &volumeUnknownError{devices: unknown}
+		}
+		return err
+	})
+}
+
+// volumeUnknownError lists devices relative volume step skipped, stepping from a guessed volume would overwrite the real one
+type volumeUnknownError struct {
+	devices []string
+}
+
+func (e *volumeUnknownError) Error() string {
+	return "volume of " + strings.Join(e.devices, ", ") + " is unknown, not changed"
+}
+
+// splitByKnownVolume returns device (or group with members) whose volume is known, nil if none, and names of the others
+func splitByKnownVolume(device apiModel.PlayerDevice, volumes apiModel.VolumeResponse) (known *apiModel.PlayerDevice, unknown []string) {
+	name := func(device apiModel.PlayerDevice) string { // not known if device check was skipped
+		if device.Name == "" {
+			return device.SerialNumber
+		}
+		return device.Name
+	}
+	if !device.IsGroup() {
+		if volumes.FindByDeviceSerialNumber(device.SerialNumber) == nil {
+			return nil, []string{name(device)}
+		}
+		return &device, nil
+	}
+	group := device
+	group.Members = nil
+	for _, member := range device.Members {
+		if volumes.FindByDeviceSerialNumber(member.SerialNumber) != nil {
+			group.Members = append(group.Members, member)
+		} else {
+			unknown = append(unknown, name(member))
+		}
+	}
+	if !group.IsGroup() {
+		return nil, unknown
+	}
+	return &group, unknown
+}
+
+func (playerAPI *PlayerAPI) PostMute(c *gin.Context) {
+	executeDeviceCommand(c, playerAPI, "mute", func(sequence *alexaModel.SequenceBuilder, target alexaModel.DeviceTarget) {
+		sequence.AddTextCommand("mute", "en-US", target)
+	})
+}
+
+func (playerAPI *PlayerAPI) PostUnmute(c *gin.Context) {
+	executeDeviceCommand(c, playerAPI, "unmute", func(sequence *alexaModel.SequenceBuilder, target alexaModel.DeviceTarget) {
+		sequence.AddTextCommand("unmute", "en-US", target)
+	})
+}
+
+// GetCommands lists queued, in-flight and recently finished player commands, optionally for a single device
+func (playerAPI *PlayerAPI) GetCommands(c *gin.Context) {
+	c.JSON(http.StatusOK, playerAPI.Scheduler.List(c.Query("serial")))
+}
+
+func (playerAPI *PlayerAPI) GetCommand(c *gin.Context) {
+	status, found := playerAPI.Scheduler.Status(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "Command not found"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "volume updated"})
+	c.JSON(http.StatusOK, status)
 }
 
 func executeTextCommand(c *gin.Context, playerAPI *PlayerAPI, command string) {
+	executeDeviceCommand(c, playerAPI, command, func(sequence *alexaModel.SequenceBuilder, target alexaModel.DeviceTarget) {
+		sequence.AddTextCommand("ask "+playerAPI.SkillName()+" to "+command, "en-US", target)
+	})
+}
+
+func executeDeviceCommand(c *gin.Context, playerAPI *PlayerAPI, command string, add func(sequence *alexaModel.SequenceBuilder, target alexaModel.DeviceTarget)) {
 	var playerDevice apiModel.PlayerDevice // request model
 	if err := c.BindJSON(&playerDevice); err != nil {
 		log.GetRequestContextLogger(c).Error("TextCommand unable to parse request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
-	if err := playerAPI.AlexaClient.PostSequenceCmd(alexaModel.BuildTextCommandCmd(
-		"ask "+playerAPI.SkillName+" to "+command, "en-US",
-		playerDevice.DeviceType,
-		playerDevice.SerialNumber,
-		playerDevice.DeviceOwnerCustomerId),
-	); err != nil {
-		log.GetRequestContextLogger(c).Error("TextCommand failed", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+	device, ok := playerAPI.resolveDevice(c, playerDevice)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": command + " executed"})
+	ctx := commandContext(c)
+	playerAPI.scheduleCommand(c, device, command, command, command+" executed", func(count int) error {
+		undoSkip := playerAPI.skipQueue(command, count)
+		if command == "play" { // group members or another device may play now, first one to start leads the queue
+			playerAPI.Queue.Lock()
+			playerAPI.Queue.ReleaseLeader()
+			playerAPI.Queue.Unlock()
+		}
+		sentAt := time.Now()
+		if err := playerAPI.AlexaClient.PostSequenceCmd(ctx, buildDeviceCmd(device, add)); err != nil {
+			undoSkip()
+			return err
+		}
+		if command == "play" { // only accepted play is matched to playback start
+			playerAPI.Queue.MarkPlayRequested(sentAt)
+		}
+		return nil
+	})
+}
+
+// skipQueue moves queue position for coalesced next/previous commands before the command is sent,
+// the skill moves it by the last one. Returned undo restores position if the command fails and nothing moved it since.
+func (playerAPI *PlayerAPI) skipQueue(command string, count int) (undo func()) {
+	queue := playerAPI.Queue
+	queue.Lock()
+	defer queue.Unlock()
+	queuePosition, trackPosition := queue.QueuePosition, queue.TrackPosition
+	for i := 1; i < count; i++ {
+		switch command {
+		case "next":
+			queue.Next()
+		case "previous":
+			queue.Prev()
+		}
+	}
+	skippedPosition := queue.QueuePosition
+	return func() {
+		queue.Lock()
+		defer queue.Unlock()
+		if queue.QueuePosition == skippedPosition {
+			queue.QueuePosition, queue.TrackPosition = queuePosition, trackPosition
+		}
+	}
+}
+
+// scheduleCommand queues the command on the device scheduler and waits for it to complete,
+// with async=true query param it responds right away with the queued command status
+func (playerAPI *PlayerAPI) scheduleCommand(c *gin.Context, device apiModel.PlayerDevice, command string, key string, successMessage string, execute CommandExecutor) {
+	requestCtx := c.Request.Context()
+	id, done := playerAPI.Scheduler.Submit(device.SerialNumber, command, key, func(count int) error {
+		playerAPI.CommandLinks.Record(requestCtx, device.SerialNumber)
+		return execute(count)
+	})
+	if queryBool(c, "async", false) {
+		status, _ := playerAPI.Scheduler.Status(id)
+		c.JSON(http.StatusAccepted, status)
+		return
+	}
+	select {
+	case <-done:
+	case <-c.Request.Context().Done():
+		log.GetRequestContextLogger(c).Warn("Request cancelled while command is pending", "command", command, "id", id)
+		return
+	}
+	status, _ := playerAPI.Scheduler.Status(id)
+	if status.Status == apiModel.CommandStatusFailed {
+		log.GetRequestContextLogger(c).Error("Command failed", "command", command, "id", id, "error", status.Error)
+		c.JSON(commandErrorStatus(playerAPI.Scheduler.Err(id)), gin.H{"status": "error", "message": status.Error})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": successMessage})
+}
+
+// commandErrorStatus is 409 for commands device state didn't allow, 500 otherwise
+func commandErrorStatus(err error) int {
+	var volumeErr *volumeUnknownError
+	if errors.As(err, &volumeErr) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// commandContext keeps request logger for queued commands, but is not cancelled with the request,
+// other requests may be coalesced into the command, and async requests return before it runs
+func commandContext(c *gin.Context) context.Context {
+	return context.WithoutCancel(log.CreateLoggerContext(c))
+}
+
+func validateVolumeRequest(request apiModel.VolumeRequest) error {
+	if (request.Volume == nil) == (request.Step == nil) {
+		return errors.New("either volume or step has to be set")
+	}
+	if request.Volume != nil && (*request.Volume < 0 || *request.Volume > 100) {
+		return errors.New("volume has to be between 0 and 100")
+	}
+	if request.Step != nil && (*request.Step < -100 || *request.Step > 100 || *request.Step == 0) {
+		return errors.New("step has to be between -100 and 100 and not 0")
+	}
+	return nil
+}
+
+func clampVolume(volume int) int {
+	if volume < 0 {
+		return 0
+	}
+	if volume > 100 {
+		return 100
+	}
+	return volume
+}
+
+func buildDeviceCmd(device apiModel.PlayerDevice, add func(sequence *alexaModel.SequenceBuilder, target alexaModel.DeviceTarget)) alexaModel.AlexaCmd {
+	sequence := alexaModel.NewSequenceBuilder()
+	if device.IsGroup() { // skills can't play on multiroom groups, best-effort fan out to each member in parallel
+		targets := make([]alexaModel.DeviceTarget, 0, len(device.Members))
+		for _, member := range device.Members {
+			targets = append(targets, toDeviceTarget(member))
+		}
+		sequence.AddForEachDevice(targets, add)
+	} else {
+		add(sequence, toDeviceTarget(device))
+	}
+	return sequence.Build()
+}
+
+func toDeviceTarget(device apiModel.PlayerDevice) alexaModel.DeviceTarget {
+	return alexaModel.DeviceTarget{
+		DeviceType:         device.DeviceType,
+		DeviceSerialNumber: device.SerialNumber,
+		CustomerID:         device.DeviceOwnerCustomerId,
+	}
 }
 
 func mapDevicesResponse(input alexaModel.DevicesResponse) (output apiModel.DevicesResponse) {
+	playersBySerial := make(map[string]apiModel.PlayerDevice)
+	for _, device := range input.Devices {
+		if isAudioPlayer(device) && device.DeviceFamily != "WHA" {
+			playersBySerial[device.SerialNumber] = mapPlayerDevice(device)
+		}
+	}
+	output.Devices = make([]apiModel.PlayerDevice, 0, len(input.Devices))
 	for _, device := range input.Devices {
-		for _, capability := range device.Capabilities {
-			if capability == "AUDIO_PLAYER" && device.DeviceFamily != "WHA" { // WHA - multiroom devices don't seem to work with skills unfortunately
-				playerDevice := apiModel.PlayerDevice{
-					Name:                  device.AccountName,
-					DeviceOwnerCustomerId: device.DeviceOwnerCustomerId,
-					DeviceType:            device.DeviceType,
-					SerialNumber:          device.SerialNumber,
+		playerDevice := mapPlayerDevice(device)
+		if device.DeviceFamily == "WHA" { // WHA - multiroom groups, resolve members to real devices
+			for _, memberSerial := range device.ClusterMembers {
+				if member, exists := playersBySerial[memberSerial]; exists {
+					playerDevice.Members = append(playerDevice.Members, member)
 				}
-				output.Devices = append(output.Devices, playerDevice)
-				break // to next device
 			}
+			playerDevice.AudioPlayer = playerDevice.IsGroup()
+			playerDevice.Online = playerDevice.Online && hasOnlineMembers(playerDevice)
+		}
+		output.Devices = append(output.Devices, playerDevice)
+	}
+	return output
+}
+
+func hasOnlineMembers(group apiModel.PlayerDevice) bool {
+	for _, member := range group.Members {
+		if member.Online {
+			return true
+		}
+	}
+	return false
+}
+
+func filterDevices(input apiModel.DevicesResponse, audioOnly bool, onlineOnly bool) (output apiModel.DevicesResponse) {
+	output.Devices = make([]apiModel.PlayerDevice, 0, len(input.Devices))
+	for _, device := range input.Devices {
+		if (!audioOnly || device.AudioPlayer) && (!onlineOnly || device.Online) {
+			output.Devices = append(output.Devices, device)
 		}
 	}
 	return output
 }
 
+func mapPlayerDevice(device alexaModel.Device) apiModel.PlayerDevice {
+	return apiModel.PlayerDevice{
+		Name:                   device.AccountName,
+		DeviceOwnerCustomerId:  device.DeviceOwnerCustomerId,
+		DeviceType:             device.DeviceType,
+		SerialNumber:           device.SerialNumber,
+		Online:                 device.Online,
+		AudioPlayer:            isAudioPlayer(device) && device.DeviceFamily != "WHA",
+		DeviceFamily:           device.DeviceFamily,
+		DeviceTypeFriendlyName: nvl(device.DeviceTypeFriendlyName),
+		SoftwareVersion:        device.SoftwareVersion,
+		Language:               nvl(device.Language),
+		BatteryLevel:           device.RemainingBatteryLevel,
+		Charging:               device.Charging,
+	}
+}
+
+func isAudioPlayer(device alexaModel.Device) bool {
+	for _, capability := range device.Capabilities {
+		if capability == "AUDIO_PLAYER" {
+			return true
+		}
+	}
+	return false
+}
+
 func mapVolumeResponse(input alexaModel.VolumeResponse) (output apiModel.VolumeResponse) {
 	for _, volume := range input.Volumes {
 		output.Volumes = append(output.Volumes, apiModel.DeviceVolume{
@@ -130,3 +547,68 @@ func mapVolumeResponse(input alexaModel.VolumeResponse) (output apiModel.VolumeR
 	}
 	return output
 }
+
+func mapPlayerStateResponse(serialNumber string, input alexaModel.PlayerStateResponse, skillName string, displayName string) (output apiModel.PlayerStateResponse) {
+	info := input.PlayerInfo
+	output.DeviceSerialNumber = serialNumber
+	output.State = "IDLE"
+	if info.State != nil {
+		output.State = *info.State
+	}
+	if info.Provider != nil {
+		output.Provider = nvl(info.Provider.ProviderName)
+		output.OwnPlayback = ownPlayback(info.Provider, skillName, displayName)
+	}
+	if info.InfoText != nil {
+		output.Title = nvl(info.InfoText.Title)
+		output.Artist = nvl(info.InfoText.SubText1)
+		output.Album = nvl(info.InfoText.SubText2)
+	}
+	if info.Progress != nil {
+		output.Progress = nvlInt(info.Progress.MediaProgress) * 1000
+		output.Duration = nvlInt(info.Progress.MediaLength) * 1000
+	}
+	if info.Volume != nil {
+		output.Volume = info.Volume.Volume
+		output.Muted = info.Volume.Muted
+	}
+	return output
+}
+
+// ownPlayback tells if provider is our skill: by display name if configured, otherwise provider matching
+// invocation name is ours and anything else is unknown, as display name may differ from invocation name
+func ownPlayback(provider *alexaModel.PlayerProvider, skillName string, displayName string) *bool {
+	names := []string{nvl(provider.ProviderName), nvl(provider.ProviderDisplayName)}
+	if names[0] == "" && names[1] == "" {
+		return nil
+	}
+	matches := func(name string) bool {
+		return slices.ContainsFunc(names, func(providerName string) bool { return strings.EqualFold(providerName, name) })
+	}
+	own := displayName != "" && matches(displayName) || matches(skillName)
+	if !own && displayName == "" {
+		return nil
+	}
+	return &own
+}
+
+func queryBool(c *gin.Context, name string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(c.Query(name)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func nvl(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func nvlInt(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
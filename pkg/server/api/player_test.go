@@ -1,14 +1,23 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	alexaClient "github.com/ahimgit/navidrome-alexa/pkg/alexa/client"
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/model"
+	apiModel "github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
 	"github.com/ahimgit/navidrome-alexa/pkg/util/tests"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type TestCase struct {
@@ -34,9 +43,10 @@ func TestPostPlayerTextCommands(t *testing.T) {
 
 			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
 			mockAlexaClient := new(MockAlexaClient)
+			mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
 			mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
 
-			playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+			playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 			testCase.run(playerAPI, mockGinContext)
 
 			assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -54,9 +64,10 @@ func TestPostPlayerTextCommands(t *testing.T) {
 
 			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
 			mockAlexaClient := new(MockAlexaClient)
+			mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
 			mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(errors.New("mock error"))
 
-			playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+			playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 			testCase.run(playerAPI, mockGinContext)
 
 			assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -73,7 +84,7 @@ func TestPostPlayerTextCommands(t *testing.T) {
 			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
 			mockAlexaClient := new(MockAlexaClient)
 
-			playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+			playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 			testCase.run(playerAPI, mockGinContext)
 
 			assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -84,6 +95,71 @@ func TestPostPlayerTextCommands(t *testing.T) {
 
 }
 
+func TestPostPlayMarksPlayRequested(t *testing.T) {
+	expectedCommand := model.BuildTextCommandCmd("ask skill name to play", "en-US", "dt", "sn", "cid")
+
+	t.Run("executed play is marked", func(t *testing.T) {
+		mockGinContext, _ := tests.MockGin(tests.MockJSONPost(`{"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "sn"}`))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
+
+		queue := apiModel.NewQueue()
+		NewPlayerAPI(mockAlexaClient, queue, "skill name").PostPlay(mockGinContext)
+
+		_, ok := queue.TakePlayRequested()
+		assert.True(t, ok)
+	})
+
+	for _, testCase := range []struct {
+		name string
+		rq   string
+		err  error
+	}{
+		{"invalid request", `{`, nil},
+		{"offline device", `{"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "snoff"}`, nil},
+		{"alexa client error", `{"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "sn"}`, errors.New("mock error")},
+	} {
+		t.Run(testCase.name+" is not marked", func(t *testing.T) {
+			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(testCase.rq))
+			mockAlexaClient := new(MockAlexaClient)
+			mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+			mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(testCase.err)
+
+			queue := apiModel.NewQueue()
+			NewPlayerAPI(mockAlexaClient, queue, "skill name").PostPlay(mockGinContext)
+
+			assert.NotEqual(t, 200, responseRecorder.Code)
+			_, ok := queue.TakePlayRequested()
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestPostPlayerTextCommandsToGroup(t *testing.T) {
+	t.Run("play with multiroom group should fan out to members", func(t *testing.T) {
+		rq := `{"deviceOwnerCustomerId": "cid", "deviceType": "gdt", "serialNumber": "gsn"}`
+		rs := `{"message": "play executed", "status": "success"}`
+		expectedCommand := model.NewSequenceBuilder().
+			AddSequence(model.NewParallelSequenceBuilder().
+				AddTextCommand("ask skill name to play", "en-US", model.DeviceTarget{DeviceType: "dt1", DeviceSerialNumber: "sn1", CustomerID: "cid"}).
+				AddTextCommand("ask skill name to play", "en-US", model.DeviceTarget{DeviceType: "dt2", DeviceSerialNumber: "sn2", CustomerID: "cid"})).
+			Build()
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.PostPlay(mockGinContext)
+
+		assert.JSONEq(t, rs, responseRecorder.Body.String())
+		assert.Equal(t, 200, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+}
+
 func TestPostPlayerVolumeCommand(t *testing.T) {
 	t.Run("PostVolume with correct request", func(t *testing.T) {
 		rq := `{
@@ -96,9 +172,35 @@ func TestPostPlayerVolumeCommand(t *testing.T) {
 
 		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
 		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.PostVolume(mockGinContext)
+
+		assert.JSONEq(t, rs, responseRecorder.Body.String())
+		assert.Equal(t, 200, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("PostVolume with multiroom group should fan out to members", func(t *testing.T) {
+		rq := `{
+			"device": {"deviceOwnerCustomerId": "cid", "deviceType": "gdt", "serialNumber": "gsn"},
+		    "volume": 31
+		}`
+		rs := `{"message": "volume updated", "status": "success"}`
+		expectedCommand := model.NewSequenceBuilder().
+			AddSequence(model.NewParallelSequenceBuilder().
+				AddVolume(31, "en-US", model.DeviceTarget{DeviceType: "dt1", DeviceSerialNumber: "sn1", CustomerID: "cid"}).
+				AddVolume(31, "en-US", model.DeviceTarget{DeviceType: "dt2", DeviceSerialNumber: "sn2", CustomerID: "cid"})).
+			Build()
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
 		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
 
-		playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 		playerAPI.PostVolume(mockGinContext)
 
 		assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -117,9 +219,10 @@ func TestPostPlayerVolumeCommand(t *testing.T) {
 
 		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
 		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
 		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(errors.New("mock error"))
 
-		playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 		playerAPI.PostVolume(mockGinContext)
 
 		assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -127,6 +230,137 @@ func TestPostPlayerVolumeCommand(t *testing.T) {
 		mockAlexaClient.AssertExpectations(t)
 	})
 
+	t.Run("PostVolume with relative step", func(t *testing.T) {
+		rq := `{
+			"device": {"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "dsn1"},
+		    "step": -5
+		}`
+		expectedCommand := model.BuildVolumeCmd(6, "en-US", "dt", "dsn1", "cid")
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(model.DevicesResponse{}, errors.New("skip device check"))
+		mockAlexaClient.On("GetVolume").Return(volume(), noError())
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.PostVolume(mockGinContext)
+
+		assert.JSONEq(t, `{"message": "volume updated", "status": "success"}`, responseRecorder.Body.String())
+		assert.Equal(t, 200, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("PostVolume with relative step is clamped to 0-100", func(t *testing.T) {
+		rq := `{
+			"device": {"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "dsn2"},
+		    "step": 100
+		}`
+		expectedCommand := model.BuildVolumeCmd(100, "en-US", "dt", "dsn2", "cid")
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(model.DevicesResponse{}, errors.New("skip device check"))
+		mockAlexaClient.On("GetVolume").Return(volume(), noError())
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.PostVolume(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("PostVolume with relative step, volume client error", func(t *testing.T) {
+		rq := `{
+			"device": {"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "sn"},
+		    "step": 5
+		}`
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("GetVolume").Return(model.VolumeResponse{}, errors.New("mock error"))
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.PostVolume(mockGinContext)
+
+		assert.JSONEq(t, `{"message":"mock error", "status":"error"}`, responseRecorder.Body.String())
+		assert.Equal(t, 500, responseRecorder.Code)
+		mockAlexaClient.AssertNotCalled(t, "PostSequenceCmd", mock.Anything)
+	})
+
+	t.Run("PostVolume with relative step, unknown volume is not guessed", func(t *testing.T) {
+		rq := `{
+			"device": {"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "sn"},
+		    "step": 5
+		}`
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("GetVolume").Return(volume(), noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.PostVolume(mockGinContext)
+
+		assert.JSONEq(t, `{"message":"volume of Kitchen is unknown, not changed", "status":"error"}`, responseRecorder.Body.String())
+		assert.Equal(t, 409, responseRecorder.Code)
+		mockAlexaClient.AssertNotCalled(t, "PostSequenceCmd", mock.Anything)
+	})
+
+	t.Run("PostVolume with relative step, group member with unknown volume is skipped", func(t *testing.T) {
+		rq := `{
+			"device": {"deviceOwnerCustomerId": "cid", "deviceType": "gdt", "serialNumber": "gsn"},
+		    "step": 5
+		}`
+		expectedCommand := model.NewSequenceBuilder().
+			AddSequence(model.NewParallelSequenceBuilder().
+				AddVolume(45, "en-US", model.DeviceTarget{DeviceType: "dt1", DeviceSerialNumber: "sn1", CustomerID: "cid"})).
+			Build()
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("GetVolume").Return(model.VolumeResponse{Volumes: []model.Volume{{DeviceType: "dt1", Dsn: "sn1", SpeakerVolume: 40}}}, noError())
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.PostVolume(mockGinContext)
+
+		assert.JSONEq(t, `{"message":"volume of Living room is unknown, not changed", "status":"error"}`, responseRecorder.Body.String())
+		assert.Equal(t, 409, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	for _, testCase := range []struct {
+		name string
+		rq   string
+		rs   string
+	}{
+		{"volume above 100", `"volume": 101`, `volume has to be between 0 and 100`},
+		{"volume below 0", `"volume": -1`, `volume has to be between 0 and 100`},
+		{"step out of range", `"step": 101`, `step has to be between -100 and 100 and not 0`},
+		{"zero step", `"step": 0`, `step has to be between -100 and 100 and not 0`},
+		{"neither volume nor step", `"muted": true`, `either volume or step has to be set`},
+		{"both volume and step", `"volume": 10, "step": 10`, `either volume or step has to be set`},
+	} {
+		t.Run(fmt.Sprintf("PostVolume with %s", testCase.name), func(t *testing.T) {
+			rq := fmt.Sprintf(`{"device": {"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "sn"}, %s}`, testCase.rq)
+			rs := fmt.Sprintf(`{"message":"%s", "status":"error"}`, testCase.rs)
+
+			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+			mockAlexaClient := new(MockAlexaClient)
+
+			playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+			playerAPI.PostVolume(mockGinContext)
+
+			assert.JSONEq(t, rs, responseRecorder.Body.String())
+			assert.Equal(t, 400, responseRecorder.Code)
+			mockAlexaClient.AssertNotCalled(t, "PostSequenceCmd", mock.Anything)
+		})
+	}
+
 	t.Run("PostVolume with invalid request", func(t *testing.T) {
 		rq := `{`
 		rs := `{"message":"unexpected EOF", "status":"error"}`
@@ -134,7 +368,7 @@ func TestPostPlayerVolumeCommand(t *testing.T) {
 		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
 		mockAlexaClient := new(MockAlexaClient)
 
-		playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 		playerAPI.PostVolume(mockGinContext)
 
 		assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -143,6 +377,166 @@ func TestPostPlayerVolumeCommand(t *testing.T) {
 	})
 }
 
+func TestPostPlayerCommandsScheduling(t *testing.T) {
+
+	t.Run("burst of next commands is coalesced into one jump", func(t *testing.T) {
+		rq := `{"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "sn"}`
+		expectedCommand := model.BuildTextCommandCmd("ask skill name to next", "en-US", "dt", "sn", "cid")
+		release := make(chan time.Time)
+
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).WaitUntil(release).Return(noError())
+		queue := apiModel.NewQueue()
+		queue.Songs = make([]apiModel.Song, 6)
+		playerAPI := NewPlayerAPI(mockAlexaClient, queue, "skill name")
+
+		var wg sync.WaitGroup
+		postNext := func() {
+			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				playerAPI.PostNext(mockGinContext)
+				assert.Equal(t, 200, responseRecorder.Code)
+			}()
+		}
+		postNext()
+		require.Eventually(t, func() bool { return hasCommand(playerAPI, apiModel.CommandStatusInFlight, 1) }, time.Second, time.Millisecond)
+		for i := 0; i < 4; i++ {
+			postNext()
+		}
+		require.Eventually(t, func() bool { return hasCommand(playerAPI, apiModel.CommandStatusQueued, 4) }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		mockAlexaClient.AssertNumberOfCalls(t, "PostSequenceCmd", 2)
+		assert.Equal(t, 3, queue.QueuePosition) // skill moves it by one more on each next
+	})
+
+	t.Run("failed coalesced next command leaves queue position unchanged", func(t *testing.T) {
+		rq := `{"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "sn"}`
+		expectedCommand := model.BuildTextCommandCmd("ask skill name to next", "en-US", "dt", "sn", "cid")
+		release := make(chan time.Time)
+
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).WaitUntil(release).Return(errors.New("alexa is down"))
+		queue := apiModel.NewQueue()
+		queue.Songs = make([]apiModel.Song, 6)
+		queue.TrackPosition = 42
+		playerAPI := NewPlayerAPI(mockAlexaClient, queue, "skill name")
+
+		var wg sync.WaitGroup
+		postNext := func() {
+			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				playerAPI.PostNext(mockGinContext)
+				assert.NotEqual(t, 200, responseRecorder.Code)
+			}()
+		}
+		postNext()
+		require.Eventually(t, func() bool { return hasCommand(playerAPI, apiModel.CommandStatusInFlight, 1) }, time.Second, time.Millisecond)
+		for i := 0; i < 4; i++ {
+			postNext()
+		}
+		require.Eventually(t, func() bool { return hasCommand(playerAPI, apiModel.CommandStatusQueued, 4) }, time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		mockAlexaClient.AssertNumberOfCalls(t, "PostSequenceCmd", 2)
+		assert.Equal(t, 0, queue.QueuePosition)
+		assert.Equal(t, 42, queue.TrackPosition)
+	})
+
+	t.Run("async command responds with queued command status", func(t *testing.T) {
+		rq := `{"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "sn"}`
+		expectedCommand := model.BuildTextCommandCmd("ask skill name to play", "en-US", "dt", "sn", "cid")
+
+		mockGinContext, responseRecorder := tests.MockGin(httptest.NewRequest("POST", "/api/play?async=true", strings.NewReader(rq)))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.PostPlay(mockGinContext)
+
+		var status apiModel.CommandStatus
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &status))
+		assert.Equal(t, 202, responseRecorder.Code)
+		assert.Equal(t, "play", status.Command)
+		assert.Equal(t, "sn", status.DeviceSerialNumber)
+		require.Eventually(t, func() bool {
+			commandStatus, _ := playerAPI.Scheduler.Status(status.ID)
+			return commandStatus.IsDone()
+		}, time.Second, time.Millisecond)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("GetCommand and GetCommands", func(t *testing.T) {
+		mockAlexaClient := new(MockAlexaClient)
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		id, done := playerAPI.Scheduler.Submit("sn", "play", "play", func(count int) error { return nil })
+		<-done
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/commands/" + id))
+		mockGinContext.Params = gin.Params{{Key: "id", Value: id}}
+		playerAPI.GetCommand(mockGinContext)
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Contains(t, responseRecorder.Body.String(), `"status":"COMPLETED"`)
+
+		mockGinContext, responseRecorder = tests.MockGin(tests.MockJSONGet("/api/commands/unknown"))
+		mockGinContext.Params = gin.Params{{Key: "id", Value: "unknown"}}
+		playerAPI.GetCommand(mockGinContext)
+		assert.JSONEq(t, `{"message":"Command not found", "status":"error"}`, responseRecorder.Body.String())
+		assert.Equal(t, 404, responseRecorder.Code)
+
+		mockGinContext, responseRecorder = tests.MockGin(tests.MockJSONGet("/api/commands?serial=other"))
+		playerAPI.GetCommands(mockGinContext)
+		assert.JSONEq(t, `{"commands":[]}`, responseRecorder.Body.String())
+	})
+}
+
+func hasCommand(playerAPI *PlayerAPI, status interface{}, count int) bool {
+	for _, command := range playerAPI.Scheduler.List("sn").Commands {
+		if command.Status == status && command.Count == count {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPostPlayerMuteCommands(t *testing.T) {
+
+	for _, testCase := range []struct {
+		command string
+		run     func(playerAPI *PlayerAPI, gin *gin.Context)
+	}{
+		{"mute", func(playerAPI *PlayerAPI, gin *gin.Context) { playerAPI.PostMute(gin) }},
+		{"unmute", func(playerAPI *PlayerAPI, gin *gin.Context) { playerAPI.PostUnmute(gin) }},
+	} {
+		t.Run(fmt.Sprintf("%s with correct request", testCase.command), func(t *testing.T) {
+			rq := `{"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "sn"}`
+			rs := fmt.Sprintf(`{"message": "%s executed", "status": "success"}`, testCase.command)
+			expectedCommand := model.BuildTextCommandCmd(testCase.command, "en-US", "dt", "sn", "cid")
+
+			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+			mockAlexaClient := new(MockAlexaClient)
+			mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+			mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
+
+			playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+			testCase.run(playerAPI, mockGinContext)
+
+			assert.JSONEq(t, rs, responseRecorder.Body.String())
+			assert.Equal(t, 200, responseRecorder.Code)
+			mockAlexaClient.AssertExpectations(t)
+		})
+	}
+}
+
 func TestPlayerAPIGetVolume(t *testing.T) {
 
 	t.Run("GetVolume", func(t *testing.T) {
@@ -155,7 +549,22 @@ func TestPlayerAPIGetVolume(t *testing.T) {
 		mockAlexaClient := new(MockAlexaClient)
 		mockAlexaClient.On("GetVolume").Return(volume(), noError())
 
-		playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetVolume(mockGinContext)
+
+		assert.JSONEq(t, rs, responseRecorder.Body.String())
+		assert.Equal(t, 200, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("GetVolume, single device", func(t *testing.T) {
+		rs := `{"deviceSerialNumber": "dsn2", "muted": true, "volume": 22}`
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/volume?serial=dsn2"))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetVolume").Return(volume(), noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 		playerAPI.GetVolume(mockGinContext)
 
 		assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -163,6 +572,21 @@ func TestPlayerAPIGetVolume(t *testing.T) {
 		mockAlexaClient.AssertExpectations(t)
 	})
 
+	t.Run("GetVolume, single device not found", func(t *testing.T) {
+		rs := `{"message":"Device volume not found", "status":"error"}`
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/volume?serial=unknown"))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetVolume").Return(volume(), noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetVolume(mockGinContext)
+
+		assert.JSONEq(t, rs, responseRecorder.Body.String())
+		assert.Equal(t, 404, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
 	t.Run("GetVolume, client error", func(t *testing.T) {
 		rs := `{"message":"mock error", "status":"error"}`
 
@@ -170,7 +594,7 @@ func TestPlayerAPIGetVolume(t *testing.T) {
 		mockAlexaClient := new(MockAlexaClient)
 		mockAlexaClient.On("GetVolume").Return(volume(), errors.New("mock error"))
 
-		playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 		playerAPI.GetVolume(mockGinContext)
 
 		assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -182,13 +606,14 @@ func TestPlayerAPIGetVolume(t *testing.T) {
 func TestPlayerAPIGetDevices(t *testing.T) {
 
 	t.Run("GetDevices", func(t *testing.T) {
-		rs := `{"devices":[{"name":"an3","deviceOwnerCustomerId":"cid3","deviceType":"dt3","serialNumber":"sn3"}]}`
+		rs := `{"devices":[{"name":"an3","deviceOwnerCustomerId":"cid3","deviceType":"dt3","serialNumber":"sn3",
+			"online":false,"audioPlayer":true,"deviceFamily":"df3"}]}`
 
 		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/"))
 		mockAlexaClient := new(MockAlexaClient)
 		mockAlexaClient.On("GetDevices").Return(devices(), noError())
 
-		playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 		playerAPI.GetDevices(mockGinContext)
 
 		assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -196,6 +621,67 @@ func TestPlayerAPIGetDevices(t *testing.T) {
 		mockAlexaClient.AssertExpectations(t)
 	})
 
+	t.Run("GetDevices, with multiroom group resolved to members", func(t *testing.T) {
+		rs := `{"devices":[
+			{"name":"an1","deviceOwnerCustomerId":"cid1","deviceType":"dt1","serialNumber":"sn1","online":true,"audioPlayer":true,"deviceFamily":"ECHO"},
+			{"name":"Everywhere","deviceOwnerCustomerId":"cid1","deviceType":"gdt","serialNumber":"gsn","online":true,"audioPlayer":true,"deviceFamily":"WHA","members":[
+				{"name":"an1","deviceOwnerCustomerId":"cid1","deviceType":"dt1","serialNumber":"sn1","online":true,"audioPlayer":true,"deviceFamily":"ECHO"},
+				{"name":"an2","deviceOwnerCustomerId":"cid2","deviceType":"dt2","serialNumber":"sn2","online":true,"audioPlayer":true,"deviceFamily":"ECHO"}
+			]},
+			{"name":"an2","deviceOwnerCustomerId":"cid2","deviceType":"dt2","serialNumber":"sn2","online":true,"audioPlayer":true,"deviceFamily":"ECHO"}
+		]}`
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/"))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(devicesWithGroup(), noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetDevices(mockGinContext)
+
+		assert.JSONEq(t, rs, responseRecorder.Body.String())
+		assert.Equal(t, 200, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("GetDevices, online only filter", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/?onlineOnly=true"))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetDevices(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Equal(t, []string{"sn", "sn1", "sn2", "gsn", "gsnmix"}, serialNumbers(responseRecorder.Body.String()))
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("GetDevices, including non-audio devices", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/?audioOnly=false"))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetDevices(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Equal(t, []string{"sn", "sn1", "sn2", "snoff", "snna", "gsn", "gsnmix", "gsnoff"}, serialNumbers(responseRecorder.Body.String()))
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("GetDevices, devices are cached between calls", func(t *testing.T) {
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError()).Once()
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+
+		for i := 0; i < 2; i++ {
+			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/"))
+			playerAPI.GetDevices(mockGinContext)
+			assert.Equal(t, 200, responseRecorder.Code)
+		}
+		mockAlexaClient.AssertExpectations(t)
+	})
+
 	t.Run("GetDevices, no devices error", func(t *testing.T) {
 		rs := `{"message":"No devices on the account", "status":"error"}`
 
@@ -203,7 +689,7 @@ func TestPlayerAPIGetDevices(t *testing.T) {
 		mockAlexaClient := new(MockAlexaClient)
 		mockAlexaClient.On("GetDevices").Return(model.DevicesResponse{}, noError())
 
-		playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 		playerAPI.GetDevices(mockGinContext)
 
 		assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -218,7 +704,7 @@ func TestPlayerAPIGetDevices(t *testing.T) {
 		mockAlexaClient := new(MockAlexaClient)
 		mockAlexaClient.On("GetDevices").Return(devices(), errors.New("mock error"))
 
-		playerAPI := NewPlayerAPI(mockAlexaClient, "skill name")
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
 		playerAPI.GetDevices(mockGinContext)
 
 		assert.JSONEq(t, rs, responseRecorder.Body.String())
@@ -227,6 +713,259 @@ func TestPlayerAPIGetDevices(t *testing.T) {
 	})
 }
 
+func TestPlayerAPIGetDevice(t *testing.T) {
+
+	t.Run("GetDevice, found", func(t *testing.T) {
+		rs := `{"name":"Kitchen","deviceOwnerCustomerId":"cid","deviceType":"dt","serialNumber":"sn","online":true,"audioPlayer":true,
+			"deviceFamily":"ECHO","deviceTypeFriendlyName":"Echo Dot","softwareVersion":"123","language":"en-US","batteryLevel":50,"charging":false}`
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/devices/sn"))
+		mockGinContext.Params = gin.Params{{Key: "serial", Value: "sn"}}
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetDevice(mockGinContext)
+
+		assert.JSONEq(t, rs, responseRecorder.Body.String())
+		assert.Equal(t, 200, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("GetDevice, not found", func(t *testing.T) {
+		rs := `{"message":"Device not found", "status":"error"}`
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/devices/unknown"))
+		mockGinContext.Params = gin.Params{{Key: "serial", Value: "unknown"}}
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetDevice(mockGinContext)
+
+		assert.JSONEq(t, rs, responseRecorder.Body.String())
+		assert.Equal(t, 404, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+}
+
+func TestPlayerAPIGetPlayerState(t *testing.T) {
+
+	kitchen := model.DeviceTarget{DeviceType: "dt", DeviceSerialNumber: "sn", CustomerID: "cid"}
+
+	t.Run("GetPlayerState, playing our skill", func(t *testing.T) {
+		rs := `{"deviceSerialNumber":"sn","state":"PLAYING","ownPlayback":true,"provider":"Skill Name","title":"Name1",
+			"artist":"Artist1","album":"Album1","progress":42000,"duration":321000,"volume":35,"muted":false,"queueState":"IDLE"}`
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/devices/sn/state"))
+		mockGinContext.Params = gin.Params{{Key: "serial", Value: "sn"}}
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("GetPlayerState", kitchen).Return(playerState("PLAYING", "Skill Name"), noError())
+		queue := apiModel.NewQueue()
+		queue.Songs = []apiModel.Song{{Id: "Id1"}}
+		queue.State = apiModel.QueueStateIdle
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, queue, "skill name")
+		playerAPI.GetPlayerState(mockGinContext)
+
+		assert.JSONEq(t, rs, responseRecorder.Body.String())
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Equal(t, apiModel.QueueStateIdle, queue.State) // reading state doesn't change the queue
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	for _, testCase := range []struct {
+		name     string
+		state    string
+		provider string
+	}{
+		{"interrupted by other music", "PLAYING", "Spotify"},
+		{"paused", "PAUSED", "skill name"},
+		{"idle", "IDLE", ""},
+	} {
+		t.Run(fmt.Sprintf("GetPlayerState, %s, queue state is not changed", testCase.name), func(t *testing.T) {
+			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/devices/sn/state"))
+			mockGinContext.Params = gin.Params{{Key: "serial", Value: "sn"}}
+			mockAlexaClient := new(MockAlexaClient)
+			mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+			mockAlexaClient.On("GetPlayerState", kitchen).Return(playerState(testCase.state, testCase.provider), noError())
+			queue := apiModel.NewQueue()
+			queue.Songs = []apiModel.Song{{Id: "Id1"}}
+			queue.State = apiModel.QueueStatePlaying
+
+			playerAPI := NewPlayerAPI(mockAlexaClient, queue, "skill name")
+			playerAPI.GetPlayerState(mockGinContext)
+
+			assert.Equal(t, 200, responseRecorder.Code)
+			assert.Equal(t, apiModel.QueueStatePlaying, queue.State)
+			assert.Contains(t, responseRecorder.Body.String(), `"queueState":"PLAYING"`)
+			mockAlexaClient.AssertExpectations(t)
+		})
+	}
+
+	t.Run("GetPlayerState, nothing played yet", func(t *testing.T) {
+		rs := `{"deviceSerialNumber":"sn","state":"IDLE","ownPlayback":null,"progress":0,"duration":0,"muted":false,"queueState":""}`
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/devices/sn/state"))
+		mockGinContext.Params = gin.Params{{Key: "serial", Value: "sn"}}
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("GetPlayerState", kitchen).Return(model.PlayerStateResponse{}, noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetPlayerState(mockGinContext)
+
+		assert.JSONEq(t, rs, responseRecorder.Body.String())
+		assert.Equal(t, 200, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("GetPlayerState, not found", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/devices/unknown/state"))
+		mockGinContext.Params = gin.Params{{Key: "serial", Value: "unknown"}}
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetPlayerState(mockGinContext)
+
+		assert.JSONEq(t, `{"message":"Device not found", "status":"error"}`, responseRecorder.Body.String())
+		assert.Equal(t, 404, responseRecorder.Code)
+		mockAlexaClient.AssertNotCalled(t, "GetPlayerState", mock.Anything)
+	})
+
+	t.Run("GetPlayerState, group", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/devices/gsn/state"))
+		mockGinContext.Params = gin.Params{{Key: "serial", Value: "gsn"}}
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetPlayerState(mockGinContext)
+
+		assert.Equal(t, 400, responseRecorder.Code)
+		mockAlexaClient.AssertNotCalled(t, "GetPlayerState", mock.Anything)
+	})
+
+	t.Run("GetPlayerState, error", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/devices/sn/state"))
+		mockGinContext.Params = gin.Params{{Key: "serial", Value: "sn"}}
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("GetPlayerState", kitchen).Return(model.PlayerStateResponse{}, errors.New("mock error"))
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.GetPlayerState(mockGinContext)
+
+		assert.JSONEq(t, `{"message":"mock error", "status":"error"}`, responseRecorder.Body.String())
+		assert.Equal(t, 500, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+}
+
+func TestOwnPlayback(t *testing.T) {
+	own, other := true, false
+	for _, testCase := range []struct {
+		name         string
+		providerName string
+		displayName  string
+		configured   string
+		expected     *bool
+	}{
+		{"provider is invocation name", "Navi Stream", "", "", &own},
+		{"provider display name is invocation name", "", "navi stream", "", &own},
+		{"unknown provider without configured display name", "Navidrome Player", "", "", nil},
+		{"no provider", "", "", "Navidrome Player", nil},
+		{"provider is configured display name", "Navidrome Player", "", "Navidrome Player", &own},
+		{"other provider with configured display name", "Spotify", "", "Navidrome Player", &other},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			provider := &model.PlayerProvider{ProviderName: &testCase.providerName, ProviderDisplayName: &testCase.displayName}
+			assert.Equal(t, testCase.expected, ownPlayback(provider, "navi stream", testCase.configured))
+		})
+	}
+}
+
+func playerState(state string, provider string) model.PlayerStateResponse {
+	title, artist, album := "Name1", "Artist1", "Album1"
+	progress, length, volume := 42, 321, 35
+	var playerProvider *model.PlayerProvider
+	if provider != "" {
+		playerProvider = &model.PlayerProvider{ProviderName: &provider}
+	}
+	return model.PlayerStateResponse{PlayerInfo: model.PlayerInfo{
+		State:    &state,
+		Provider: playerProvider,
+		InfoText: &model.PlayerInfoText{Title: &title, SubText1: &artist, SubText2: &album},
+		Progress: &model.PlayerProgress{MediaProgress: &progress, MediaLength: &length},
+		Volume:   &model.PlayerVolume{Volume: &volume},
+	}}
+}
+
+func TestPostPlayerCommandsDeviceChecks(t *testing.T) {
+
+	for _, testCase := range []struct {
+		name         string
+		serialNumber string
+		code         int
+		rs           string
+	}{
+		{"offline device should be rejected", "snoff", 409, `{"message":"Device Offline is offline", "status":"error"}`},
+		{"group with all members offline should be rejected", "gsnoff", 409, `{"message":"Device Offline group is offline", "status":"error"}`},
+		{"non-audio device should be rejected", "snna", 400, `{"message":"Device Tablet can't play audio", "status":"error"}`},
+		{"unknown device should be rejected", "unknown", 404, `{"message":"Device not found", "status":"error"}`},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			rq := fmt.Sprintf(`{"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "%s"}`, testCase.serialNumber)
+
+			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+			mockAlexaClient := new(MockAlexaClient)
+			mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+
+			playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+			playerAPI.PostPlay(mockGinContext)
+
+			assert.JSONEq(t, testCase.rs, responseRecorder.Body.String())
+			assert.Equal(t, testCase.code, responseRecorder.Code)
+			mockAlexaClient.AssertExpectations(t)
+			mockAlexaClient.AssertNotCalled(t, "PostSequenceCmd", mock.Anything)
+		})
+	}
+
+	t.Run("group with some members offline should only target online members", func(t *testing.T) {
+		rq := `{"deviceOwnerCustomerId": "cid", "deviceType": "gdt", "serialNumber": "gsnmix"}`
+		expectedCommand := model.BuildTextCommandCmd("ask skill name to play", "en-US", "dt1", "sn1", "cid")
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(commandDevices(), noError())
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.PostPlay(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+
+	t.Run("device status check failure should not block the command", func(t *testing.T) {
+		rq := `{"deviceOwnerCustomerId": "cid", "deviceType": "dt", "serialNumber": "sn"}`
+		expectedCommand := model.BuildTextCommandCmd("ask skill name to play", "en-US", "dt", "sn", "cid")
+
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
+		mockAlexaClient := new(MockAlexaClient)
+		mockAlexaClient.On("GetDevices").Return(model.DevicesResponse{}, errors.New("mock error"))
+		mockAlexaClient.On("PostSequenceCmd", expectedCommand).Return(noError())
+
+		playerAPI := NewPlayerAPI(mockAlexaClient, apiModel.NewQueue(), "skill name")
+		playerAPI.PostPlay(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		mockAlexaClient.AssertExpectations(t)
+	})
+}
+
 func noError() error {
 	return nil
 }
@@ -283,30 +1022,149 @@ func devices() model.DevicesResponse {
 	}
 }
 
+func devicesWithGroup() model.DevicesResponse {
+	return model.DevicesResponse{
+		Devices: []model.Device{
+			{
+				AccountName:           "an1",
+				Capabilities:          []string{"AUDIO_PLAYER"},
+				DeviceFamily:          "ECHO",
+				Online:                true,
+				DeviceOwnerCustomerId: "cid1",
+				DeviceType:            "dt1",
+				SerialNumber:          "sn1",
+			},
+			{
+				AccountName:           "Everywhere",
+				Capabilities:          []string{"AUDIO_PLAYER"},
+				ClusterMembers:        []string{"sn1", "sn2", "sn-not-a-player"},
+				DeviceFamily:          "WHA",
+				Online:                true,
+				DeviceOwnerCustomerId: "cid1",
+				DeviceType:            "gdt",
+				SerialNumber:          "gsn",
+			},
+			{
+				AccountName:           "an2",
+				Capabilities:          []string{"AUDIO_PLAYER"},
+				DeviceFamily:          "ECHO",
+				Online:                true,
+				DeviceOwnerCustomerId: "cid2",
+				DeviceType:            "dt2",
+				SerialNumber:          "sn2",
+			},
+			{
+				AccountName:           "an3",
+				Capabilities:          []string{"ANYTHING"},
+				DeviceFamily:          "TABLET",
+				DeviceOwnerCustomerId: "cid3",
+				DeviceType:            "dt3",
+				SerialNumber:          "sn-not-a-player",
+			},
+		},
+	}
+}
+
+func commandDevices() model.DevicesResponse {
+	friendlyName, language, battery, charging := "Echo Dot", "en-US", 50, false
+	player := func(name string, serialNumber string, deviceType string, online bool) model.Device {
+		return model.Device{
+			AccountName:           name,
+			Capabilities:          []string{"AUDIO_PLAYER"},
+			DeviceFamily:          "ECHO",
+			DeviceOwnerCustomerId: "cid",
+			DeviceType:            deviceType,
+			SerialNumber:          serialNumber,
+			Online:                online,
+		}
+	}
+	group := func(name string, serialNumber string, members ...string) model.Device {
+		return model.Device{
+			AccountName:           name,
+			ClusterMembers:        members,
+			DeviceFamily:          "WHA",
+			DeviceOwnerCustomerId: "cid",
+			DeviceType:            "gdt",
+			SerialNumber:          serialNumber,
+			Online:                true,
+		}
+	}
+	kitchen := player("Kitchen", "sn", "dt", true)
+	kitchen.DeviceTypeFriendlyName = &friendlyName
+	kitchen.Language = &language
+	kitchen.SoftwareVersion = "123"
+	kitchen.RemainingBatteryLevel = &battery
+	kitchen.Charging = &charging
+	tablet := player("Tablet", "snna", "dtna", true)
+	tablet.Capabilities = []string{"ANYTHING"}
+	return model.DevicesResponse{
+		Devices: []model.Device{
+			kitchen,
+			player("Bedroom", "sn1", "dt1", true),
+			player("Living room", "sn2", "dt2", true),
+			player("Offline", "snoff", "dtoff", false),
+			tablet,
+			group("Everywhere", "gsn", "sn1", "sn2"),
+			group("Mixed group", "gsnmix", "sn1", "snoff"),
+			group("Offline group", "gsnoff", "snoff"),
+		},
+	}
+}
+
+func serialNumbers(body string) (serialNumbers []string) {
+	var rs struct {
+		Devices []struct {
+			SerialNumber string `json:"serialNumber"`
+		} `json:"devices"`
+	}
+	_ = json.Unmarshal([]byte(body), &rs)
+	for _, device := range rs.Devices {
+		serialNumbers = append(serialNumbers, device.SerialNumber)
+	}
+	return serialNumbers
+}
+
 type MockAlexaClient struct {
 	mock.Mock
 }
 
-func (m *MockAlexaClient) LogIn(relog bool) (err error) {
+func (m *MockAlexaClient) LogIn(_ context.Context, relog bool) (err error) {
 	args := m.Called(relog)
 	return args.Error(1)
 }
 
-func (m *MockAlexaClient) PostSequenceCmd(command model.AlexaCmd) (err error) {
+func (m *MockAlexaClient) PostSequenceCmd(_ context.Context, command model.AlexaCmd) (err error) {
 	args := m.Called(command)
 	return args.Error(0)
 }
 
-func (m *MockAlexaClient) GetDevices() (devices model.DevicesResponse, err error) {
+func (m *MockAlexaClient) GetDevices(_ context.Context) (devices model.DevicesResponse, err error) {
 	args := m.Called()
 	ret1 := args.Get(0)
 	ret2 := args.Error(1)
 	return ret1.(model.DevicesResponse), ret2
 }
 
-func (m *MockAlexaClient) GetVolume() (devices model.VolumeResponse, err error) {
+func (m *MockAlexaClient) GetVolume(_ context.Context) (devices model.VolumeResponse, err error) {
 	args := m.Called()
 	ret1 := args.Get(0)
 	ret2 := args.Error(1)
 	return ret1.(model.VolumeResponse), ret2
 }
+
+func (m *MockAlexaClient) GetCircuitBreakerState() alexaClient.CircuitBreakerState {
+	args := m.Called()
+	return args.Get(0).(alexaClient.CircuitBreakerState)
+}
+
+func (m *MockAlexaClient) GetSessionState() alexaClient.SessionState {
+	args := m.Called()
+	return args.Get(0).(alexaClient.SessionState)
+}
+
+func (m *MockAlexaClient) GetPlayerState(_ context.Context, device model.DeviceTarget) (state model.PlayerStateResponse, err error) {
+	args := m.Called(device)
+	ret1 := args.Get(0)
+	ret2 := args.Error(1)
+	return ret1.(model.PlayerStateResponse), ret2
+}
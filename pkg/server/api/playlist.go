@@ -0,0 +1,344 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/playqueue"
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/subsonic"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/api/playlist"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	maxImportSize    = 1024 * 1024
+	maxImportEntries = 1000
+	importWorkers    = 4  // parallel Navidrome searches
+	importCount      = 20 // search results to match entry against
+)
+
+// PlaylistAPI fills the queue from playlist files and saves it as one, Subsonic is nil if Navidrome access is not configured
+type PlaylistAPI struct {
+	Queue        *model.Queue
+	Subsonic     subsonic.ISubsonicClient
+	StreamDomain func() string // same as skill streams from, changes on reload
+	PlayQueue    playqueue.IPlayQueueSync
+}
+
+type SavePlaylistRequest struct {
+	Name       string `json:"name"`       // name of new playlist
+	PlaylistId string `json:"playlistId"` // existing playlist to update instead
+	Mode       string `json:"mode"`       // replace (default) or append songs of existing playlist
+}
+
+type ImportFailure struct {
+	Entry    int    `json:"entry"` // 1-based position in playlist
+	Location string `json:"location"`
+	Title    string `json:"title,omitempty"`
+	Message  string `json:"message"`
+}
+
+func NewPlaylistAPI(queue *model.Queue, subsonicClient subsonic.ISubsonicClient, streamDomain func() string) *PlaylistAPI {
+	return &PlaylistAPI{Queue: queue, Subsonic: subsonicClient, StreamDomain: streamDomain}
+}
+
+// PostImport replaces (mode=replace, default) or appends to (mode=append) the queue with playlist in request body,
+// format is detected from content unless set with format query param
+func (api *PlaylistAPI) PostImport(c *gin.Context) {
+	mode := c.DefaultQuery("mode", "replace")
+	if mode != "replace" && mode != "append" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "mode must be replace or append"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportSize+1))
+	if err != nil {
+		log.GetRequestContextLogger(c).Error("PostImport unable to read request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if len(body) > maxImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"status": "error", "message": "playlist is larger than " + strconv.Itoa(maxImportSize) + " bytes"})
+		return
+	}
+	format := playlist.DetectFormat(body)
+	if formatParam := c.Query("format"); formatParam != "" {
+		if format, err = playlist.ParseFormat(formatParam); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+			return
+		}
+	}
+	entries, err := playlist.Parse(format, body)
+	if err != nil {
+		log.GetRequestContextLogger(c).Error("PostImport unable to parse playlist", "format", format, "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "playlist has no entries"})
+		return
+	}
+	if len(entries) > maxImportEntries {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "playlist has more than " + strconv.Itoa(maxImportEntries) + " entries"})
+		return
+	}
+	songs, failed := api.resolve(log.CreateLoggerContext(c), entries)
+	if len(songs) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"status": "error", "message": "no playlist entries could be resolved", "failed": failed})
+		return
+	}
+	api.Queue.Lock()
+	defer api.Queue.Unlock()
+	if mode == "append" {
+		api.Queue.Songs = append(api.Queue.Songs, songs...)
+	} else {
+		api.Queue.Songs = songs
+		api.Queue.QueuePosition = 0
+		api.Queue.TrackPosition = 0
+	}
+	if api.PlayQueue != nil {
+		api.PlayQueue.Changed()
+	}
+	log.GetRequestContextLogger(c).Info("PostImport queue updated", "mode", mode, "format", format, "imported", len(songs), "failed", len(failed))
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "queue updated", "imported": len(songs), "failed": failed})
+}
+
+// GetExport renders the queue as playlist with absolute stream URLs, format is m3u8 (default), m3u or xspf.
+// Navidrome credentials of songs posted by Navidrome UI are not exported
+func (api *PlaylistAPI) GetExport(c *gin.Context) {
+	format, err := playlist.ParseFormat(c.DefaultQuery("format", string(playlist.FormatM3U8)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	streamDomain := api.StreamDomain()
+	api.Queue.Lock()
+	entries := make([]playlist.Entry, len(api.Queue.Songs))
+	for i, song := range api.Queue.Songs {
+		song.Stream, song.Cover = subsonic.StripCredentials(song.Stream), subsonic.StripCredentials(song.Cover)
+		entries[i] = playlist.Entry{
+			Location: song.StreamURL(streamDomain),
+			Title:    song.Name,
+			Artist:   song.Artist,
+			Album:    song.Album,
+			Duration: song.Duration,
+			Image:    song.CoverURL(streamDomain),
+		}
+	}
+	api.Queue.Unlock()
+	out, err := playlist.Write(format, "Navidrome Alexa Queue", entries)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="queue.`+string(format)+`"`)
+	c.Data(http.StatusOK, playlist.ContentType(format)+"; charset=utf-8", out)
+}
+
+// PostPlaylist saves Navidrome songs of the queue as new playlist, or replaces or appends songs of existing one,
+// imported stream URLs are not in the library and are skipped
+func (api *PlaylistAPI) PostPlaylist(c *gin.Context) {
+	if api.Subsonic == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "message": "Navidrome access is not configured"})
+		return
+	}
+	var rq SavePlaylistRequest
+	if err := c.BindJSON(&rq); err != nil {
+		log.GetRequestContextLogger(c).Error("PostPlaylist unable to parse request", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := validateSavePlaylistRequest(rq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	api.Queue.Lock()
+	queueLength := len(api.Queue.Songs)
+	songIds := make([]string, 0, queueLength)
+	for _, song := range api.Queue.Songs {
+		if !song.External() {
+			songIds = append(songIds, song.Id)
+		}
+	}
+	api.Queue.Unlock()
+	if len(songIds) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "queue has no Navidrome songs"})
+		return
+	}
+	ctx := log.CreateLoggerContext(c)
+	saved := &subsonic.Playlist{Id: rq.PlaylistId}
+	var err error
+	if rq.Mode == "append" {
+		err = api.Subsonic.UpdatePlaylist(ctx, rq.PlaylistId, songIds)
+	} else {
+		saved, err = api.Subsonic.CreatePlaylist(ctx, rq.PlaylistId, rq.Name, songIds)
+	}
+	if err != nil {
+		log.GetRequestContextLogger(c).Error("PostPlaylist failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	message := "playlist updated"
+	if rq.PlaylistId == "" {
+		message = "playlist created"
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": message, "playlist": saved,
+		"saved": len(songIds), "skipped": queueLength - len(songIds)})
+}
+
+func validateSavePlaylistRequest(rq SavePlaylistRequest) error {
+	switch {
+	case rq.Mode != "" && rq.Mode != "replace" && rq.Mode != "append":
+		return errors.New("mode must be replace or append")
+	case rq.Name == "" && rq.PlaylistId == "":
+		return errors.New("name or playlistId is required")
+	case rq.Mode == "append" && rq.PlaylistId == "":
+		return errors.New("playlistId is required to append")
+	}
+	return nil
+}
+
+// PostResume replaces the queue with Navidrome play queue of navidromeUser, saved by other Subsonic clients or by us
+func (api *PlaylistAPI) PostResume(c *gin.Context) {
+	if api.Subsonic == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "message": "Navidrome access is not configured"})
+		return
+	}
+	playQueue, err := api.Subsonic.GetPlayQueue(log.CreateLoggerContext(c))
+	if err != nil {
+		log.GetRequestContextLogger(c).Error("PostResume failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if playQueue == nil || len(playQueue.Entry) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "message": "Navidrome has no saved play queue"})
+		return
+	}
+	api.Queue.Lock()
+	defer api.Queue.Unlock()
+	api.Queue.Songs = mapSongs(api.Subsonic, playQueue.Entry)
+	api.Queue.QueuePosition = 0
+	api.Queue.TrackPosition = 0
+	for i, song := range playQueue.Entry {
+		if song.Id == playQueue.Current {
+			api.Queue.QueuePosition = i
+			api.Queue.TrackPosition = playQueue.Position
+			break
+		}
+	}
+	log.GetRequestContextLogger(c).Info("PostResume queue loaded", "songs", len(api.Queue.Songs), "queuePosition", api.Queue.QueuePosition, "trackPosition", api.Queue.TrackPosition)
+	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "queue loaded",
+		"songs": len(api.Queue.Songs), "queuePosition": api.Queue.QueuePosition, "trackPosition": api.Queue.TrackPosition})
+}
+
+// resolve maps entries to songs keeping playlist order, searches run in parallel
+func (api *PlaylistAPI) resolve(ctx context.Context, entries []playlist.Entry) ([]model.Song, []ImportFailure) {
+	resolved := make([]*model.Song, len(entries))
+	errs := make([]error, len(entries))
+	workers := make(chan struct{}, importWorkers)
+	var wg sync.WaitGroup
+	for i := range entries {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(i int) {
+			defer func() { <-workers; wg.Done() }()
+			resolved[i], errs[i] = api.resolveEntry(ctx, entries[i])
+		}(i)
+	}
+	wg.Wait()
+	songs := make([]model.Song, 0, len(entries))
+	failed := make([]ImportFailure, 0)
+	for i, song := range resolved {
+		if errs[i] != nil {
+			failed = append(failed, ImportFailure{Entry: i + 1, Location: entries[i].Location, Title: entries[i].Title, Message: errs[i].Error()})
+			continue
+		}
+		songs = append(songs, *song)
+	}
+	return songs, failed
+}
+
+// resolveEntry finds entry in Navidrome library if configured, falls back to entry location as stream URL
+func (api *PlaylistAPI) resolveEntry(ctx context.Context, entry playlist.Entry) (*model.Song, error) {
+	if api.Subsonic == nil || entry.Name() == "" {
+		return streamSong(entry)
+	}
+	song, err := api.searchSong(ctx, entry)
+	if err != nil && isStreamURL(entry.Location) {
+		return streamSong(entry)
+	}
+	return song, err
+}
+
+func (api *PlaylistAPI) searchSong(ctx context.Context, entry playlist.Entry) (*model.Song, error) {
+	queries := []string{entry.Name()}
+	if entry.Artist != "" { // artist narrows search, retried without it in case it is spelled differently in library
+		queries = []string{entry.Artist + " " + entry.Name(), entry.Name()}
+	}
+	for _, query := range queries {
+		found, err := api.Subsonic.Search(ctx, query, importCount)
+		if err != nil {
+			return nil, err
+		}
+		if match := matchSong(entry, found); match != nil {
+			return &mapSongs(api.Subsonic, []subsonic.Song{*match})[0], nil
+		}
+	}
+	return nil, errors.New("not found in library")
+}
+
+// matchSong picks song with entry title, preferring the one with entry artist and then album
+func matchSong(entry playlist.Entry, songs []subsonic.Song) *subsonic.Song {
+	var best *subsonic.Song
+	bestScore := -1
+	for i, song := range songs {
+		if !sameName(song.Title, entry.Name()) {
+			continue
+		}
+		score := 0
+		if entry.Artist != "" && sameName(song.Artist, entry.Artist) {
+			score += 2
+		}
+		if entry.Album != "" && sameName(song.Album, entry.Album) {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = &songs[i], score
+		}
+	}
+	return best
+}
+
+func sameName(a string, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+func isStreamURL(location string) bool {
+	parsed, err := url.Parse(location)
+	return err == nil && parsed.Scheme == "https" && parsed.Host != ""
+}
+
+// streamSong plays entry location as is, Alexa only streams over https
+func streamSong(entry playlist.Entry) (*model.Song, error) {
+	if !isStreamURL(entry.Location) {
+		return nil, errors.New("not an https stream URL")
+	}
+	id := sha256.Sum256([]byte(entry.Location))
+	return &model.Song{
+		Id:       "url-" + hex.EncodeToString(id[:8]),
+		Name:     entry.Name(),
+		Album:    entry.Album,
+		Artist:   entry.Artist,
+		Duration: entry.Duration,
+		Cover:    entry.Image,
+		Stream:   entry.Location,
+	}, nil
+}
@@ -0,0 +1,277 @@
+package playlist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"github.com/pkg/errors"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatM3U  Format = "m3u"
+	FormatM3U8 Format = "m3u8"
+	FormatXSPF Format = "xspf"
+	FormatPLS  Format = "pls"
+)
+
+// Entry is a playlist item as found in the file, any field but Location may be empty
+type Entry struct {
+	Location string
+	Title    string
+	Artist   string
+	Album    string
+	Duration int    // ms
+	Image    string // cover URL, XSPF only
+}
+
+var trackNumber = regexp.MustCompile(`^\d{1,3}\s*[-._]?\s+`)
+
+// Name is entry title, or file name without extension and track number if title is unknown
+func (e Entry) Name() string {
+	if e.Title != "" {
+		return e.Title
+	}
+	name := e.Location
+	if parsed, err := url.Parse(e.Location); err == nil && len(parsed.Scheme) > 1 { // not a windows drive letter
+		name = parsed.Path
+	}
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	return strings.TrimSpace(trackNumber.ReplaceAllString(name, ""))
+}
+
+func ParseFormat(format string) (Format, error) {
+	switch parsed := Format(strings.ToLower(format)); parsed {
+	case FormatM3U, FormatM3U8, FormatXSPF, FormatPLS:
+		return parsed, nil
+	}
+	return "", errors.New("unsupported playlist format " + format + ", expected one of m3u, m3u8, xspf, pls")
+}
+
+// DetectFormat guesses format from content, M3U if it is neither XSPF nor PLS
+func DetectFormat(body []byte) Format {
+	start := bytes.ToLower(bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))))
+	switch {
+	case bytes.HasPrefix(start, []byte("<")):
+		return FormatXSPF
+	case bytes.HasPrefix(start, []byte("[playlist]")):
+		return FormatPLS
+	}
+	return FormatM3U
+}
+
+func Parse(format Format, body []byte) ([]Entry, error) {
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	switch format {
+	case FormatM3U, FormatM3U8:
+		return parseM3U(body)
+	case FormatXSPF:
+		return parseXSPF(body)
+	case FormatPLS:
+		return parsePLS(body)
+	}
+	return nil, errors.New("unsupported playlist format " + string(format))
+}
+
+// parseM3U reads plain and extended M3U, #EXTINF:<seconds>,<artist> - <title> and #EXTART/#EXTALB apply to next location
+func parseM3U(body []byte) ([]Entry, error) {
+	var entries []Entry
+	var next Entry
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			duration, title, _ := strings.Cut(info, ",")
+			if fields := strings.Fields(duration); len(fields) > 0 { // duration may be followed by attributes
+				if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil && seconds > 0 {
+					next.Duration = int(seconds * 1000)
+				}
+			}
+			if artist, songTitle, found := strings.Cut(title, " - "); found {
+				next.Artist, next.Title = strings.TrimSpace(artist), strings.TrimSpace(songTitle)
+			} else {
+				next.Title = strings.TrimSpace(title)
+			}
+		case strings.HasPrefix(line, "#EXTART:"):
+			next.Artist = strings.TrimSpace(strings.TrimPrefix(line, "#EXTART:"))
+		case strings.HasPrefix(line, "#EXTALB:"):
+			next.Album = strings.TrimSpace(strings.TrimPrefix(line, "#EXTALB:"))
+		case strings.HasPrefix(line, "#"):
+		default:
+			next.Location = line
+			entries = append(entries, next)
+			next = Entry{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading m3u failed")
+	}
+	return entries, nil
+}
+
+type xspf struct {
+	XMLName   xml.Name `xml:"playlist"`
+	Xmlns     string   `xml:"xmlns,attr"` // namespace is not checked on parse, some players omit it
+	Version   string   `xml:"version,attr"`
+	Title     string   `xml:"title,omitempty"`
+	TrackList struct {
+		Tracks []xspfTrack `xml:"track"`
+	} `xml:"trackList"`
+}
+
+type xspfTrack struct {
+	Locations []string `xml:"location"`
+	Title     string   `xml:"title,omitempty"`
+	Creator   string   `xml:"creator,omitempty"`
+	Album     string   `xml:"album,omitempty"`
+	Duration  int      `xml:"duration,omitempty"` // ms
+	Image     string   `xml:"image,omitempty"`
+}
+
+func parseXSPF(body []byte) ([]Entry, error) {
+	var parsed xspf
+	if err := xml.Unmarshal(body, &parsed); err != nil {
+		return nil, errors.Wrap(err, "parsing xspf failed")
+	}
+	entries := make([]Entry, 0, len(parsed.TrackList.Tracks))
+	for _, track := range parsed.TrackList.Tracks {
+		entry := Entry{
+			Title:    strings.TrimSpace(track.Title),
+			Artist:   strings.TrimSpace(track.Creator),
+			Album:    strings.TrimSpace(track.Album),
+			Duration: track.Duration,
+			Image:    strings.TrimSpace(track.Image),
+		}
+		if len(track.Locations) > 0 {
+			entry.Location = strings.TrimSpace(track.Locations[0])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parsePLS reads FileN, TitleN and LengthN (seconds) keys, ordered by N
+func parsePLS(body []byte) ([]Entry, error) {
+	byNumber := map[int]*Entry{}
+	var numbers []int
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !found {
+			continue
+		}
+		name := strings.TrimRight(strings.ToLower(key), "0123456789")
+		number, err := strconv.Atoi(key[len(name):])
+		if err != nil {
+			continue
+		}
+		entry, ok := byNumber[number]
+		if !ok {
+			entry = &Entry{}
+			byNumber[number] = entry
+			numbers = append(numbers, number)
+		}
+		switch name {
+		case "file":
+			entry.Location = strings.TrimSpace(value)
+		case "title":
+			entry.Title = strings.TrimSpace(value)
+		case "length":
+			if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+				entry.Duration = seconds * 1000
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading pls failed")
+	}
+	sort.Ints(numbers)
+	entries := make([]Entry, 0, len(numbers))
+	for _, number := range numbers {
+		if byNumber[number].Location != "" {
+			entries = append(entries, *byNumber[number])
+		}
+	}
+	return entries, nil
+}
+
+// ContentType is media type of the format for downloads
+func ContentType(format Format) string {
+	switch format {
+	case FormatXSPF:
+		return "application/xspf+xml"
+	case FormatPLS:
+		return "audio/x-scpls"
+	}
+	return "audio/x-mpegurl"
+}
+
+// Write renders entries as extended M3U (UTF-8 for both m3u and m3u8) or XSPF titled with name
+func Write(format Format, name string, entries []Entry) ([]byte, error) {
+	switch format {
+	case FormatM3U, FormatM3U8:
+		return writeM3U(name, entries), nil
+	case FormatXSPF:
+		return writeXSPF(name, entries)
+	}
+	return nil, errors.New("unsupported export format " + string(format) + ", expected one of m3u, m3u8, xspf")
+}
+
+func writeM3U(name string, entries []Entry) []byte {
+	var out bytes.Buffer
+	out.WriteString("#EXTM3U\n")
+	if name != "" {
+		out.WriteString("#PLAYLIST:" + oneLine(name) + "\n")
+	}
+	for _, entry := range entries {
+		duration := -1
+		if entry.Duration > 0 {
+			duration = (entry.Duration + 500) / 1000
+		}
+		title := oneLine(entry.Title)
+		if entry.Artist != "" {
+			title = oneLine(entry.Artist) + " - " + title
+		}
+		out.WriteString("#EXTINF:" + strconv.Itoa(duration) + "," + title + "\n")
+		if entry.Album != "" {
+			out.WriteString("#EXTALB:" + oneLine(entry.Album) + "\n")
+		}
+		out.WriteString(oneLine(entry.Location) + "\n")
+	}
+	return out.Bytes()
+}
+
+func writeXSPF(name string, entries []Entry) ([]byte, error) {
+	playlist := xspf{Xmlns: "http://xspf.org/ns/0/", Version: "1", Title: name}
+	for _, entry := range entries {
+		playlist.TrackList.Tracks = append(playlist.TrackList.Tracks, xspfTrack{
+			Locations: []string{entry.Location},
+			Title:     entry.Title,
+			Creator:   entry.Artist,
+			Album:     entry.Album,
+			Duration:  entry.Duration,
+			Image:     entry.Image,
+		})
+	}
+	out, err := xml.MarshalIndent(playlist, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "writing xspf failed")
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+// oneLine keeps line based formats parseable
+func oneLine(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,158 @@
+package playlist
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	t.Run("Parse, extended m3u", func(t *testing.T) {
+		m3u := "\xef\xbb\xbf#EXTM3U\r\n" +
+			"#EXTINF:123,Artist1 - Title1\r\n" +
+			"#EXTALB:Album1\r\n" +
+			"Artist1/Album1/01 - Title1.mp3\r\n" +
+			"\r\n" +
+			"# comment\r\n" +
+			"#EXTINF:-1 tvg-id=\"x\",Radio\r\n" +
+			"https://radio.example.com/stream\r\n" +
+			"/music/02. Title3.flac\r\n"
+
+		entries, err := Parse(DetectFormat([]byte(m3u)), []byte(m3u))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Entry{
+			{Location: "Artist1/Album1/01 - Title1.mp3", Title: "Title1", Artist: "Artist1", Album: "Album1", Duration: 123000},
+			{Location: "https://radio.example.com/stream", Title: "Radio"},
+			{Location: "/music/02. Title3.flac"},
+		}, entries)
+		assert.Equal(t, "Title3", entries[2].Name())
+	})
+
+	t.Run("Parse, xspf", func(t *testing.T) {
+		xspf := `<?xml version="1.0" encoding="UTF-8"?>
+			<playlist version="1" xmlns="http://xspf.org/ns/0/">
+				<trackList>
+					<track>
+						<location>https://music.example.com/Title1.mp3</location>
+						<title>Title1</title>
+						<creator>Artist1</creator>
+						<album>Album1</album>
+						<duration>123000</duration>
+					</track>
+					<track><location>file:///music/Title%202.mp3</location></track>
+				</trackList>
+			</playlist>`
+
+		entries, err := Parse(DetectFormat([]byte(xspf)), []byte(xspf))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Entry{
+			{Location: "https://music.example.com/Title1.mp3", Title: "Title1", Artist: "Artist1", Album: "Album1", Duration: 123000},
+			{Location: "file:///music/Title%202.mp3"},
+		}, entries)
+		assert.Equal(t, "Title 2", entries[1].Name())
+	})
+
+	t.Run("Parse, pls", func(t *testing.T) {
+		pls := "[playlist]\n" +
+			"File2=C:\\Music\\Title2.mp3\n" +
+			"File1=https://music.example.com/Title1.mp3\n" +
+			"Title1=Title1\n" +
+			"Length1=123\n" +
+			"Length2=-1\n" +
+			"NumberOfEntries=2\n" +
+			"Version=2\n"
+
+		entries, err := Parse(DetectFormat([]byte(pls)), []byte(pls))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Entry{
+			{Location: "https://music.example.com/Title1.mp3", Title: "Title1", Duration: 123000},
+			{Location: "C:\\Music\\Title2.mp3"},
+		}, entries)
+		assert.Equal(t, "Title2", entries[1].Name())
+	})
+
+	t.Run("Parse, invalid xspf", func(t *testing.T) {
+		_, err := Parse(FormatXSPF, []byte("<playlist><trackList>"))
+
+		assert.ErrorContains(t, err, "parsing xspf failed")
+	})
+
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("M3U8")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatM3U8, format)
+	_, err = ParseFormat("wpl")
+	assert.EqualError(t, err, "unsupported playlist format wpl, expected one of m3u, m3u8, xspf, pls")
+}
+
+func TestWrite(t *testing.T) {
+	entries := []Entry{
+		{Location: "https://music.example.com/rest/stream?id=1&u=user", Title: "Title1", Artist: "Artist1", Album: "Album1", Duration: 122600, Image: "https://music.example.com/cover/1"},
+		{Location: "https://radio.example.com/stream", Title: "Radio\nLive"},
+	}
+
+	t.Run("Write, extended m3u", func(t *testing.T) {
+		out, err := Write(FormatM3U8, "Queue", entries)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "#EXTM3U\n"+
+			"#PLAYLIST:Queue\n"+
+			"#EXTINF:123,Artist1 - Title1\n"+
+			"#EXTALB:Album1\n"+
+			"https://music.example.com/rest/stream?id=1&u=user\n"+
+			"#EXTINF:-1,Radio Live\n"+
+			"https://radio.example.com/stream\n", string(out))
+	})
+
+	t.Run("Write, xspf", func(t *testing.T) {
+		out, err := Write(FormatXSPF, "Queue", entries)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<playlist xmlns="http://xspf.org/ns/0/" version="1">
+  <title>Queue</title>
+  <trackList>
+    <track>
+      <location>https://music.example.com/rest/stream?id=1&amp;u=user</location>
+      <title>Title1</title>
+      <creator>Artist1</creator>
+      <album>Album1</album>
+      <duration>122600</duration>
+      <image>https://music.example.com/cover/1</image>
+    </track>
+    <track>
+      <location>https://radio.example.com/stream</location>
+      <title>Radio&#xA;Live</title>
+    </track>
+  </trackList>
+</playlist>
+`, string(out))
+	})
+
+	t.Run("Write, parses back", func(t *testing.T) {
+		for _, format := range []Format{FormatM3U8, FormatXSPF} {
+			out, err := Write(format, "Queue", entries[:1])
+			assert.NoError(t, err)
+
+			parsed, err := Parse(DetectFormat(out), out)
+
+			assert.NoError(t, err)
+			expected := entries[0]
+			if format == FormatM3U8 {
+				expected.Duration, expected.Image = 123000, ""
+			}
+			assert.Equal(t, []Entry{expected}, parsed)
+		}
+	})
+
+	t.Run("Write, pls is import only", func(t *testing.T) {
+		_, err := Write(FormatPLS, "Queue", entries)
+
+		assert.EqualError(t, err, "unsupported export format pls, expected one of m3u, m3u8, xspf")
+	})
+}
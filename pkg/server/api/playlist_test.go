@@ -0,0 +1,336 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/subsonic"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tests"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const importM3U = `#EXTM3U
+#EXTINF:123,Artist1 - Title1
+Artist1/Album1/01 - Title1.mp3
+#EXTINF:10,Unknown - Missing
+Unknown/Missing.mp3
+https://radio.example.com/stream
+/music/Title2.flac
+`
+
+func TestPlaylistAPIPostImport(t *testing.T) {
+
+	t.Run("PostImport, resolves entries through Navidrome search and replaces queue", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(importRequest("/?format=m3u8", importM3U))
+		mockSubsonicClient := &MockSubsonicClient{results: map[string][]subsonic.Song{
+			"Artist1 Title1": {
+				{Id: "Id0", Title: "Title1 (Live)", Artist: "Artist1"},
+				{Id: "Id1", Title: "Title1", Artist: "Other"},
+				{Id: "Id2", Title: "title1", Artist: "artist1", Album: "Album1", Duration: 123},
+			},
+			"Title2": {{Id: "Id3", Title: "Title2", Artist: "Artist2", Duration: 10}},
+		}}
+		queue := queue()
+		queue.QueuePosition = 1
+		playQueue := &MockPlayQueueSync{}
+		playlistAPI := NewPlaylistAPI(queue, mockSubsonicClient, streamDomain)
+		playlistAPI.PlayQueue = playQueue
+
+		playlistAPI.PostImport(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "success", "message": "queue updated", "imported": 3, "failed": [
+			{"entry": 2, "location": "Unknown/Missing.mp3", "title": "Missing", "message": "not found in library"}
+		]}`, responseRecorder.Body.String())
+		assert.Equal(t, []string{"Id2", "url-" + streamId("https://radio.example.com/stream"), "Id3"}, songIds(queue))
+		assert.Equal(t, "/stream/Id2", queue.Songs[0].Stream)
+		assert.Equal(t, "https://radio.example.com/stream", queue.Songs[1].Stream)
+		assert.Equal(t, "stream", queue.Songs[1].Name)
+		assert.Equal(t, 0, queue.QueuePosition)
+		assert.Equal(t, 1, playQueue.changed)
+	})
+
+	t.Run("PostImport, resolves entries as stream URLs without Navidrome access and appends to queue", func(t *testing.T) {
+		xspf := `<playlist><trackList>
+			<track><location>https://music.example.com/a.mp3</location><title>A</title><duration>1000</duration></track>
+			<track><location>http://music.example.com/b.mp3</location></track>
+		</trackList></playlist>`
+		mockGinContext, responseRecorder := tests.MockGin(importRequest("/?mode=append", xspf))
+		queue := queue()
+
+		NewPlaylistAPI(queue, nil, streamDomain).PostImport(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "success", "message": "queue updated", "imported": 1, "failed": [
+			{"entry": 2, "location": "http://music.example.com/b.mp3", "message": "not an https stream URL"}
+		]}`, responseRecorder.Body.String())
+		assert.Equal(t, []string{"Id1", "url-" + streamId("https://music.example.com/a.mp3")}, songIds(queue))
+		assert.Equal(t, model.Song{Id: "url-" + streamId("https://music.example.com/a.mp3"), Name: "A", Duration: 1000, Stream: "https://music.example.com/a.mp3"}, queue.Songs[1])
+	})
+
+	t.Run("PostImport, keeps queue if nothing resolved", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(importRequest("/", "[playlist]\nFile1=/music/a.mp3\n"))
+		queue := queue()
+
+		NewPlaylistAPI(queue, &MockSubsonicClient{err: errors.New("search failed")}, streamDomain).PostImport(mockGinContext)
+
+		assert.Equal(t, 422, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "error", "message": "no playlist entries could be resolved", "failed": [
+			{"entry": 1, "location": "/music/a.mp3", "message": "search failed"}
+		]}`, responseRecorder.Body.String())
+		assert.Equal(t, []string{"Id1"}, songIds(queue))
+	})
+
+	t.Run("PostImport, rejects invalid requests", func(t *testing.T) {
+		for _, test := range []struct {
+			url, body, message string
+		}{
+			{"/?mode=insert", importM3U, "mode must be replace or append"},
+			{"/?format=wpl", importM3U, "unsupported playlist format wpl, expected one of m3u, m3u8, xspf, pls"},
+			{"/", "#EXTM3U\n", "playlist has no entries"},
+			{"/", strings.Repeat("https://music.example.com/a.mp3\n", maxImportEntries+1), "playlist has more than 1000 entries"},
+		} {
+			mockGinContext, responseRecorder := tests.MockGin(importRequest(test.url, test.body))
+
+			NewPlaylistAPI(queue(), nil, streamDomain).PostImport(mockGinContext)
+
+			assert.Equal(t, 400, responseRecorder.Code, test.message)
+			assert.JSONEq(t, `{"status": "error", "message": "`+test.message+`"}`, responseRecorder.Body.String())
+		}
+	})
+
+	t.Run("PostImport, rejects too large playlist", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(importRequest("/", strings.Repeat("#", maxImportSize+1)))
+
+		NewPlaylistAPI(queue(), nil, streamDomain).PostImport(mockGinContext)
+
+		assert.Equal(t, 413, responseRecorder.Code)
+	})
+
+}
+
+func TestPlaylistAPIGetExport(t *testing.T) {
+
+	t.Run("GetExport, m3u8 by default with absolute URLs", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/"))
+		queue := queue()
+		queue.Songs = append(queue.Songs, model.Song{Id: "url-1", Name: "Radio", Duration: 10000, Stream: "https://radio.example.com/stream"})
+
+		NewPlaylistAPI(queue, nil, streamDomain).GetExport(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Equal(t, "audio/x-mpegurl; charset=utf-8", responseRecorder.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="queue.m3u8"`, responseRecorder.Header().Get("Content-Disposition"))
+		assert.Equal(t, "#EXTM3U\n"+
+			"#PLAYLIST:Navidrome Alexa Queue\n"+
+			"#EXTINF:0,Artist1 - Name1\n"+
+			"#EXTALB:Album1\n"+
+			"https://music.example.com/Stream1\n"+
+			"#EXTINF:10,Radio\n"+
+			"https://radio.example.com/stream\n", responseRecorder.Body.String())
+	})
+
+	t.Run("GetExport, xspf with covers", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/?format=xspf"))
+
+		NewPlaylistAPI(queue(), nil, streamDomain).GetExport(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Equal(t, "application/xspf+xml; charset=utf-8", responseRecorder.Header().Get("Content-Type"))
+		assert.Contains(t, responseRecorder.Body.String(), "<location>https://music.example.com/Stream1</location>")
+		assert.Contains(t, responseRecorder.Body.String(), "<image>https://music.example.com/Cover1</image>")
+	})
+
+	t.Run("GetExport, strips Navidrome credentials from stream and cover paths", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/?format=xspf"))
+		queue := model.NewQueue()
+		queue.Songs = []model.Song{{Id: "Id1", Name: "Name1",
+			Stream: "/rest/stream?id=Id1&u=admin&t=token&s=salt&v=1.16.1&c=NavidromeUI",
+			Cover:  "/rest/getCoverArt?id=al-1&u=admin&t=token&s=salt&size=300"}}
+
+		NewPlaylistAPI(queue, nil, streamDomain).GetExport(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Contains(t, responseRecorder.Body.String(), "<location>https://music.example.com/rest/stream?c=NavidromeUI&amp;id=Id1&amp;v=1.16.1</location>")
+		assert.Contains(t, responseRecorder.Body.String(), "<image>https://music.example.com/rest/getCoverArt?id=al-1&amp;size=300</image>")
+		assert.NotContains(t, responseRecorder.Body.String(), "token")
+		assert.NotContains(t, responseRecorder.Body.String(), "salt")
+		assert.Equal(t, "/rest/stream?id=Id1&u=admin&t=token&s=salt&v=1.16.1&c=NavidromeUI", queue.Songs[0].Stream, "queue keeps credentials for streaming")
+	})
+
+	t.Run("GetExport, rejects unsupported format", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/?format=pls"))
+
+		NewPlaylistAPI(queue(), nil, streamDomain).GetExport(mockGinContext)
+
+		assert.Equal(t, 400, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "error", "message": "unsupported export format pls, expected one of m3u, m3u8, xspf"}`, responseRecorder.Body.String())
+	})
+
+}
+
+func TestPlaylistAPIPostPlaylist(t *testing.T) {
+
+	t.Run("PostPlaylist, creates playlist skipping stream URLs", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(`{"name": "Party"}`))
+		mockSubsonicClient := &MockSubsonicClient{playlist: &subsonic.Playlist{Id: "pl-1", Name: "Party", SongCount: 2}}
+		queue := queue()
+		queue.Songs = append(queue.Songs,
+			model.Song{Id: "url-1", Stream: "https://radio.example.com/stream"},
+			model.Song{Id: "Id2", Stream: "/Stream2"})
+
+		NewPlaylistAPI(queue, mockSubsonicClient, streamDomain).PostPlaylist(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "success", "message": "playlist created", "saved": 2, "skipped": 1,
+			"playlist": {"id": "pl-1", "name": "Party", "songCount": 2}}`, responseRecorder.Body.String())
+		assert.Equal(t, []string{"CreatePlaylist::Party:Id1,Id2"}, mockSubsonicClient.calls)
+	})
+
+	t.Run("PostPlaylist, replaces songs of existing playlist", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(`{"playlistId": "pl-1"}`))
+		mockSubsonicClient := &MockSubsonicClient{playlist: &subsonic.Playlist{Id: "pl-1", Name: "Party", SongCount: 1}}
+
+		NewPlaylistAPI(queue(), mockSubsonicClient, streamDomain).PostPlaylist(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Equal(t, []string{"CreatePlaylist:pl-1::Id1"}, mockSubsonicClient.calls)
+	})
+
+	t.Run("PostPlaylist, appends songs to existing playlist", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(`{"playlistId": "pl-1", "mode": "append"}`))
+		mockSubsonicClient := &MockSubsonicClient{}
+
+		NewPlaylistAPI(queue(), mockSubsonicClient, streamDomain).PostPlaylist(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "success", "message": "playlist updated", "saved": 1, "skipped": 0,
+			"playlist": {"id": "pl-1", "name": "", "songCount": 0}}`, responseRecorder.Body.String())
+		assert.Equal(t, []string{"UpdatePlaylist:pl-1::Id1"}, mockSubsonicClient.calls)
+	})
+
+	t.Run("PostPlaylist, returns Subsonic error", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(`{"name": "Party"}`))
+
+		NewPlaylistAPI(queue(), &MockSubsonicClient{err: errors.New("playlist failed")}, streamDomain).PostPlaylist(mockGinContext)
+
+		assert.Equal(t, 500, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "error", "message": "playlist failed"}`, responseRecorder.Body.String())
+	})
+
+	t.Run("PostPlaylist, rejects invalid requests", func(t *testing.T) {
+		emptyQueue := model.NewQueue()
+		for _, test := range []struct {
+			queue         *model.Queue
+			body, message string
+		}{
+			{queue(), `{"name": "Party", "mode": "insert"}`, "mode must be replace or append"},
+			{queue(), `{}`, "name or playlistId is required"},
+			{queue(), `{"name": "Party", "mode": "append"}`, "playlistId is required to append"},
+			{emptyQueue, `{"name": "Party"}`, "queue has no Navidrome songs"},
+		} {
+			mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(test.body))
+
+			NewPlaylistAPI(test.queue, &MockSubsonicClient{}, streamDomain).PostPlaylist(mockGinContext)
+
+			assert.Equal(t, 400, responseRecorder.Code, test.message)
+			assert.JSONEq(t, `{"status": "error", "message": "`+test.message+`"}`, responseRecorder.Body.String())
+		}
+	})
+
+	t.Run("PostPlaylist, unavailable without Navidrome access", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(`{"name": "Party"}`))
+
+		NewPlaylistAPI(queue(), nil, streamDomain).PostPlaylist(mockGinContext)
+
+		assert.Equal(t, 503, responseRecorder.Code)
+	})
+
+}
+
+func TestPlaylistAPIPostResume(t *testing.T) {
+
+	t.Run("PostResume, loads Navidrome play queue with position", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(""))
+		mockSubsonicClient := &MockSubsonicClient{playQueue: &subsonic.PlayQueue{
+			Entry:    []subsonic.Song{{Id: "Id1", Title: "Title1"}, {Id: "Id2", Title: "Title2", Duration: 200}},
+			Current:  "Id2",
+			Position: 12345,
+		}}
+		queue := queue()
+
+		NewPlaylistAPI(queue, mockSubsonicClient, streamDomain).PostResume(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "success", "message": "queue loaded", "songs": 2, "queuePosition": 1, "trackPosition": 12345}`, responseRecorder.Body.String())
+		assert.Equal(t, []string{"Id1", "Id2"}, songIds(queue))
+		assert.Equal(t, model.Song{Id: "Id2", Name: "Title2", Duration: 200000, Stream: "/stream/Id2"}, queue.Songs[1])
+		assert.Equal(t, 1, queue.QueuePosition)
+		assert.Equal(t, 12345, queue.TrackPosition)
+	})
+
+	t.Run("PostResume, starts from beginning if current song is not in queue", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(""))
+		mockSubsonicClient := &MockSubsonicClient{playQueue: &subsonic.PlayQueue{Entry: []subsonic.Song{{Id: "Id1"}}, Current: "Id9", Position: 12345}}
+		queue := queue()
+
+		NewPlaylistAPI(queue, mockSubsonicClient, streamDomain).PostResume(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Equal(t, 0, queue.QueuePosition)
+		assert.Equal(t, 0, queue.TrackPosition)
+	})
+
+	t.Run("PostResume, keeps queue without saved play queue", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(""))
+		queue := queue()
+
+		NewPlaylistAPI(queue, &MockSubsonicClient{}, streamDomain).PostResume(mockGinContext)
+
+		assert.Equal(t, 404, responseRecorder.Code)
+		assert.JSONEq(t, `{"status": "error", "message": "Navidrome has no saved play queue"}`, responseRecorder.Body.String())
+		assert.Equal(t, []string{"Id1"}, songIds(queue))
+	})
+
+	t.Run("PostResume, returns Subsonic error", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(""))
+
+		NewPlaylistAPI(queue(), &MockSubsonicClient{err: errors.New("get failed")}, streamDomain).PostResume(mockGinContext)
+
+		assert.Equal(t, 500, responseRecorder.Code)
+	})
+
+	t.Run("PostResume, unavailable without Navidrome access", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(""))
+
+		NewPlaylistAPI(queue(), nil, streamDomain).PostResume(mockGinContext)
+
+		assert.Equal(t, 503, responseRecorder.Code)
+	})
+
+}
+
+func streamDomain() string {
+	return "https://music.example.com"
+}
+
+func importRequest(url string, body string) *http.Request {
+	return httptest.NewRequest("POST", url, strings.NewReader(body))
+}
+
+func streamId(location string) string {
+	id := sha256.Sum256([]byte(location))
+	return hex.EncodeToString(id[:8])
+}
+
+func songIds(queue *model.Queue) (ids []string) {
+	for _, song := range queue.Songs {
+		ids = append(ids, song.Id)
+	}
+	return ids
+}
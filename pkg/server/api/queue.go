@@ -1,14 +1,17 @@
 package api
 
 import (
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/playqueue"
 	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
 	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"net/http"
 )
 
 type QueueAPI struct {
-	Queue *model.Queue
+	Queue     *model.Queue
+	PlayQueue playqueue.IPlayQueueSync // nil if Navidrome access is not configured
 }
 
 func NewQueueAPI(queue *model.Queue) *QueueAPI {
@@ -18,15 +21,26 @@ func NewQueueAPI(queue *model.Queue) *QueueAPI {
 }
 
 func (api *QueueAPI) PostQueue(c *gin.Context) {
-	if err := c.BindJSON(&api.Queue); err != nil {
+	body, err := c.GetRawData() // read before locking the queue, clients may be slow
+	if err == nil {
+		api.Queue.Lock()
+		defer api.Queue.Unlock()
+		err = binding.JSON.BindBody(body, api.Queue)
+	}
+	if err != nil {
 		log.GetRequestContextLogger(c).Error("PostQueue unable to parse request", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
+	if api.PlayQueue != nil {
+		api.PlayQueue.Changed()
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "queue updated"})
 }
 
 func (api *QueueAPI) GetNowPlaying(c *gin.Context) {
+	api.Queue.Lock()
+	defer api.Queue.Unlock()
 	if api.Queue.HasItems() {
 		c.JSON(http.StatusOK, gin.H{
 			"state": api.Queue.State,
@@ -38,5 +52,7 @@ func (api *QueueAPI) GetNowPlaying(c *gin.Context) {
 }
 
 func (api *QueueAPI) GetQueue(c *gin.Context) {
+	api.Queue.Lock()
+	defer api.Queue.Unlock()
 	c.JSON(http.StatusOK, api.Queue)
 }
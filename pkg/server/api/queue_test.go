@@ -97,12 +97,16 @@ func TestQueueAPIPostQueue(t *testing.T) {
 		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(rq))
 		queueUpdated := model.NewQueue()
 
+		playQueue := &MockPlayQueueSync{}
+
 		queueAPI := NewQueueAPI(queueUpdated)
+		queueAPI.PlayQueue = playQueue
 		queueAPI.PostQueue(mockGinContext)
 
 		assert.JSONEq(t, rs, responseRecorder.Body.String())
 		assert.Equal(t, 200, responseRecorder.Code)
 		assert.Equal(t, queue(), queueUpdated)
+		assert.Equal(t, 1, playQueue.changed) // saved to Navidrome play queue
 	})
 
 	t.Run("PostQueue, invalid request", func(t *testing.T) {
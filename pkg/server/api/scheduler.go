@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	apiModel "github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CommandScheduler runs commands one at a time per device with a minimum spacing between them,
+// alexa processes bursts of commands slowly and out of order, so queued commands with the same
+// coalescing key are merged into one (e.g. five "next" clicks become a single jump of five)
+type CommandScheduler struct {
+	MinSpacing  time.Duration
+	HistorySize int
+	devices     map[string]*deviceCommands
+	commands    map[string]*scheduledCommand
+	history     []string // ids of finished commands, oldest first
+	lastID      uint64
+	mutex       sync.Mutex
+}
+
+type deviceCommands struct {
+	pending      []*scheduledCommand
+	running      bool
+	lastFinished time.Time
+}
+
+type scheduledCommand struct {
+	seq     uint64
+	status  apiModel.CommandStatus
+	err     error // executor error of failed command
+	key     string
+	execute func(count int) error
+	done    chan struct{}
+}
+
+// CommandExecutor is called with the number of requests coalesced into a command
+type CommandExecutor func(count int) error
+
+func NewCommandScheduler(minSpacing time.Duration, historySize int) *CommandScheduler {
+	return &CommandScheduler{
+		MinSpacing:  minSpacing,
+		HistorySize: historySize,
+		devices:     make(map[string]*deviceCommands),
+		commands:    make(map[string]*scheduledCommand),
+	}
+}
+
+// Submit queues a command for the device, if the last queued (not yet in-flight) command for the device has
+// the same non-empty key, the command is merged into it: count is incremented and the newer executor is used.
+// Returns command id and a channel closed when command is done.
+func (s *CommandScheduler) Submit(serialNumber string, command string, key string, execute CommandExecutor) (id string, done <-chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	device, exists := s.devices[serialNumber]
+	if !exists {
+		device = &deviceCommands{}
+		s.devices[serialNumber] = device
+	}
+	if key != "" && len(device.pending) > 0 {
+		if last := device.pending[len(device.pending)-1]; last.key == key {
+			last.status.Count++
+			last.execute = execute
+			return last.status.ID, last.done
+		}
+	}
+	s.lastID++
+	scheduled := &scheduledCommand{
+		seq: s.lastID,
+		status: apiModel.CommandStatus{
+			ID:                 fmt.Sprintf("cmd-%d", s.lastID),
+			DeviceSerialNumber: serialNumber,
+			Command:            command,
+			Count:              1,
+			Status:             apiModel.CommandStatusQueued,
+			QueuedAt:           time.Now(),
+		},
+		key:     key,
+		execute: execute,
+		done:    make(chan struct{}),
+	}
+	s.commands[scheduled.status.ID] = scheduled
+	device.pending = append(device.pending, scheduled)
+	if !device.running {
+		device.running = true
+		go s.run(device)
+	}
+	return scheduled.status.ID, scheduled.done
+}
+
+// Status returns a snapshot of the command status, finished commands are kept for the last HistorySize commands
+func (s *CommandScheduler) Status(id string) (status apiModel.CommandStatus, found bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if scheduled, exists := s.commands[id]; exists {
+		return scheduled.status, true
+	}
+	return status, false
+}
+
+// Err returns executor error of failed command, nil if it didn't fail or is not known
+func (s *CommandScheduler) Err(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if scheduled, exists := s.commands[id]; exists {
+		return scheduled.err
+	}
+	return nil
+}
+
+// List returns queued, in-flight and recently finished commands, optionally for a single device
+func (s *CommandScheduler) List(serialNumber string) (output apiModel.CommandsResponse) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	scheduled := make([]*scheduledCommand, 0, len(s.commands))
+	for _, command := range s.commands {
+		if serialNumber == "" || command.status.DeviceSerialNumber == serialNumber {
+			scheduled = append(scheduled, command)
+		}
+	}
+	sort.Slice(scheduled, func(i, j int) bool { return scheduled[i].seq < scheduled[j].seq })
+	output.Commands = make([]apiModel.CommandStatus, 0, len(scheduled))
+	for _, command := range scheduled {
+		output.Commands = append(output.Commands, command.status)
+	}
+	return output
+}
+
+func (s *CommandScheduler) run(device *deviceCommands) {
+	for {
+		s.mutex.Lock()
+		if len(device.pending) == 0 {
+			device.running = false
+			s.mutex.Unlock()
+			return
+		}
+		wait := s.MinSpacing - time.Since(device.lastFinished)
+		s.mutex.Unlock()
+		if wait > 0 { // more commands may get coalesced while waiting
+			time.Sleep(wait)
+		}
+
+		s.mutex.Lock()
+		scheduled := device.pending[0]
+		device.pending = device.pending[1:]
+		startedAt := time.Now()
+		scheduled.status.Status = apiModel.CommandStatusInFlight
+		scheduled.status.StartedAt = &startedAt
+		execute, count := scheduled.execute, scheduled.status.Count
+		s.mutex.Unlock()
+
+		err := execute(count)
+
+		s.mutex.Lock()
+		completedAt := time.Now()
+		scheduled.status.CompletedAt = &completedAt
+		if err != nil {
+			scheduled.status.Status = apiModel.CommandStatusFailed
+			scheduled.status.Error = err.Error()
+			scheduled.err = err
+		} else {
+			scheduled.status.Status = apiModel.CommandStatusCompleted
+		}
+		device.lastFinished = completedAt
+		s.addToHistory(scheduled.status.ID)
+		close(scheduled.done)
+		s.mutex.Unlock()
+	}
+}
+
+func (s *CommandScheduler) addToHistory(id string) {
+	s.history = append(s.history, id)
+	for len(s.history) > s.HistorySize {
+		delete(s.commands, s.history[0])
+		s.history = s.history[1:]
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	apiModel "github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCommandScheduler(t *testing.T) {
+
+	t.Run("Submit executes command and reports completed status", func(t *testing.T) {
+		scheduler := NewCommandScheduler(0, 10)
+
+		id, done := scheduler.Submit("sn", "play", "play", func(count int) error { return nil })
+		waitDone(t, done)
+
+		status, found := scheduler.Status(id)
+		require.True(t, found)
+		assert.Equal(t, apiModel.CommandStatusCompleted, status.Status)
+		assert.Equal(t, "sn", status.DeviceSerialNumber)
+		assert.Equal(t, "play", status.Command)
+		assert.Equal(t, 1, status.Count)
+		assert.NotNil(t, status.StartedAt)
+		assert.NotNil(t, status.CompletedAt)
+	})
+
+	t.Run("Submit reports failed status", func(t *testing.T) {
+		scheduler := NewCommandScheduler(0, 10)
+
+		id, done := scheduler.Submit("sn", "play", "play", func(count int) error { return errors.New("mock error") })
+		waitDone(t, done)
+
+		status, _ := scheduler.Status(id)
+		assert.Equal(t, apiModel.CommandStatusFailed, status.Status)
+		assert.Equal(t, "mock error", status.Error)
+	})
+
+	t.Run("Submit coalesces queued commands with the same key while one is in flight", func(t *testing.T) {
+		scheduler := NewCommandScheduler(0, 10)
+		release := make(chan struct{})
+		var counts []int
+		var mutex sync.Mutex
+		execute := func(count int) error {
+			<-release
+			mutex.Lock()
+			defer mutex.Unlock()
+			counts = append(counts, count)
+			return nil
+		}
+
+		firstID, firstDone := scheduler.Submit("sn", "next", "next", execute)
+		waitStatus(t, scheduler, firstID, apiModel.CommandStatusInFlight)
+		var ids []string
+		var done <-chan struct{}
+		for i := 0; i < 5; i++ {
+			var id string
+			id, done = scheduler.Submit("sn", "next", "next", execute)
+			ids = append(ids, id)
+		}
+		queued, _ := scheduler.Status(ids[0])
+		assert.Equal(t, apiModel.CommandStatusQueued, queued.Status)
+		close(release)
+		waitDone(t, firstDone)
+		waitDone(t, done)
+
+		assert.Equal(t, []int{1, 5}, counts)
+		for _, id := range ids {
+			assert.Equal(t, ids[0], id)
+		}
+		status, _ := scheduler.Status(ids[0])
+		assert.Equal(t, 5, status.Count)
+		assert.Len(t, scheduler.List("sn").Commands, 2)
+	})
+
+	t.Run("Submit does not coalesce different keys and keeps order", func(t *testing.T) {
+		scheduler := NewCommandScheduler(0, 10)
+		release := make(chan struct{})
+		var order []string
+		var mutex sync.Mutex
+		execute := func(name string) CommandExecutor {
+			return func(count int) error {
+				<-release
+				mutex.Lock()
+				defer mutex.Unlock()
+				order = append(order, name)
+				return nil
+			}
+		}
+
+		firstID, _ := scheduler.Submit("sn", "play", "play", execute("play"))
+		waitStatus(t, scheduler, firstID, apiModel.CommandStatusInFlight)
+		scheduler.Submit("sn", "next", "next", execute("next"))
+		scheduler.Submit("sn", "stop", "stop", execute("stop"))
+		_, done := scheduler.Submit("sn", "next", "next", execute("next"))
+		close(release)
+		waitDone(t, done)
+
+		assert.Equal(t, []string{"play", "next", "stop", "next"}, order)
+	})
+
+	t.Run("Submit enforces minimum spacing between commands of a device", func(t *testing.T) {
+		scheduler := NewCommandScheduler(50*time.Millisecond, 10)
+
+		firstID, firstDone := scheduler.Submit("sn", "play", "", func(count int) error { return nil })
+		waitDone(t, firstDone)
+		secondID, secondDone := scheduler.Submit("sn", "stop", "", func(count int) error { return nil })
+		waitDone(t, secondDone)
+
+		first, _ := scheduler.Status(firstID)
+		second, _ := scheduler.Status(secondID)
+		assert.GreaterOrEqual(t, second.StartedAt.Sub(*first.CompletedAt), 50*time.Millisecond)
+	})
+
+	t.Run("Submit runs commands for different devices independently", func(t *testing.T) {
+		scheduler := NewCommandScheduler(0, 10)
+		release := make(chan struct{})
+		defer close(release)
+
+		blockedID, _ := scheduler.Submit("sn1", "play", "", func(count int) error { <-release; return nil })
+		waitStatus(t, scheduler, blockedID, apiModel.CommandStatusInFlight)
+		_, done := scheduler.Submit("sn2", "play", "", func(count int) error { return nil })
+
+		waitDone(t, done)
+		assert.Len(t, scheduler.List("sn1").Commands, 1)
+		assert.Len(t, scheduler.List("").Commands, 2)
+	})
+
+	t.Run("Finished commands are kept up to history size", func(t *testing.T) {
+		scheduler := NewCommandScheduler(0, 2)
+
+		var ids []string
+		for i := 0; i < 3; i++ {
+			id, done := scheduler.Submit("sn", "play", "", func(count int) error { return nil })
+			waitDone(t, done)
+			ids = append(ids, id)
+		}
+
+		_, found := scheduler.Status(ids[0])
+		assert.False(t, found)
+		commands := scheduler.List("").Commands
+		require.Len(t, commands, 2)
+		assert.Equal(t, ids[1], commands[0].ID)
+		assert.Equal(t, ids[2], commands[1].ID)
+	})
+}
+
+func waitDone(t *testing.T, done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "command was not completed in time")
+	}
+}
+
+func waitStatus(t *testing.T, scheduler *CommandScheduler, id string, expected interface{}) {
+	require.Eventually(t, func() bool {
+		status, _ := scheduler.Status(id)
+		return status.Status == expected
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"github.com/ahimgit/navidrome-alexa/pkg/server/mid"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const minApiKeySize = 16
+
+var skillIdFormat = regexp.MustCompile(`^amzn1\.ask\.skill\.[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+var corsMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+var apiKeyHashFormat = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// Errors lists all config values that prevent starting, empty if config is valid
+func (config *Config) Errors() (errors []string) {
+	required := []struct{ name, value string }{
+		{"amazonDomain", config.AmazonDomain},
+		{"amazonCookiePath", config.AmazonCookiePath},
+		{"streamDomain", config.StreamDomain},
+		{"alexaSkillId", config.AlexaSkillId},
+		{"alexaSkillName", config.AlexaSkillName},
+		{"listenAddress", config.ListenAddress},
+	}
+	for _, param := range required {
+		if param.value == "" {
+			errors = append(errors, param.name+" is required")
+		}
+	}
+	if config.ApiKey == "" && len(config.ApiKeys) == 0 && !config.NavidromeAuth {
+		errors = append(errors, "apiKey or apiKeys is required")
+	}
+	errors = append(errors, apiKeysErrors(config.ApiKeys)...)
+	if config.AmazonDomain != "" && strings.ContainsAny(config.AmazonDomain, "/:") {
+		errors = append(errors, "amazonDomain must be a domain name without scheme or path, e.g. amazon.com")
+	}
+	if config.StreamDomain != "" && !isAbsoluteHttpURL(config.StreamDomain) {
+		errors = append(errors, "streamDomain must be an absolute http(s) URL, e.g. https://navidrome.example.com")
+	}
+	if config.NavidromeURL != "" && !isAbsoluteHttpURL(config.NavidromeURL) {
+		errors = append(errors, "navidromeURL must be an absolute http(s) URL, e.g. http://localhost:4533")
+	}
+	if config.NavidromeUser != "" && config.NavidromePassword == "" {
+		errors = append(errors, "navidromePassword is required with navidromeUser")
+	}
+	if config.NavidromeProxy && config.NavidromeURL == "" {
+		errors = append(errors, "navidromeProxy requires navidromeURL, e.g. http://localhost:4533")
+	}
+	for _, host := range config.WidgetProxyHosts {
+		hostPort := strings.TrimSuffix(host, ":*") // any port
+		if parsed, err := url.Parse("http://" + hostPort); err != nil || parsed.Host != hostPort || hostPort == "" {
+			errors = append(errors, "widgetProxyHosts "+host+" must be host, host:port or host:* (any port), e.g. localhost:4533")
+		}
+	}
+	errors = append(errors, corsErrors(config)...)
+	if config.TracingEndpoint != "" && !isAbsoluteHttpURL(config.TracingEndpoint) {
+		errors = append(errors, "tracingEndpoint must be an absolute http(s) URL, e.g. http://localhost:4318")
+	}
+	if config.AlexaSkillId != "" && !skillIdFormat.MatchString(config.AlexaSkillId) {
+		errors = append(errors, "alexaSkillId must look like amzn1.ask.skill.xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx")
+	}
+	if config.ListenAddress != "" && !isListenAddress(config.ListenAddress) {
+		errors = append(errors, "listenAddress must be [host]:port, e.g. :8080")
+	}
+	errors = append(errors, tlsErrors(config)...)
+	if config.LogLevel != "" {
+		if err := new(slog.Level).UnmarshalText([]byte(config.LogLevel)); err != nil {
+			errors = append(errors, "logLevel must be one of debug, info, warn, error")
+		}
+	}
+	return errors
+}
+
+// Level is slog level for logLevel, debug if not set
+func (config *Config) Level() (level slog.Level) {
+	if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		return slog.LevelDebug
+	}
+	return level
+}
+
+// Problems lists config values that look wrong but don't prevent starting, reported by health status
+func (config *Config) Problems() (problems []string) {
+	if streamURL, err := url.Parse(config.StreamDomain); err == nil && streamURL.Scheme != "https" {
+		problems = append(problems, "streamDomain is not https, Alexa only plays https streams")
+	}
+	if config.ApiKey != "" && len(config.ApiKey) < minApiKeySize {
+		problems = append(problems, "apiKey is shorter than 16 characters")
+	}
+	return problems
+}
+
+// Cors is CORS policy, origins default to streamDomain origin (widget runs in Navidrome UI), methods and headers to mid defaults
+func (config *Config) Cors() mid.CorsConfig {
+	cors := mid.CorsConfig{
+		AllowOrigins:     config.CorsAllowOrigins,
+		AllowMethods:     config.CorsAllowMethods,
+		AllowHeaders:     config.CorsAllowHeaders,
+		AllowCredentials: config.CorsAllowCredentials,
+	}
+	if len(cors.AllowOrigins) == 0 {
+		if streamURL, err := url.Parse(config.StreamDomain); err == nil && streamURL.Host != "" {
+			cors.AllowOrigins = []string{streamURL.Scheme + "://" + streamURL.Host}
+		}
+	}
+	if len(cors.AllowMethods) == 0 {
+		cors.AllowMethods = mid.DefaultCorsMethods
+	}
+	if len(cors.AllowHeaders) == 0 {
+		cors.AllowHeaders = mid.DefaultCorsHeaders
+	}
+	return cors
+}
+
+func corsErrors(config *Config) (errors []string) {
+	for _, origin := range config.CorsAllowOrigins {
+		if !isCorsOrigin(origin) {
+			errors = append(errors, "corsAllowOrigins "+origin+" must be * or scheme://host[:port] with at most one *, e.g. https://navidrome.example.com")
+		}
+	}
+	if config.CorsAllowCredentials && mid.HasWildcardOrigin(config.CorsAllowOrigins) {
+		errors = append(errors, "corsAllowOrigins with * can't be used with corsAllowCredentials, list origins explicitly")
+	}
+	for _, method := range config.CorsAllowMethods {
+		if !slices.Contains(corsMethods, method) {
+			errors = append(errors, "corsAllowMethods "+method+" must be one of "+strings.Join(corsMethods, ", "))
+		}
+	}
+	for _, header := range config.CorsAllowHeaders {
+		if header == "" || strings.ContainsAny(header, " ,:*") {
+			errors = append(errors, "corsAllowHeaders "+header+" must be a header name")
+		}
+	}
+	return errors
+}
+
+func tlsErrors(config *Config) (errors []string) {
+	if (config.TlsCertFile == "") != (config.TlsKeyFile == "") {
+		errors = append(errors, "tlsCertFile and tlsKeyFile must be set together")
+	}
+	if config.TlsMinVersion != "" {
+		if _, exists := tlsVersions[config.TlsMinVersion]; !exists {
+			errors = append(errors, "tlsMinVersion must be 1.2 or 1.3")
+		}
+	}
+	if config.HttpRedirectAddress != "" {
+		if config.TlsCertFile == "" {
+			errors = append(errors, "httpRedirectAddress requires tlsCertFile and tlsKeyFile")
+		}
+		if !isListenAddress(config.HttpRedirectAddress) {
+			errors = append(errors, "httpRedirectAddress must be [host]:port, e.g. :80")
+		}
+	}
+	return errors
+}
+
+func isCorsOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	if strings.Count(origin, "*") > 1 {
+		return false
+	}
+	parsed, err := url.Parse(strings.Replace(origin, "*", "wildcard", 1))
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" &&
+		parsed.Path == "" && parsed.RawQuery == "" && parsed.User == nil
+}
+
+func apiKeysErrors(keys []ApiKeyConfig) (errors []string) {
+	names := map[string]bool{}
+	for i, key := range keys {
+		name := key.Name
+		if name == "" {
+			name = "#" + strconv.Itoa(i+1)
+			errors = append(errors, "apiKeys "+name+" name is required")
+		} else if names[name] {
+			errors = append(errors, "apiKeys "+name+" name is not unique")
+		}
+		names[name] = true
+		if !apiKeyHashFormat.MatchString(key.Hash) {
+			errors = append(errors, "apiKeys "+name+" hash must be hex encoded SHA-256")
+		}
+		if len(key.Scopes) == 0 {
+			errors = append(errors, "apiKeys "+name+" scopes are required")
+		}
+		for _, scope := range key.Scopes {
+			if !slices.Contains(mid.Scopes, scope) {
+				errors = append(errors, "apiKeys "+name+" scope "+scope+" must be one of "+strings.Join(mid.Scopes, ", "))
+			}
+		}
+	}
+	return errors
+}
+
+func isAbsoluteHttpURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func isListenAddress(value string) bool {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return false
+	}
+	number, err := strconv.Atoi(port)
+	return err == nil && number >= 0 && number <= 65535
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"github.com/ahimgit/navidrome-alexa/pkg/server/mid"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func validConfig() *Config {
+	return &Config{
+		AmazonDomain:     "amazon.com",
+		AmazonCookiePath: "cookies.data",
+		AlexaSkillId:     "amzn1.ask.skill.0a1b2c3d-0000-0000-0000-00000000abcd",
+		AlexaSkillName:   "navi stream",
+		StreamDomain:     "https://music.example.com",
+		ApiKey:           "0123456789abcdef",
+		ListenAddress:    ":8080",
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+
+	t.Run("Errors, valid config", func(t *testing.T) {
+		assert.Empty(t, validConfig().Errors())
+	})
+
+	t.Run("Errors, reports all missing params", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"amazonDomain is required",
+			"amazonCookiePath is required",
+			"streamDomain is required",
+			"alexaSkillId is required",
+			"alexaSkillName is required",
+			"listenAddress is required",
+			"apiKey or apiKeys is required",
+		}, (&Config{}).Errors())
+	})
+
+	t.Run("Errors, reports all invalid values", func(t *testing.T) {
+		config := validConfig()
+		config.AmazonDomain = "https://amazon.com"
+		config.StreamDomain = "music.example.com"
+		config.TracingEndpoint = "localhost:4318"
+		config.AlexaSkillId = "skill"
+		config.ListenAddress = "8080"
+		assert.Equal(t, []string{
+			"amazonDomain must be a domain name without scheme or path, e.g. amazon.com",
+			"streamDomain must be an absolute http(s) URL, e.g. https://navidrome.example.com",
+			"tracingEndpoint must be an absolute http(s) URL, e.g. http://localhost:4318",
+			"alexaSkillId must look like amzn1.ask.skill.xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx",
+			"listenAddress must be [host]:port, e.g. :8080",
+		}, config.Errors())
+	})
+
+	t.Run("Errors, named api keys instead of apiKey", func(t *testing.T) {
+		config := validConfig()
+		config.ApiKey = ""
+		config.ApiKeys = []ApiKeyConfig{{Name: "widget", Hash: strings.Repeat("ab", 32), Scopes: []string{"read"}}}
+		assert.Empty(t, config.Errors())
+	})
+
+	t.Run("Errors, Navidrome auth instead of api key", func(t *testing.T) {
+		config := validConfig()
+		config.ApiKey = ""
+		config.NavidromeAuth = true
+		assert.Empty(t, config.Errors())
+		config.NavidromeURL = "localhost:4533"
+		assert.Equal(t, []string{"navidromeURL must be an absolute http(s) URL, e.g. http://localhost:4533"}, config.Errors())
+	})
+
+	t.Run("Errors, Navidrome proxy needs Navidrome URL", func(t *testing.T) {
+		config := validConfig()
+		config.NavidromeProxy = true
+		assert.Equal(t, []string{"navidromeProxy requires navidromeURL, e.g. http://localhost:4533"}, config.Errors())
+		config.NavidromeURL = "http://localhost:4533"
+		assert.Empty(t, config.Errors())
+	})
+
+	t.Run("Errors, Navidrome user needs password", func(t *testing.T) {
+		config := validConfig()
+		config.NavidromeUser = "alexa"
+		assert.Equal(t, []string{"navidromePassword is required with navidromeUser"}, config.Errors())
+		config.NavidromePassword = "secret"
+		assert.Empty(t, config.Errors())
+	})
+
+	t.Run("Errors, widget proxy hosts", func(t *testing.T) {
+		config := validConfig()
+		config.WidgetProxyHosts = []string{"localhost:4533", "navidrome.example.com", "navidrome:*", "http://localhost:4533", "localhost/app"}
+		assert.Equal(t, []string{
+			"widgetProxyHosts http://localhost:4533 must be host, host:port or host:* (any port), e.g. localhost:4533",
+			"widgetProxyHosts localhost/app must be host, host:port or host:* (any port), e.g. localhost:4533",
+		}, config.Errors())
+	})
+
+	t.Run("Errors, invalid cors policy", func(t *testing.T) {
+		config := validConfig()
+		config.CorsAllowOrigins = []string{"*", "https://*.*.example.com", "music.example.com", "https://music.example.com/app"}
+		config.CorsAllowMethods = []string{"GET", "FETCH"}
+		config.CorsAllowHeaders = []string{"Authorization", "X Token"}
+		config.CorsAllowCredentials = true
+		assert.Equal(t, []string{
+			"corsAllowOrigins https://*.*.example.com must be * or scheme://host[:port] with at most one *, e.g. https://navidrome.example.com",
+			"corsAllowOrigins music.example.com must be * or scheme://host[:port] with at most one *, e.g. https://navidrome.example.com",
+			"corsAllowOrigins https://music.example.com/app must be * or scheme://host[:port] with at most one *, e.g. https://navidrome.example.com",
+			"corsAllowOrigins with * can't be used with corsAllowCredentials, list origins explicitly",
+			"corsAllowMethods FETCH must be one of GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS",
+			"corsAllowHeaders X Token must be a header name",
+		}, config.Errors())
+	})
+
+	t.Run("Errors, cors credentials with explicit and wildcard origins", func(t *testing.T) {
+		config := validConfig()
+		config.CorsAllowOrigins = []string{"https://music.example.com", "http://localhost:4533"}
+		config.CorsAllowCredentials = true
+		assert.Empty(t, config.Errors())
+		config.CorsAllowOrigins = append(config.CorsAllowOrigins, "https://*.example.com")
+		assert.Equal(t, []string{"corsAllowOrigins with * can't be used with corsAllowCredentials, list origins explicitly"}, config.Errors())
+	})
+
+	t.Run("Errors, tls", func(t *testing.T) {
+		config := validConfig()
+		config.TlsCertFile = "cert.pem"
+		config.TlsKeyFile = "key.pem"
+		config.TlsMinVersion = "1.3"
+		config.HttpRedirectAddress = ":80"
+		assert.Empty(t, config.Errors())
+		config.TlsKeyFile = ""
+		config.TlsMinVersion = "1.1"
+		config.HttpRedirectAddress = "80"
+		assert.Equal(t, []string{
+			"tlsCertFile and tlsKeyFile must be set together",
+			"tlsMinVersion must be 1.2 or 1.3",
+			"httpRedirectAddress must be [host]:port, e.g. :80",
+		}, config.Errors())
+	})
+
+	t.Run("Errors, http redirect without tls", func(t *testing.T) {
+		config := validConfig()
+		config.HttpRedirectAddress = ":80"
+		assert.Equal(t, []string{"httpRedirectAddress requires tlsCertFile and tlsKeyFile"}, config.Errors())
+	})
+
+	t.Run("Errors, invalid api keys", func(t *testing.T) {
+		config := validConfig()
+		config.ApiKeys = []ApiKeyConfig{
+			{Name: "widget", Hash: strings.Repeat("ab", 32), Scopes: []string{"read"}},
+			{Name: "widget", Hash: "plainKey", Scopes: []string{"read", "write"}},
+			{Hash: strings.Repeat("ab", 32)},
+		}
+		assert.Equal(t, []string{
+			"apiKeys widget name is not unique",
+			"apiKeys widget hash must be hex encoded SHA-256",
+			"apiKeys widget scope write must be one of read, control, admin",
+			"apiKeys #3 name is required",
+			"apiKeys #3 scopes are required",
+		}, config.Errors())
+	})
+
+	t.Run("Errors, unknown log level", func(t *testing.T) {
+		config := validConfig()
+		config.LogLevel = "verbose"
+		assert.Equal(t, []string{"logLevel must be one of debug, info, warn, error"}, config.Errors())
+	})
+
+	t.Run("Errors, port out of range", func(t *testing.T) {
+		config := validConfig()
+		config.ListenAddress = "localhost:99999"
+		assert.Equal(t, []string{"listenAddress must be [host]:port, e.g. :8080"}, config.Errors())
+	})
+
+}
+
+func TestConfigLevel(t *testing.T) {
+	assert.Equal(t, slog.LevelDebug, (&Config{}).Level())
+	assert.Equal(t, slog.LevelWarn, (&Config{LogLevel: "warn"}).Level())
+	assert.Equal(t, slog.LevelError, (&Config{LogLevel: "ERROR"}).Level())
+}
+
+func TestConfigCors(t *testing.T) {
+
+	t.Run("Cors, defaults to stream domain origin", func(t *testing.T) {
+		config := validConfig()
+		config.StreamDomain = "https://music.example.com:8443/navidrome/"
+		assert.Equal(t, mid.CorsConfig{
+			AllowOrigins: []string{"https://music.example.com:8443"},
+			AllowMethods: mid.DefaultCorsMethods,
+			AllowHeaders: mid.DefaultCorsHeaders,
+		}, config.Cors())
+	})
+
+	t.Run("Cors, configured policy", func(t *testing.T) {
+		config := validConfig()
+		config.CorsAllowOrigins = []string{"https://music.example.com", "http://localhost:4533"}
+		config.CorsAllowMethods = []string{"GET"}
+		config.CorsAllowHeaders = []string{"Authorization"}
+		config.CorsAllowCredentials = true
+		assert.Equal(t, mid.CorsConfig{
+			AllowOrigins:     []string{"https://music.example.com", "http://localhost:4533"},
+			AllowMethods:     []string{"GET"},
+			AllowHeaders:     []string{"Authorization"},
+			AllowCredentials: true,
+		}, config.Cors())
+	})
+
+}
+
+func TestConfigProblems(t *testing.T) {
+
+	t.Run("Problems, valid config", func(t *testing.T) {
+		assert.Empty(t, validConfig().Problems())
+	})
+
+	t.Run("Problems, plain http stream and short api key", func(t *testing.T) {
+		config := validConfig()
+		config.StreamDomain = "http://music.example.com"
+		config.ApiKey = "key"
+		assert.Equal(t, []string{
+			"streamDomain is not https, Alexa only plays https streams",
+			"apiKey is shorter than 16 characters",
+		}, config.Problems())
+	})
+
+}
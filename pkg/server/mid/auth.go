@@ -1,43 +1,171 @@
 package mid
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strings"
 )
 
-func ApiKeyAuthMiddleware(pathPrefix string, apiKey string) gin.HandlerFunc {
+const (
+	ScopeRead    = "read"    // queue, playing, devices
+	ScopeControl = "control" // play, stop, volume, queue changes
+	ScopeAdmin   = "admin"   // config reload
+)
+
+var Scopes = []string{ScopeRead, ScopeControl, ScopeAdmin}
+
+const apiKeyContextKey = "ApiKey"
+const usernameContextKey = "Username"
+
+// navidromeUserKey is used for calls authenticated with Navidrome user credentials, users can't administer NA
+var navidromeUserKey = ApiKey{Name: "navidrome", Scopes: []string{ScopeRead, ScopeControl}}
+
+// ApiKey is a named key known only by its SHA-256 hash
+type ApiKey struct {
+	Name   string
+	Hash   string // hex encoded SHA-256 of the key
+	Scopes []string
+}
+
+func HashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func (key *ApiKey) HasScope(scope string) bool {
+	for _, keyScope := range key.Scopes {
+		if keyScope == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ApiKeyAuthMiddleware authenticates calls under path prefix with one of the keys passed as Bearer token
+// (or apiKey query param if allowed), or with Navidrome user credentials if subsonicAuth is set.
+// Writes audit log entry with key name (and username) for each authenticated call
+func ApiKeyAuthMiddleware(pathPrefix string, keys []ApiKey, allowQuery bool, subsonicAuth *SubsonicAuth) gin.HandlerFunc {
+	hashes := make([][]byte, len(keys))
+	for i, key := range keys {
+		hashes[i], _ = hex.DecodeString(key.Hash) // validated with config
+	}
 	return func(context *gin.Context) {
-		if strings.HasPrefix(context.Request.URL.Path, pathPrefix) {
-			authParam := context.Query("apiKey")
-			if authParam != "" {
-				if authParam != apiKey {
-					log.GetRequestContextLogger(context).Error("Auth incorrect api key, unauthorized")
-					context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Unauthorized"})
-					return
-				}
-			} else {
-				authHeader := context.GetHeader("Authorization")
-				if authHeader == "" {
-					log.GetRequestContextLogger(context).Error("Auth empty, unauthorized")
-					context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Unauthorized"})
-					return
-				}
-				authHeaderParts := strings.SplitN(authHeader, " ", 2)
-				if !(len(authHeaderParts) == 2 && authHeaderParts[0] == "Bearer") {
-					log.GetRequestContextLogger(context).Error("Auth incorrect auth type, unauthorized")
-					context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Unauthorized"})
-					return
-				}
-				if authHeaderParts[1] != apiKey {
-					log.GetRequestContextLogger(context).Error("Auth incorrect api key, unauthorized")
-					context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Unauthorized"})
-					return
-				}
-			}
+		if !strings.HasPrefix(context.Request.URL.Path, pathPrefix) {
+			context.Next()
+			return
+		}
+		var key *ApiKey
+		if subsonicAuth != nil && context.GetHeader(SubsonicUserHeader) != "" {
+			key = authenticateNavidromeUser(context, subsonicAuth)
+		} else {
+			key = authenticateApiKey(context, keys, hashes, allowQuery)
+		}
+		if key == nil {
+			return // aborted
+		}
+		context.Set(apiKeyContextKey, key)
+
+		context.Next() // chain
+
+		log.GetRequestContextLogger(context).Info("Audit",
+			"ApiKey", key.Name,
+			"RequestMethod", context.Request.Method,
+			"RequestPath", context.Request.URL.Path,
+			"ResponseStatus", context.Writer.Status(),
+			"ClientIP", context.ClientIP())
+	}
+}
+
+func authenticateApiKey(context *gin.Context, keys []ApiKey, hashes [][]byte, allowQuery bool) *ApiKey {
+	presented, ok := presentedApiKey(context, allowQuery)
+	if !ok {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Unauthorized"})
+		return nil
+	}
+	presentedHash := sha256.Sum256([]byte(presented))
+	key := matchApiKey(keys, hashes, presentedHash[:])
+	if key == nil {
+		log.GetRequestContextLogger(context).Error("Auth incorrect api key, unauthorized")
+		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Unauthorized"})
+	}
+	return key
+}
+
+func authenticateNavidromeUser(context *gin.Context, subsonicAuth *SubsonicAuth) *ApiKey {
+	user := context.GetHeader(SubsonicUserHeader)
+	valid, err := subsonicAuth.Verify(context.Request.Context(), user, context.GetHeader(SubsonicTokenHeader), context.GetHeader(SubsonicSaltHeader))
+	if err != nil {
+		log.GetRequestContextLogger(context).Error("Auth unable to verify Navidrome user", "User", user, "error", err)
+		context.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"status": "error", "message": "Unable to verify Navidrome user"})
+		return nil
+	}
+	if !valid {
+		log.GetRequestContextLogger(context).Error("Auth incorrect Navidrome user credentials, unauthorized", "User", user)
+		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Unauthorized"})
+		return nil
+	}
+	context.Set(usernameContextKey, user)
+	log.AddRequestContextLoggerAttrs(context, "User", user)
+	return &navidromeUserKey
+}
+
+// RequireScope rejects calls authenticated with a key that lacks the scope, use on routes after ApiKeyAuthMiddleware
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		key := GetApiKey(context)
+		if key == nil || !key.HasScope(scope) {
+			log.GetRequestContextLogger(context).Error("Auth api key lacks scope, forbidden", "scope", scope)
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "error", "message": "Forbidden"})
+			return
 		}
 		context.Next()
 	}
+}
+
+// GetApiKey returns the key call was authenticated with, nil if none
+func GetApiKey(context *gin.Context) *ApiKey {
+	if value, exists := context.Get(apiKeyContextKey); exists {
+		return value.(*ApiKey)
+	}
+	return nil
+}
+
+// GetUsername returns Navidrome username for calls authenticated with Navidrome user credentials, empty otherwise
+func GetUsername(context *gin.Context) string {
+	return context.GetString(usernameContextKey)
+}
 
+func presentedApiKey(context *gin.Context, allowQuery bool) (string, bool) {
+	if queryKey := context.Query("apiKey"); queryKey != "" {
+		if !allowQuery {
+			log.GetRequestContextLogger(context).Error("Auth api key in query is disabled, unauthorized")
+			return "", false
+		}
+		return queryKey, true
+	}
+	authHeader := context.GetHeader("Authorization")
+	if authHeader == "" {
+		log.GetRequestContextLogger(context).Error("Auth empty, unauthorized")
+		return "", false
+	}
+	authHeaderParts := strings.SplitN(authHeader, " ", 2)
+	if !(len(authHeaderParts) == 2 && authHeaderParts[0] == "Bearer") {
+		log.GetRequestContextLogger(context).Error("Auth incorrect auth type, unauthorized")
+		return "", false
+	}
+	return authHeaderParts[1], true
+}
+
+func matchApiKey(keys []ApiKey, hashes [][]byte, presentedHash []byte) *ApiKey {
+	var matched *ApiKey
+	for i := range keys { // no early return, time doesn't depend on which key matched
+		if subtle.ConstantTimeCompare(hashes[i], presentedHash) == 1 {
+			matched = &keys[i]
+		}
+	}
+	return matched
 }
@@ -0,0 +1,119 @@
+package mid
+
+import (
+	"bytes"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tests"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+var testApiKeys = []ApiKey{
+	{Name: "widget", Hash: HashApiKey("readKey"), Scopes: []string{ScopeRead}},
+	{Name: "remote", Hash: HashApiKey("controlKey"), Scopes: []string{ScopeRead, ScopeControl}},
+}
+
+func TestApiKeyAuthMiddleware(t *testing.T) {
+
+	testCases := []struct {
+		name       string
+		url        string
+		header     string
+		allowQuery bool
+		key        string
+		status     int
+	}{
+		{"bearer key", "/api/queue", "Bearer controlKey", false, "remote", 200},
+		{"second bearer key", "/api/queue", "Bearer readKey", false, "widget", 200},
+		{"query key allowed", "/api/queue?apiKey=readKey", "", true, "widget", 200},
+		{"query key disabled", "/api/queue?apiKey=readKey", "", false, "", 401},
+		{"unknown key", "/api/queue", "Bearer otherKey", true, "", 401},
+		{"no key", "/api/queue", "", true, "", 401},
+		{"wrong auth type", "/api/queue", "Basic readKey", true, "", 401},
+		{"path not protected", "/skill", "", false, "", 200},
+	}
+
+	for _, testCase := range testCases {
+		t.Run("ApiKeyAuthMiddleware, "+testCase.name, func(t *testing.T) {
+			request := tests.MockJSONGet(testCase.url)
+			if testCase.header != "" {
+				request.Header.Set("Authorization", testCase.header)
+			}
+			mockGinContext, responseRecorder := tests.MockGin(request)
+
+			ApiKeyAuthMiddleware("/api/", testApiKeys, testCase.allowQuery, nil)(mockGinContext)
+
+			assert.Equal(t, testCase.status, responseRecorder.Code)
+			assert.Equal(t, testCase.status != 200, mockGinContext.IsAborted())
+			if testCase.key != "" {
+				assert.Equal(t, testCase.key, GetApiKey(mockGinContext).Name)
+			} else {
+				assert.Nil(t, GetApiKey(mockGinContext))
+			}
+		})
+	}
+
+	t.Run("ApiKeyAuthMiddleware, audit logs key name", func(t *testing.T) {
+		logs := new(bytes.Buffer)
+		defaultLogger := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(logs, nil)))
+		defer slog.SetDefault(defaultLogger)
+		request := tests.MockJSONPost("")
+		request.URL.Path = "/api/play"
+		request.Header.Set("Authorization", "Bearer controlKey")
+		mockGinContext, _ := tests.MockGin(request)
+
+		ApiKeyAuthMiddleware("/api/", testApiKeys, false, nil)(mockGinContext)
+
+		assert.Contains(t, logs.String(), "msg=Audit")
+		assert.Contains(t, logs.String(), "ApiKey=remote RequestMethod=POST RequestPath=/api/play ResponseStatus=200")
+	})
+
+}
+
+func TestRequireScope(t *testing.T) {
+
+	testCases := []struct {
+		name   string
+		key    string
+		scope  string
+		status int
+	}{
+		{"read key can read", "readKey", ScopeRead, 200},
+		{"read key can't control", "readKey", ScopeControl, 403},
+		{"control key can control", "controlKey", ScopeControl, 200},
+		{"control key is not admin", "controlKey", ScopeAdmin, 403},
+	}
+
+	for _, testCase := range testCases {
+		t.Run("RequireScope, "+testCase.name, func(t *testing.T) {
+			called := false
+			engine := gin.New()
+			engine.Use(ApiKeyAuthMiddleware("/api/", testApiKeys, false, nil))
+			engine.GET("/api/test", RequireScope(testCase.scope), func(context *gin.Context) { called = true })
+			request := tests.MockJSONGet("/api/test")
+			request.Header.Set("Authorization", "Bearer "+testCase.key)
+			_, responseRecorder := tests.MockGin(request)
+
+			engine.ServeHTTP(responseRecorder, request)
+
+			assert.Equal(t, testCase.status, responseRecorder.Code)
+			assert.Equal(t, testCase.status == 200, called)
+		})
+	}
+
+	t.Run("RequireScope, unauthenticated call", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/api/test"))
+
+		RequireScope(ScopeRead)(mockGinContext)
+
+		assert.Equal(t, http.StatusForbidden, responseRecorder.Code)
+	})
+
+}
+
+func TestHashApiKey(t *testing.T) {
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", HashApiKey("hello"))
+}
@@ -3,14 +3,41 @@ package mid
 import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
 )
 
-func CorsMiddleware() gin.HandlerFunc {
+var DefaultCorsMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+var DefaultCorsHeaders = []string{"Origin", "Authorization", "Content-Type", SubsonicUserHeader, SubsonicTokenHeader, SubsonicSaltHeader}
+
+// CorsConfig lists what cross-origin callers (widget running in Navidrome UI) are allowed to do.
+// Origins are exact (https://navidrome.example.com), with one * wildcard (https://*.example.com) or * for any
+type CorsConfig struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	AllowCredentials bool
+}
+
+// CorsMiddleware config has to be validated, wildcard origins with credentials are not accepted by browsers anyway
+func CorsMiddleware(config CorsConfig) gin.HandlerFunc {
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
+		AllowOrigins:     config.AllowOrigins,
+		AllowWildcard:    HasWildcardOrigin(config.AllowOrigins),
+		AllowMethods:     config.AllowMethods,
+		AllowHeaders:     config.AllowHeaders,
 		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
+		AllowCredentials: config.AllowCredentials,
+		MaxAge:           time.Hour,
 	})
 }
+
+func HasWildcardOrigin(origins []string) bool {
+	for _, origin := range origins {
+		if strings.Contains(origin, "*") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package mid
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorsMiddleware(t *testing.T) {
+
+	config := CorsConfig{
+		AllowOrigins: []string{"https://music.example.com", "https://*.home.example.com"},
+		AllowMethods: DefaultCorsMethods,
+		AllowHeaders: DefaultCorsHeaders,
+	}
+
+	t.Run("CorsMiddleware, preflight from allowed origin", func(t *testing.T) {
+		recorder := corsRequest(config, http.MethodOptions, "https://music.example.com")
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		assert.Equal(t, "https://music.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET,POST,PUT,PATCH,DELETE", recorder.Header().Get("Access-Control-Allow-Methods"))
+		assert.Contains(t, recorder.Header().Get("Access-Control-Allow-Headers"), "X-Subsonic-Token")
+		assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("CorsMiddleware, wildcard origin", func(t *testing.T) {
+		recorder := corsRequest(config, http.MethodGet, "https://navidrome.home.example.com")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "https://navidrome.home.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("CorsMiddleware, origin not allowed", func(t *testing.T) {
+		recorder := corsRequest(config, http.MethodGet, "https://evil.example.com")
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("CorsMiddleware, credentials with explicit origin", func(t *testing.T) {
+		credentials := config
+		credentials.AllowOrigins = []string{"https://music.example.com"}
+		credentials.AllowCredentials = true
+		recorder := corsRequest(credentials, http.MethodGet, "https://music.example.com")
+		assert.Equal(t, "https://music.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", recorder.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+}
+
+func corsRequest(config CorsConfig, method string, origin string) *httptest.ResponseRecorder {
+	engine := gin.New()
+	engine.Use(CorsMiddleware(config))
+	engine.GET("/api/queue", func(context *gin.Context) { context.Status(http.StatusOK) })
+	request := httptest.NewRequest(method, "/api/queue", nil)
+	request.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder
+}
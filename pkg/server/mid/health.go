@@ -2,48 +2,171 @@ package mid
 
 import (
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	HealthStatusOk   = "ok"
+	HealthStatusWarn = "warn" // reported, does not make the service unready
+	HealthStatusFail = "fail"
+)
+
+type HealthComponent struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	Details gin.H  `json:"details,omitempty"`
+}
+
 type HealthResponse struct {
-	StatusCode int
-	Body       gin.H
-	UpdatedAt  time.Time
+	Status     string                     `json:"status"`
+	Components map[string]HealthComponent `json:"components"`
+}
+
+type skillActivity interface {
+	LastRequestAt() time.Time
 }
 
+// Health reports state known to the process, it never calls Amazon, so probes don't generate Alexa API traffic
 type Health struct {
-	AlexaClient client.IAlexaClient
+	AlexaClient    client.IAlexaClient
+	Queue          *model.Queue
+	Skill          skillActivity
+	configProblems atomic.Pointer[[]string] // swapped on config reload
 }
 
-func NewHealth(alexaClient client.IAlexaClient) *Health {
-	return &Health{
+func NewHealth(alexaClient client.IAlexaClient, queue *model.Queue, skill skillActivity, configProblems []string) *Health {
+	health := &Health{
 		AlexaClient: alexaClient,
+		Queue:       queue,
+		Skill:       skill,
 	}
+	health.SetConfigProblems(configProblems)
+	return health
 }
 
-func (api *Health) GetHealth(context *gin.Context) {
-	devices, err := api.AlexaClient.GetDevices()
-	var response *HealthResponse
-	if err != nil {
-		response = &HealthResponse{
-			UpdatedAt:  time.Now(),
-			StatusCode: http.StatusInternalServerError,
-			Body: gin.H{
-				"status": "dead",
-				"error":  err.Error(),
-			},
-		}
+func (api *Health) SetConfigProblems(configProblems []string) {
+	api.configProblems.Store(&configProblems)
+}
+
+// GetLiveness only checks the process is serving requests, Amazon being down should not restart it
+func (api *Health) GetLiveness(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"status": HealthStatusOk})
+}
+
+// GetStatus reports each component separately, responds 503 (not ready) if any of them failed
+func (api *Health) GetStatus(context *gin.Context) {
+	now := time.Now()
+	session := api.AlexaClient.GetSessionState()
+	response := HealthResponse{
+		Components: map[string]HealthComponent{
+			"session":    sessionComponent(session),
+			"cookies":    cookiesComponent(session, now),
+			"alexaCalls": alexaCallsComponent(session, now),
+			"skill":      skillComponent(api.Skill.LastRequestAt(), now),
+			"queue":      queueComponent(api.Queue),
+			"config":     configComponent(*api.configProblems.Load()),
+		},
+	}
+	response.Status = overallStatus(response.Components)
+	if response.Status == HealthStatusFail {
+		context.JSON(http.StatusServiceUnavailable, response)
 	} else {
-		response = &HealthResponse{
-			UpdatedAt:  time.Now(),
-			StatusCode: http.StatusOK,
-			Body: gin.H{
-				"status":  "ok",
-				"devices": len(devices.Devices),
-			},
+		context.JSON(http.StatusOK, response)
+	}
+}
+
+func sessionComponent(session client.SessionState) HealthComponent {
+	details := gin.H{"loggedInAt": timeOrNil(session.LoggedInAt), "circuitBreaker": session.CircuitBreaker}
+	if !session.LoggedIn {
+		return HealthComponent{Status: HealthStatusFail, Message: "not logged in to Alexa", Details: details}
+	}
+	if session.CircuitBreaker.State != client.CircuitClosed {
+		return HealthComponent{Status: HealthStatusWarn, Message: "Alexa API circuit breaker is not closed", Details: details}
+	}
+	return HealthComponent{Status: HealthStatusOk, Details: details}
+}
+
+func cookiesComponent(session client.SessionState, now time.Time) HealthComponent {
+	if session.CookiesSavedAt.IsZero() {
+		return HealthComponent{Status: HealthStatusWarn, Message: "no saved cookies, login is needed on restart"}
+	}
+	return HealthComponent{Status: HealthStatusOk, Details: gin.H{
+		"savedAt":    session.CookiesSavedAt,
+		"ageSeconds": secondsSince(session.CookiesSavedAt, now),
+	}}
+}
+
+func alexaCallsComponent(session client.SessionState, now time.Time) HealthComponent {
+	details := gin.H{
+		"lastSuccessAt":           timeOrNil(session.LastSuccessAt),
+		"lastFailureAt":           timeOrNil(session.LastFailureAt),
+		"secondsSinceLastSuccess": secondsSince(session.LastSuccessAt, now),
+	}
+	if session.LastFailureAt.After(session.LastSuccessAt) {
+		return HealthComponent{Status: HealthStatusWarn, Message: "last Alexa API call failed", Details: details}
+	}
+	return HealthComponent{Status: HealthStatusOk, Details: details}
+}
+
+func skillComponent(lastRequestAt time.Time, now time.Time) HealthComponent {
+	if lastRequestAt.IsZero() {
+		return HealthComponent{Status: HealthStatusWarn, Message: "no skill requests received yet"}
+	}
+	return HealthComponent{Status: HealthStatusOk, Details: gin.H{
+		"lastRequestAt":           lastRequestAt,
+		"secondsSinceLastRequest": secondsSince(lastRequestAt, now),
+	}}
+}
+
+func queueComponent(queue *model.Queue) HealthComponent {
+	queue.Lock()
+	defer queue.Unlock()
+	details := gin.H{
+		"state":    queue.State,
+		"length":   len(queue.Songs),
+		"position": queue.QueuePosition,
+	}
+	if queue.HasItems() {
+		details["current"] = queue.Current().Name
+	}
+	return HealthComponent{Status: HealthStatusOk, Details: details}
+}
+
+func configComponent(problems []string) HealthComponent {
+	if len(problems) > 0 {
+		return HealthComponent{Status: HealthStatusWarn, Message: "configuration looks wrong", Details: gin.H{"problems": problems}}
+	}
+	return HealthComponent{Status: HealthStatusOk}
+}
+
+func overallStatus(components map[string]HealthComponent) string {
+	status := HealthStatusOk
+	for _, component := range components {
+		if component.Status == HealthStatusFail {
+			return HealthStatusFail
 		}
+		if component.Status == HealthStatusWarn {
+			status = HealthStatusWarn
+		}
+	}
+	return status
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func secondsSince(t time.Time, now time.Time) *int64 {
+	if t.IsZero() {
+		return nil
 	}
-	context.JSON(response.StatusCode, response.Body)
+	seconds := int64(now.Sub(t).Seconds())
+	return &seconds
 }
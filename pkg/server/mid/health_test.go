@@ -0,0 +1,115 @@
+package mid
+
+import (
+	"encoding/json"
+	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tests"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type stubAlexaClient struct {
+	client.IAlexaClient // calls to anything but session state panic, health must not call Amazon
+	session             client.SessionState
+}
+
+func (s *stubAlexaClient) GetSessionState() client.SessionState {
+	return s.session
+}
+
+type stubSkill struct {
+	lastRequestAt time.Time
+}
+
+func (s *stubSkill) LastRequestAt() time.Time {
+	return s.lastRequestAt
+}
+
+func TestHealthGetLiveness(t *testing.T) {
+
+	t.Run("GetLiveness, does not check Alexa session", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/health/live"))
+
+		health := NewHealth(&stubAlexaClient{}, model.NewQueue(), &stubSkill{}, nil)
+		health.GetLiveness(mockGinContext)
+
+		assert.JSONEq(t, `{"status":"ok"}`, responseRecorder.Body.String())
+		assert.Equal(t, 200, responseRecorder.Code)
+	})
+
+}
+
+func TestHealthGetStatus(t *testing.T) {
+
+	now := time.Now()
+
+	t.Run("GetStatus, all components ok", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/health/ready"))
+		queue := model.NewQueue()
+		queue.Songs = []model.Song{{Id: "Id1", Name: "Name1"}}
+
+		health := NewHealth(&stubAlexaClient{session: client.SessionState{
+			LoggedIn:       true,
+			LoggedInAt:     now.Add(-time.Hour),
+			CookiesSavedAt: now.Add(-time.Hour),
+			LastSuccessAt:  now.Add(-time.Minute),
+			CircuitBreaker: client.CircuitBreakerState{State: client.CircuitClosed},
+		}}, queue, &stubSkill{lastRequestAt: now}, nil)
+		health.GetStatus(mockGinContext)
+
+		response := parseHealth(t, responseRecorder.Body.Bytes())
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Equal(t, HealthStatusOk, response.Status)
+		assert.Len(t, response.Components, 6)
+		for name, component := range response.Components {
+			assert.Equal(t, HealthStatusOk, component.Status, name)
+		}
+		assert.Equal(t, "Name1", response.Components["queue"].Details["current"])
+		assert.EqualValues(t, 3600, response.Components["cookies"].Details["ageSeconds"])
+		assert.Nil(t, response.Components["alexaCalls"].Details["lastFailureAt"])
+	})
+
+	t.Run("GetStatus, warnings keep service ready", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/health/ready"))
+
+		health := NewHealth(&stubAlexaClient{session: client.SessionState{
+			LoggedIn:       true,
+			LastSuccessAt:  now.Add(-time.Minute),
+			LastFailureAt:  now,
+			CircuitBreaker: client.CircuitBreakerState{State: client.CircuitOpen},
+		}}, model.NewQueue(), &stubSkill{}, []string{"apiKey is shorter than 16 characters"})
+		health.GetStatus(mockGinContext)
+
+		response := parseHealth(t, responseRecorder.Body.Bytes())
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.Equal(t, HealthStatusWarn, response.Status)
+		assert.Equal(t, HealthStatusWarn, response.Components["session"].Status)
+		assert.Equal(t, HealthStatusWarn, response.Components["cookies"].Status)
+		assert.Equal(t, HealthStatusWarn, response.Components["alexaCalls"].Status)
+		assert.Equal(t, HealthStatusWarn, response.Components["skill"].Status)
+		assert.Equal(t, HealthStatusOk, response.Components["queue"].Status)
+		assert.Equal(t, HealthStatusWarn, response.Components["config"].Status)
+		assert.Equal(t, []any{"apiKey is shorter than 16 characters"}, response.Components["config"].Details["problems"])
+	})
+
+	t.Run("GetStatus, not logged in is not ready", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONGet("/health/ready"))
+
+		health := NewHealth(&stubAlexaClient{}, model.NewQueue(), &stubSkill{lastRequestAt: now}, nil)
+		health.GetStatus(mockGinContext)
+
+		response := parseHealth(t, responseRecorder.Body.Bytes())
+		assert.Equal(t, 503, responseRecorder.Code)
+		assert.Equal(t, HealthStatusFail, response.Status)
+		assert.Equal(t, HealthStatusFail, response.Components["session"].Status)
+		assert.Nil(t, response.Components["session"].Details["loggedInAt"])
+	})
+
+}
+
+func parseHealth(t *testing.T, body []byte) (response HealthResponse) {
+	assert.NoError(t, json.Unmarshal(body, &response))
+	return response
+}
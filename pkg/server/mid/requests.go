@@ -66,18 +66,24 @@ func RequestLogsMiddleware(logRequests bool) gin.HandlerFunc {
 
 func RequestLogsForClients() func(rq *http.Request, rqBody []byte, rs *http.Response, rsBody []byte, err error, start time.Time) {
 	return func(rq *http.Request, rqBody []byte, rs *http.Response, rsBody []byte, err error, start time.Time) {
+		var rsContentLength int64
+		var rsHeaders http.Header
+		var rsStatus int
+		if rs != nil { // no response on network errors
+			rsContentLength, rsHeaders, rsStatus = rs.ContentLength, rs.Header, rs.StatusCode
+		}
 		logRequest("client",
 			rq.Method,
 			rq.URL.String(),
 			rq.ContentLength,
-			rs.ContentLength,
+			rsContentLength,
 			rq.Header,
-			rs.Header,
+			rsHeaders,
 			string(rqBody),
 			string(rsBody),
-			rs.StatusCode,
+			rsStatus,
 			err,
-			start, log.Logger(),
+			start, log.GetContextLogger(rq.Context()), // request logger of the incoming call, to correlate with it
 		)
 	}
 }
@@ -0,0 +1,86 @@
+package mid
+
+import (
+	"context"
+	"crypto/sha256"
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/subsonic"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+const (
+	SubsonicUserHeader  = "X-Subsonic-User"
+	SubsonicTokenHeader = "X-Subsonic-Token" // md5(password + salt), as stored by Navidrome UI
+	SubsonicSaltHeader  = "X-Subsonic-Salt"
+	// client errors: missing parameter (10), incompatible version (20, 30), wrong credentials (40),
+	// unsupported authentication (41-44), all rejected as unauthorized rather than Navidrome unavailable
+	subsonicErrorClientMin = 10
+	subsonicErrorClientMax = 44
+	subsonicCacheMax       = 1000
+)
+
+// SubsonicAuth verifies Navidrome users credentials with Subsonic ping, results are cached for a short time
+// so widget polling doesn't call Navidrome on every request
+type SubsonicAuth struct {
+	Client   *subsonic.SubsonicClient
+	CacheTTL time.Duration
+	mutex    sync.Mutex
+	verified map[[32]byte]subsonicVerification
+	now      func() time.Time
+}
+
+type subsonicVerification struct {
+	valid      bool
+	verifiedAt time.Time
+}
+
+func NewSubsonicAuth(navidromeURL string, cacheTTL time.Duration) *SubsonicAuth {
+	return &SubsonicAuth{
+		Client:   subsonic.NewSubsonicClient(navidromeURL, "", "", 5*time.Second), // pings with credentials of verified user
+		CacheTTL: cacheTTL,
+		verified: map[[32]byte]subsonicVerification{},
+		now:      time.Now,
+	}
+}
+
+// Verify returns whether credentials are valid, error if Navidrome could not tell (unavailable, unexpected response)
+func (auth *SubsonicAuth) Verify(ctx context.Context, user string, token string, salt string) (bool, error) {
+	cacheKey := sha256.Sum256([]byte(user + "\x00" + token + "\x00" + salt))
+	if valid, cached := auth.cached(cacheKey); cached {
+		return valid, nil
+	}
+	valid, err := auth.ping(ctx, user, token, salt)
+	if err != nil {
+		return false, err
+	}
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	if len(auth.verified) >= subsonicCacheMax {
+		auth.verified = map[[32]byte]subsonicVerification{}
+	}
+	auth.verified[cacheKey] = subsonicVerification{valid: valid, verifiedAt: auth.now()}
+	return valid, nil
+}
+
+func (auth *SubsonicAuth) cached(cacheKey [32]byte) (valid bool, cached bool) {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	verification, exists := auth.verified[cacheKey]
+	if !exists || auth.now().Sub(verification.verifiedAt) > auth.CacheTTL {
+		return false, false
+	}
+	return verification.valid, true
+}
+
+func (auth *SubsonicAuth) ping(ctx context.Context, user string, token string, salt string) (bool, error) {
+	err := auth.Client.Ping(ctx, user, token, salt)
+	var subsonicErr *subsonic.Error
+	if errors.As(err, &subsonicErr) && subsonicErr.Code >= subsonicErrorClientMin && subsonicErr.Code <= subsonicErrorClientMax {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "SubsonicAuth.ping failed")
+	}
+	return true, nil
+}
@@ -0,0 +1,175 @@
+package mid
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tests"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	subsonicOk          = `{"subsonic-response":{"status":"ok","version":"1.16.1"}}`
+	subsonicWrongUser   = `{"subsonic-response":{"status":"failed","version":"1.16.1","error":{"code":40,"message":"Wrong username or password"}}}`
+	subsonicMissingUser = `{"subsonic-response":{"status":"failed","version":"1.16.1","error":{"code":10,"message":"Missing parameter"}}}`
+	subsonicTokenAuth   = `{"subsonic-response":{"status":"failed","version":"1.16.1","error":{"code":41,"message":"Token authentication not supported for LDAP users"}}}`
+	subsonicGeneric     = `{"subsonic-response":{"status":"failed","version":"1.16.1","error":{"code":0,"message":"Generic error"}}}`
+)
+
+func mockNavidrome(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		calls.Add(1)
+		assert.Equal(t, "/rest/ping.view", rq.URL.Path)
+		assert.Equal(t, "json", rq.URL.Query().Get("f"))
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func TestSubsonicAuthVerify(t *testing.T) {
+
+	t.Run("Verify, valid credentials are cached", func(t *testing.T) {
+		server, calls := mockNavidrome(t, 200, subsonicOk)
+		auth := NewSubsonicAuth(server.URL, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			valid, err := auth.Verify(context.Background(), "user", "token", "salt")
+			assert.NoError(t, err)
+			assert.True(t, valid)
+		}
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Verify, cache expires", func(t *testing.T) {
+		server, calls := mockNavidrome(t, 200, subsonicOk)
+		auth := NewSubsonicAuth(server.URL, time.Minute)
+		now := time.Now()
+		auth.now = func() time.Time { return now }
+
+		_, _ = auth.Verify(context.Background(), "user", "token", "salt")
+		now = now.Add(2 * time.Minute)
+		_, _ = auth.Verify(context.Background(), "user", "token", "salt")
+
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("Verify, wrong credentials", func(t *testing.T) {
+		server, calls := mockNavidrome(t, 200, subsonicWrongUser)
+		auth := NewSubsonicAuth(server.URL, time.Minute)
+
+		valid, err := auth.Verify(context.Background(), "user", "token", "salt")
+		assert.NoError(t, err)
+		assert.False(t, valid)
+		valid, _ = auth.Verify(context.Background(), "user", "token", "salt")
+		assert.False(t, valid)
+		_, _ = auth.Verify(context.Background(), "user", "otherToken", "salt")
+		assert.Equal(t, int32(2), calls.Load(), "result cached per credentials")
+	})
+
+	for name, body := range map[string]string{"missing parameter": subsonicMissingUser, "token auth not supported": subsonicTokenAuth} {
+		t.Run("Verify, "+name+" is invalid credentials", func(t *testing.T) {
+			server, _ := mockNavidrome(t, 200, body)
+			auth := NewSubsonicAuth(server.URL, time.Minute)
+
+			valid, err := auth.Verify(context.Background(), "user", "token", "salt")
+			assert.NoError(t, err)
+			assert.False(t, valid)
+		})
+	}
+
+	t.Run("Verify, other subsonic error is an error and not cached", func(t *testing.T) {
+		server, calls := mockNavidrome(t, 200, subsonicGeneric)
+		auth := NewSubsonicAuth(server.URL, time.Minute)
+
+		_, err := auth.Verify(context.Background(), "user", "token", "salt")
+		assert.EqualError(t, err, "SubsonicAuth.ping failed: SubsonicClient.Ping failed: subsonic error 0: Generic error")
+		_, _ = auth.Verify(context.Background(), "user", "token", "salt")
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("Verify, Navidrome error status", func(t *testing.T) {
+		server, _ := mockNavidrome(t, 502, "Bad Gateway")
+		auth := NewSubsonicAuth(server.URL, time.Minute)
+
+		_, err := auth.Verify(context.Background(), "user", "token", "salt")
+		assert.EqualError(t, err, "SubsonicAuth.ping failed: SubsonicClient.Ping failed: ping.view failed, status 502")
+	})
+
+}
+
+func TestApiKeyAuthMiddlewareNavidromeUser(t *testing.T) {
+
+	navidromeRequest := func(user string) *http.Request {
+		request := tests.MockJSONGet("/api/queue")
+		request.Header.Set(SubsonicUserHeader, user)
+		request.Header.Set(SubsonicTokenHeader, "token")
+		request.Header.Set(SubsonicSaltHeader, "salt")
+		return request
+	}
+
+	t.Run("ApiKeyAuthMiddleware, valid Navidrome user", func(t *testing.T) {
+		server, _ := mockNavidrome(t, 200, subsonicOk)
+		mockGinContext, responseRecorder := tests.MockGin(navidromeRequest("alice"))
+
+		ApiKeyAuthMiddleware("/api/", testApiKeys, false, NewSubsonicAuth(server.URL, time.Minute))(mockGinContext)
+
+		assert.Equal(t, 200, responseRecorder.Code)
+		assert.False(t, mockGinContext.IsAborted())
+		assert.Equal(t, "alice", GetUsername(mockGinContext))
+		assert.True(t, GetApiKey(mockGinContext).HasScope(ScopeControl))
+		assert.False(t, GetApiKey(mockGinContext).HasScope(ScopeAdmin))
+	})
+
+	t.Run("ApiKeyAuthMiddleware, wrong Navidrome credentials", func(t *testing.T) {
+		server, _ := mockNavidrome(t, 200, subsonicWrongUser)
+		mockGinContext, responseRecorder := tests.MockGin(navidromeRequest("alice"))
+
+		ApiKeyAuthMiddleware("/api/", testApiKeys, false, NewSubsonicAuth(server.URL, time.Minute))(mockGinContext)
+
+		assert.Equal(t, 401, responseRecorder.Code)
+		assert.Equal(t, "", GetUsername(mockGinContext))
+	})
+
+	t.Run("ApiKeyAuthMiddleware, Navidrome unavailable", func(t *testing.T) {
+		server, _ := mockNavidrome(t, 500, "")
+		mockGinContext, responseRecorder := tests.MockGin(navidromeRequest("alice"))
+
+		ApiKeyAuthMiddleware("/api/", testApiKeys, false, NewSubsonicAuth(server.URL, time.Minute))(mockGinContext)
+
+		assert.Equal(t, 503, responseRecorder.Code)
+	})
+
+	t.Run("ApiKeyAuthMiddleware, missing Navidrome credentials", func(t *testing.T) {
+		server, _ := mockNavidrome(t, 200, subsonicMissingUser)
+		mockGinContext, responseRecorder := tests.MockGin(navidromeRequest("alice"))
+
+		ApiKeyAuthMiddleware("/api/", testApiKeys, false, NewSubsonicAuth(server.URL, time.Minute))(mockGinContext)
+
+		assert.Equal(t, 401, responseRecorder.Code)
+	})
+
+	t.Run("ApiKeyAuthMiddleware, Navidrome unreachable", func(t *testing.T) {
+		server, _ := mockNavidrome(t, 200, subsonicOk)
+		server.Close()
+		mockGinContext, responseRecorder := tests.MockGin(navidromeRequest("alice"))
+
+		ApiKeyAuthMiddleware("/api/", testApiKeys, false, NewSubsonicAuth(server.URL, time.Minute))(mockGinContext)
+
+		assert.Equal(t, 503, responseRecorder.Code)
+	})
+
+	t.Run("ApiKeyAuthMiddleware, Navidrome auth off ignores user headers", func(t *testing.T) {
+		mockGinContext, responseRecorder := tests.MockGin(navidromeRequest("alice"))
+
+		ApiKeyAuthMiddleware("/api/", testApiKeys, false, nil)(mockGinContext)
+
+		assert.Equal(t, 401, responseRecorder.Code)
+	})
+
+}
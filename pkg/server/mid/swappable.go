@@ -0,0 +1,28 @@
+package mid
+
+import (
+	"github.com/gin-gonic/gin"
+	"sync/atomic"
+)
+
+// SwappableMiddleware delegates to a middleware that can be replaced at runtime, e.g. rebuilt with reloaded config
+type SwappableMiddleware struct {
+	handler atomic.Pointer[gin.HandlerFunc]
+}
+
+func NewSwappableMiddleware(handler gin.HandlerFunc) *SwappableMiddleware {
+	swappable := &SwappableMiddleware{}
+	swappable.Swap(handler)
+	return swappable
+}
+
+// Swap replaces middleware for subsequent requests, requests in flight finish with the previous one
+func (s *SwappableMiddleware) Swap(handler gin.HandlerFunc) {
+	s.handler.Store(&handler)
+}
+
+func (s *SwappableMiddleware) Handler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		(*s.handler.Load())(context)
+	}
+}
@@ -0,0 +1,43 @@
+package mid
+
+import (
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tests"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSwappableMiddleware(t *testing.T) {
+
+	t.Run("Swap, subsequent requests use new middleware", func(t *testing.T) {
+		swappable := NewSwappableMiddleware(ApiKeyAuthMiddleware("/api/", []ApiKey{{Name: "old", Hash: HashApiKey("oldKey"), Scopes: Scopes}}, true, nil))
+		handler := swappable.Handler()
+
+		oldKeyContext, oldKeyRecorder := tests.MockGin(tests.MockJSONGet("/api/queue?apiKey=oldKey"))
+		handler(oldKeyContext)
+		assert.False(t, oldKeyContext.IsAborted())
+		assert.Equal(t, 200, oldKeyRecorder.Code)
+
+		swappable.Swap(ApiKeyAuthMiddleware("/api/", []ApiKey{{Name: "new", Hash: HashApiKey("newKey"), Scopes: Scopes}}, true, nil))
+
+		rejectedContext, rejectedRecorder := tests.MockGin(tests.MockJSONGet("/api/queue?apiKey=oldKey"))
+		handler(rejectedContext)
+		assert.True(t, rejectedContext.IsAborted())
+		assert.Equal(t, 401, rejectedRecorder.Code)
+
+		newKeyContext, _ := tests.MockGin(tests.MockJSONGet("/api/queue?apiKey=newKey"))
+		handler(newKeyContext)
+		assert.False(t, newKeyContext.IsAborted())
+	})
+
+	t.Run("Handler, chains to next handlers", func(t *testing.T) {
+		called := false
+		engine := gin.New()
+		engine.Use(NewSwappableMiddleware(func(context *gin.Context) { context.Next() }).Handler())
+		engine.GET("/", func(context *gin.Context) { called = true })
+		engine.ServeHTTP(httptest.NewRecorder(), tests.MockJSONGet("/"))
+		assert.True(t, called)
+	})
+
+}
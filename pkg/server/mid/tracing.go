@@ -0,0 +1,45 @@
+package mid
+
+import (
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tracing"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// TracingMiddleware starts a server span per request and passes it down in request context,
+// spans are noop unless tracing exporter is configured
+func TracingMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if shouldSkipURL(context.Request.URL.Path) {
+			context.Next()
+			return
+		}
+		method := context.Request.Method
+		route := context.FullPath() // empty for unknown routes, don't put raw paths into span names
+		ctx := otel.GetTextMapPropagator().Extract(context.Request.Context(), propagation.HeaderCarrier(context.Request.Header))
+		ctx, span := tracing.Tracer().Start(ctx, method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.HTTPRoute(route),
+			))
+		defer span.End()
+		context.Request = context.Request.WithContext(ctx)
+
+		context.Next() // chain
+
+		status := context.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if len(context.Errors) > 0 {
+			span.RecordError(context.Errors.Last().Err)
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
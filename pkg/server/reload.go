@@ -0,0 +1,160 @@
+package server
+
+import (
+	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+type configField struct {
+	name       string
+	reloaded   bool // applied at runtime, others need restart
+	value      func(config *Config) any
+	setRunning func(running *Config, reloaded *Config)
+}
+
+var configFields = []configField{
+	{name: "amazonDomain", value: func(c *Config) any { return c.AmazonDomain }},
+	{name: "amazonUser", value: func(c *Config) any { return c.AmazonUser }},
+	{name: "amazonPassword", value: func(c *Config) any { return c.AmazonPassword }},
+	{name: "amazonCookiePath", value: func(c *Config) any { return c.AmazonCookiePath }},
+	{name: "alexaSkillId", value: func(c *Config) any { return c.AlexaSkillId }},
+	{name: "listenAddress", value: func(c *Config) any { return c.ListenAddress }},
+	{name: "navidromeUser", value: func(c *Config) any { return c.NavidromeUser }},
+	{name: "navidromePassword", value: func(c *Config) any { return c.NavidromePassword }},
+	{name: "navidromeProxy", value: func(c *Config) any { return c.NavidromeProxy }},
+	{name: "tlsCertFile", value: func(c *Config) any { return c.TlsCertFile }}, // certificate itself is reloaded when files change
+	{name: "tlsKeyFile", value: func(c *Config) any { return c.TlsKeyFile }},
+	{name: "tlsMinVersion", value: func(c *Config) any { return c.TlsMinVersion }},
+	{name: "httpRedirectAddress", value: func(c *Config) any { return c.HttpRedirectAddress }},
+	{name: "tracingEndpoint", value: func(c *Config) any { return c.TracingEndpoint }},
+	{name: "apiKey", reloaded: true, value: func(c *Config) any { return c.ApiKey },
+		setRunning: func(running *Config, reloaded *Config) { running.ApiKey = reloaded.ApiKey }},
+	{name: "apiKeys", reloaded: true, value: func(c *Config) any { return c.ApiKeys },
+		setRunning: func(running *Config, reloaded *Config) { running.ApiKeys = reloaded.ApiKeys }},
+	{name: "allowQueryApiKey", reloaded: true, value: func(c *Config) any { return c.AllowQueryApiKey },
+		setRunning: func(running *Config, reloaded *Config) { running.AllowQueryApiKey = reloaded.AllowQueryApiKey }},
+	{name: "navidromeAuth", reloaded: true, value: func(c *Config) any { return c.NavidromeAuth },
+		setRunning: func(running *Config, reloaded *Config) { running.NavidromeAuth = reloaded.NavidromeAuth }},
+	{name: "navidromeURL", reloaded: true, value: func(c *Config) any { return c.NavidromeURL },
+		setRunning: func(running *Config, reloaded *Config) { running.NavidromeURL = reloaded.NavidromeURL }},
+	{name: "widgetProxyHosts", reloaded: true, value: func(c *Config) any { return c.WidgetProxyHosts },
+		setRunning: func(running *Config, reloaded *Config) { running.WidgetProxyHosts = reloaded.WidgetProxyHosts }},
+	{name: "corsAllowOrigins", reloaded: true, value: func(c *Config) any { return c.CorsAllowOrigins },
+		setRunning: func(running *Config, reloaded *Config) { running.CorsAllowOrigins = reloaded.CorsAllowOrigins }},
+	{name: "corsAllowMethods", reloaded: true, value: func(c *Config) any { return c.CorsAllowMethods },
+		setRunning: func(running *Config, reloaded *Config) { running.CorsAllowMethods = reloaded.CorsAllowMethods }},
+	{name: "corsAllowHeaders", reloaded: true, value: func(c *Config) any { return c.CorsAllowHeaders },
+		setRunning: func(running *Config, reloaded *Config) { running.CorsAllowHeaders = reloaded.CorsAllowHeaders }},
+	{name: "corsAllowCredentials", reloaded: true, value: func(c *Config) any { return c.CorsAllowCredentials },
+		setRunning: func(running *Config, reloaded *Config) { running.CorsAllowCredentials = reloaded.CorsAllowCredentials }},
+	{name: "streamDomain", reloaded: true, value: func(c *Config) any { return c.StreamDomain },
+		setRunning: func(running *Config, reloaded *Config) { running.StreamDomain = reloaded.StreamDomain }},
+	{name: "alexaSkillName", reloaded: true, value: func(c *Config) any { return c.AlexaSkillName },
+		setRunning: func(running *Config, reloaded *Config) { running.AlexaSkillName = reloaded.AlexaSkillName }},
+	{name: "alexaSkillDisplayName", reloaded: true, value: func(c *Config) any { return c.AlexaSkillDisplayName },
+		setRunning: func(running *Config, reloaded *Config) {
+			running.AlexaSkillDisplayName = reloaded.AlexaSkillDisplayName
+		}},
+	{name: "logIncomingRequests", reloaded: true, value: func(c *Config) any { return c.LogIncomingRequests },
+		setRunning: func(running *Config, reloaded *Config) { running.LogIncomingRequests = reloaded.LogIncomingRequests }},
+	{name: "logOutgoingRequests", reloaded: true, value: func(c *Config) any { return c.LogOutgoingRequests },
+		setRunning: func(running *Config, reloaded *Config) { running.LogOutgoingRequests = reloaded.LogOutgoingRequests }},
+	{name: "logStructured", reloaded: true, value: func(c *Config) any { return c.LogStructured },
+		setRunning: func(running *Config, reloaded *Config) { running.LogStructured = reloaded.LogStructured }},
+	{name: "logLevel", reloaded: true, value: func(c *Config) any { return c.LogLevel },
+		setRunning: func(running *Config, reloaded *Config) { running.LogLevel = reloaded.LogLevel }},
+}
+
+type ReloadResult struct {
+	Applied         []string `json:"applied"`         // changed and applied at runtime
+	RestartRequired []string `json:"restartRequired"` // changed, but can only be applied by restart
+}
+
+// Reloader loads config again and applies settings that can change at runtime, keeping the rest of running config.
+// Reloads are serialized, components swap their settings atomically so requests in flight see either old or new value
+type Reloader struct {
+	load    func() (*Config, error)
+	apply   func(config *Config)
+	mutex   sync.Mutex
+	running atomic.Pointer[Config]
+}
+
+func NewReloader(config *Config, load func() (*Config, error), apply func(config *Config)) *Reloader {
+	reloader := &Reloader{load: load, apply: apply}
+	reloader.running.Store(config)
+	return reloader
+}
+
+// Config is the running config
+func (r *Reloader) Config() *Config {
+	return r.running.Load()
+}
+
+func (r *Reloader) Reload() (result ReloadResult, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	result = ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	reloaded, err := r.load()
+	if err != nil {
+		return result, errors.Wrap(err, "Reloader.Reload loading config failed")
+	}
+	running := *r.running.Load()
+	for _, field := range configFields {
+		if reflect.DeepEqual(field.value(&running), field.value(reloaded)) {
+			continue
+		}
+		if field.reloaded {
+			field.setRunning(&running, reloaded)
+			result.Applied = append(result.Applied, field.name)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, field.name)
+		}
+	}
+	if len(result.Applied) > 0 {
+		r.apply(&running)
+		r.running.Store(&running)
+	}
+	return result, nil
+}
+
+// ReloadOnSignal reloads config on SIGHUP
+func (r *Reloader) ReloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			r.logReload(r.Reload())
+		}
+	}()
+}
+
+func (r *Reloader) PostReload(c *gin.Context) {
+	result, err := r.Reload()
+	r.logReload(result, err)
+	if err != nil {
+		_ = c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Reload failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "applied": result.Applied, "restartRequired": result.RestartRequired})
+}
+
+func (r *Reloader) logReload(result ReloadResult, err error) {
+	if err != nil {
+		log.Logger().Error("Config reload failed, keeping running config", "error", err)
+		return
+	}
+	log.Logger().Info("Config reloaded", "applied", strings.Join(result.Applied, ","))
+	if len(result.RestartRequired) > 0 {
+		log.Logger().Warn("Config changes ignored until restart", "restartRequired", strings.Join(result.RestartRequired, ","))
+	}
+}
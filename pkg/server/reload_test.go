@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tests"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReloaderReload(t *testing.T) {
+
+	log.InitWithLogger(slog.Default())
+
+	t.Run("Reload, applies runtime settings and reports the rest", func(t *testing.T) {
+		reloaded := validConfig()
+		reloaded.ApiKey = "fedcba9876543210"
+		reloaded.LogOutgoingRequests = true
+		reloaded.LogLevel = "info"
+		reloaded.ListenAddress = ":9090"
+		var applied []*Config
+		reloader := NewReloader(validConfig(), func() (*Config, error) { return reloaded, nil },
+			func(config *Config) { applied = append(applied, config) })
+
+		result, err := reloader.Reload()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"apiKey", "logOutgoingRequests", "logLevel"}, result.Applied)
+		assert.Equal(t, []string{"listenAddress"}, result.RestartRequired)
+		assert.Len(t, applied, 1)
+		assert.Equal(t, applied[0], reloader.Config())
+		assert.Equal(t, "fedcba9876543210", reloader.Config().ApiKey)
+		assert.Equal(t, true, reloader.Config().LogOutgoingRequests)
+		assert.Equal(t, ":8080", reloader.Config().ListenAddress, "running config keeps settings that need restart")
+	})
+
+	t.Run("Reload, nothing changed", func(t *testing.T) {
+		running := validConfig()
+		reloader := NewReloader(running, func() (*Config, error) { return validConfig(), nil },
+			func(config *Config) { t.Fatal("should not apply unchanged config") })
+
+		result, err := reloader.Reload()
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.Applied)
+		assert.Empty(t, result.RestartRequired)
+		assert.Same(t, running, reloader.Config())
+	})
+
+	t.Run("Reload, Subsonic calls and Navidrome proxy go to reloaded Navidrome URL", func(t *testing.T) {
+		var oldCalls, newCalls []string
+		oldNavidrome := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			oldCalls = append(oldCalls, request.URL.Path)
+		}))
+		defer oldNavidrome.Close()
+		newNavidrome := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			newCalls = append(newCalls, request.URL.Path)
+			_, _ = writer.Write([]byte(`{"subsonic-response":{"status":"ok"}}`))
+		}))
+		defer newNavidrome.Close()
+		running := validConfig()
+		running.NavidromeURL = oldNavidrome.URL
+		running.NavidromeUser = "alexa"
+		running.NavidromePassword = "password"
+		running.NavidromeProxy = true
+		reloaded := *running
+		reloaded.NavidromeURL = newNavidrome.URL + "/"
+		navidrome := subsonicClient(running)
+		proxy := navidromeProxy(running, http.NotFoundHandler())
+		reloader := NewReloader(running, func() (*Config, error) { return &reloaded, nil },
+			func(config *Config) { setNavidromeURL(config, navidrome, proxy) })
+
+		result, err := reloader.Reload()
+		_, searchErr := navidrome.Search(context.Background(), "song", 1)
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/app/", nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"navidromeURL"}, result.Applied)
+		assert.NoError(t, searchErr)
+		assert.Equal(t, []string{"/rest/search3", "/app/"}, newCalls)
+		assert.Empty(t, oldCalls)
+	})
+
+	t.Run("Reload, keeps running config if loading fails", func(t *testing.T) {
+		running := validConfig()
+		reloader := NewReloader(running, func() (*Config, error) { return nil, errors.New("apiKey is required") },
+			func(config *Config) { t.Fatal("should not apply invalid config") })
+
+		_, err := reloader.Reload()
+
+		assert.EqualError(t, err, "Reloader.Reload loading config failed: apiKey is required")
+		assert.Same(t, running, reloader.Config())
+	})
+
+}
+
+func TestReloaderPostReload(t *testing.T) {
+
+	t.Run("PostReload, success", func(t *testing.T) {
+		reloaded := validConfig()
+		reloaded.AlexaSkillName = "other name"
+		reloaded.AmazonUser = "other@example.com"
+		reloader := NewReloader(validConfig(), func() (*Config, error) { return reloaded, nil }, func(config *Config) {})
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(""))
+
+		reloader.PostReload(mockGinContext)
+
+		assert.JSONEq(t, `{"status":"success","applied":["alexaSkillName"],"restartRequired":["amazonUser"]}`, responseRecorder.Body.String())
+		assert.Equal(t, 200, responseRecorder.Code)
+	})
+
+	t.Run("PostReload, invalid config", func(t *testing.T) {
+		reloader := NewReloader(validConfig(), func() (*Config, error) { return nil, errors.New("apiKey is required") }, func(config *Config) {})
+		mockGinContext, responseRecorder := tests.MockGin(tests.MockJSONPost(""))
+
+		reloader.PostReload(mockGinContext)
+
+		assert.JSONEq(t, `{"status":"error","message":"Reload failed: Reloader.Reload loading config failed: apiKey is required"}`, responseRecorder.Body.String())
+		assert.Equal(t, 500, responseRecorder.Code)
+	})
+
+}
@@ -1,96 +1,262 @@
 package server
 
 import (
+	"context"
 	alexa "github.com/ahimgit/navidrome-alexa/pkg/alexa/client"
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/client/httpclient"
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/playqueue"
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/subsonic"
 	server "github.com/ahimgit/navidrome-alexa/pkg/server/api"
 	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
 	"github.com/ahimgit/navidrome-alexa/pkg/server/mid"
 	"github.com/ahimgit/navidrome-alexa/pkg/server/skill"
 	"github.com/ahimgit/navidrome-alexa/pkg/server/ui"
 	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
-	"github.com/gin-contrib/cache"
-	"github.com/gin-contrib/cache/persistence"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	nethttp "net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type Config struct {
-	AmazonDomain        string
-	AmazonUser          string
-	AmazonPassword      string
-	AmazonCookiePath    string
-	AlexaSkillId        string
-	AlexaSkillName      string
-	StreamDomain        string
-	ApiKey              string
-	ListenAddress       string
-	LogIncomingRequests bool
-	LogOutgoingRequests bool
-	LogStructured       bool
+	AmazonDomain          string
+	AmazonUser            string
+	AmazonPassword        string
+	AmazonCookiePath      string
+	AlexaSkillId          string
+	AlexaSkillName        string
+	AlexaSkillDisplayName string // provider name Alexa reports while skill plays, matched as AlexaSkillName if empty
+	StreamDomain          string
+	ApiKey                string         // legacy single key with all scopes, optional if ApiKeys are set
+	ApiKeys               []ApiKeyConfig // named hashed keys, from config file only
+	AllowQueryApiKey      bool
+	NavidromeAuth         bool   // widget users authenticate with their Navidrome credentials
+	NavidromeURL          string // for Subsonic API calls, StreamDomain if empty
+	NavidromeProxy        bool   // reverse proxy Navidrome with widget injected, NA under /na
+	NavidromeUser         string // for Subsonic API calls by NA itself (library search), off if empty
+	NavidromePassword     string
+	WidgetProxyHosts      []string // hosts /proxy may fetch Navidrome UI script from, NavidromeURL (or StreamDomain) host if empty
+	CorsAllowOrigins      []string // StreamDomain origin if empty
+	CorsAllowMethods      []string
+	CorsAllowHeaders      []string
+	CorsAllowCredentials  bool
+	ListenAddress         string
+	TlsCertFile           string // serve https if set, files are reloaded when changed
+	TlsKeyFile            string
+	TlsMinVersion         string // 1.2 or 1.3
+	HttpRedirectAddress   string // plain http listener redirecting to https, off if empty
+	LogIncomingRequests   bool
+	LogOutgoingRequests   bool
+	LogStructured         bool
+	LogLevel              string
+	TracingEndpoint       string
 }
 
-func StartRouter(config *Config) {
-	store := persistence.NewInMemoryStore(time.Minute)
+type ApiKeyConfig struct {
+	Name   string
+	Hash   string // hex encoded SHA-256 of the key
+	Scopes []string
+}
+
+// StartRouter starts the server, load is used to load config again on reload (SIGHUP or /api/admin/reload)
+func StartRouter(config *Config, load func() (*Config, error)) {
+	shutdownTracing, err := tracing.Init(context.Background(), config.TracingEndpoint, "navidrome-alexa")
+	if err != nil {
+		log.Logger().Error("Unable to init tracing", "error", err)
+	} else {
+		defer shutdownTracing(context.Background())
+	}
+	commandLinks := tracing.NewCommandLinks(30 * time.Second)
 	queue := model.NewQueue()
+	var logOutgoingRequests atomic.Bool
+	logOutgoingRequests.Store(config.LogOutgoingRequests)
 	alexaClient := initAlexaClient(
 		config.AmazonDomain,
 		config.AmazonUser,
 		config.AmazonPassword,
 		config.AmazonCookiePath,
-		config.LogOutgoingRequests,
+		logOutgoingRequests.Load,
 	)
-	healthCheck := mid.NewHealth(alexaClient)
 	queueAPI := server.NewQueueAPI(queue)
-	playerAPI := server.NewPlayerAPI(alexaClient, config.AlexaSkillName)
+	navidrome := subsonicClient(config)
+	libraryAPI := server.NewLibraryAPI(navidrome)
+	playerAPI := server.NewPlayerAPI(alexaClient, queue, config.AlexaSkillName)
+	playerAPI.SetSkillDisplayName(config.AlexaSkillDisplayName)
 	skillHandler := skill.NewHandlerSelector(queue, config.StreamDomain)
+	prometheus.MustRegister(skill.NewQueueCollector(queue))
+	playlistAPI := server.NewPlaylistAPI(queue, navidrome, skillHandler.StreamDomain)
+	if navidrome != nil { // two-way sync with Navidrome play queue, saved on changes and loaded on demand
+		playQueueSync := playqueue.NewSync(queue, navidrome, 10*time.Second)
+		queueAPI.PlayQueue = playQueueSync
+		playlistAPI.PlayQueue = playQueueSync
+		skillHandler.PlayQueue = playQueueSync
+		skillHandler.StreamAuth = navidrome
+	}
 	skillAPI := skill.NewSkillAPI(skillHandler, config.AlexaSkillId)
-
+	playerAPI.CommandLinks = commandLinks
+	skillAPI.CommandLinks = commandLinks
+	widgetProxy := ui.NewWidgetProxy(widgetProxyHosts(config), 10*time.Second)
+	healthCheck := mid.NewHealth(alexaClient, queue, skillAPI, config.Problems())
+	cors := mid.NewSwappableMiddleware(mid.CorsMiddleware(config.Cors()))
+	requestLogs := mid.NewSwappableMiddleware(mid.RequestLogsMiddleware(config.LogIncomingRequests))
+	apiKeyAuth := mid.NewSwappableMiddleware(mid.ApiKeyAuthMiddleware("/api/", apiKeys(config), config.AllowQueryApiKey, subsonicAuth(config)))
 	gin.SetMode(gin.ReleaseMode)
 	engine := gin.New()
+	proxy := navidromeProxy(config, engine.Handler())
+	reloader := NewReloader(config, load, func(config *Config) {
+		log.Init(config.LogStructured, config.Level())
+		logOutgoingRequests.Store(config.LogOutgoingRequests)
+		cors.Swap(mid.CorsMiddleware(config.Cors()))
+		requestLogs.Swap(mid.RequestLogsMiddleware(config.LogIncomingRequests))
+		apiKeyAuth.Swap(mid.ApiKeyAuthMiddleware("/api/", apiKeys(config), config.AllowQueryApiKey, subsonicAuth(config)))
+		playerAPI.SetSkillName(config.AlexaSkillName)
+		playerAPI.SetSkillDisplayName(config.AlexaSkillDisplayName)
+		skillHandler.SetStreamDomain(config.StreamDomain)
+		setNavidromeURL(config, navidrome, proxy)
+		widgetProxy.SetAllowedHosts(widgetProxyHosts(config))
+		healthCheck.SetConfigProblems(config.Problems())
+	})
+	reloader.ReloadOnSignal()
 
 	engine.Use(gin.Recovery())
-	engine.Use(mid.CorsMiddleware())
-	engine.Use(mid.RequestLogsMiddleware(config.LogIncomingRequests))
-	engine.Use(mid.ApiKeyAuthMiddleware("/api/", config.ApiKey))
+	engine.Use(mid.TracingMiddleware())
+	engine.Use(cors.Handler())
+	engine.Use(requestLogs.Handler())
+	engine.Use(apiKeyAuth.Handler())
 	engine.Use(mid.MetricsMiddleware("/metrics", engine))
-	engine.GET("/health", cached(healthCheck.GetHealth, store))
-
-	engine.GET("/api/playing", queueAPI.GetNowPlaying) // player api
-	engine.GET("/api/queue", queueAPI.GetQueue)
-	engine.POST("/api/queue", queueAPI.PostQueue)
-	engine.POST("/api/play", playerAPI.PostPlay)
-	engine.POST("/api/stop", playerAPI.PostStop)
-	engine.POST("/api/next", playerAPI.PostNext)
-	engine.POST("/api/prev", playerAPI.PostPrev)
-	engine.POST("/api/volume", playerAPI.PostVolume)
-	engine.GET("/api/volume", playerAPI.GetVolume)
-	engine.GET("/api/devices", cached(playerAPI.GetDevices, store))
+	engine.GET("/health/live", healthCheck.GetLiveness) // process only, for liveness probes
+	engine.GET("/health/ready", healthCheck.GetStatus)
+	engine.GET("/health", healthCheck.GetStatus)
+
+	read, control, admin := mid.RequireScope(mid.ScopeRead), mid.RequireScope(mid.ScopeControl), mid.RequireScope(mid.ScopeAdmin)
+	engine.GET("/api/playing", read, queueAPI.GetNowPlaying) // player api
+	engine.GET("/api/queue", read, queueAPI.GetQueue)
+	engine.POST("/api/queue", control, queueAPI.PostQueue)
+	engine.POST("/api/queue/import", control, playlistAPI.PostImport)
+	engine.GET("/api/queue/export", read, playlistAPI.GetExport)
+	engine.POST("/api/queue/playlist", control, playlistAPI.PostPlaylist)
+	engine.POST("/api/queue/resume", control, playlistAPI.PostResume)
+	engine.POST("/api/play", control, playerAPI.PostPlay)
+	engine.POST("/api/stop", control, playerAPI.PostStop)
+	engine.POST("/api/next", control, playerAPI.PostNext)
+	engine.POST("/api/prev", control, playerAPI.PostPrev)
+	engine.POST("/api/volume", control, playerAPI.PostVolume)
+	engine.GET("/api/volume", read, playerAPI.GetVolume)
+	engine.POST("/api/mute", control, playerAPI.PostMute)
+	engine.POST("/api/unmute", control, playerAPI.PostUnmute)
+	engine.GET("/api/commands", read, playerAPI.GetCommands)
+	engine.GET("/api/commands/:id", read, playerAPI.GetCommand)
+	engine.GET("/api/devices", read, playerAPI.GetDevices) // cached by player api device cache
+	engine.GET("/api/devices/:serial", read, playerAPI.GetDevice)
+	engine.GET("/api/devices/:serial/state", read, playerAPI.GetPlayerState)
+	engine.GET("/api/library", read, libraryAPI.GetLibrary)
+	engine.GET("/api/library/search", read, libraryAPI.GetSearch)
+	engine.GET("/api/library/cover/:id", read, libraryAPI.GetCover)
+	engine.POST("/api/admin/reload", admin, reloader.PostReload)
 
 	engine.POST("/skill", skillAPI.Post) // alexa skill api
 
-	engine.GET("/proxy", ui.GetWidget) // ui widget
+	engine.GET("/proxy", widgetProxy.GetWidget) // ui widget
 	engine.StaticFS("/static", ui.NewEmbedFileSystem())
+	engine.GET("/remote", func(c *gin.Context) { // remote control ui, relative so it works under /na in proxy mode too
+		c.Redirect(nethttp.StatusFound, "static/remote.html")
+	})
 
-	log.Logger().Info("Starting NA", "listenAddress", config.ListenAddress)
-	log.Logger().Error("Error starting server", "error", engine.Run(config.ListenAddress))
+	handler := engine.Handler()
+	if proxy != nil {
+		handler = proxy
+	}
+	log.Logger().Error("Error starting server", "error", serve(config, handler))
 }
 
-func initAlexaClient(amazonDomain string, amazonUser string, amazonPassword string, amazonCookiePath string, logRequests bool) alexa.IAlexaClient {
-	var client alexa.IAlexaClient
-	if logRequests {
-		http := httpclient.NewHttpClient().WithResponseLogger(mid.RequestLogsForClients())
-		cookie := httpclient.NewCookieHelper(amazonCookiePath)
-		client = alexa.NewAlexaClientWithHttpClient(amazonDomain, amazonUser, amazonPassword, cookie, http)
-	} else {
-		client = alexa.NewAlexaClient(amazonDomain, amazonUser, amazonPassword, amazonCookiePath)
+// navidromeProxy serves NA under /na in proxy mode, nil otherwise
+func navidromeProxy(config *Config, na nethttp.Handler) *ui.NavidromeProxy {
+	if !config.NavidromeProxy {
+		return nil
 	}
-	if err := client.LogIn(false); err != nil {
-		log.Logger().Error("Unable to log in to Alexa account", "error", err)
+	navidromeURL, _ := url.Parse(config.NavidromeURL) // validated with config
+	log.Logger().Info("Proxying Navidrome", "navidromeURL", config.NavidromeURL, "naPath", ui.NaPathPrefix)
+	return ui.NewNavidromeProxy(navidromeURL, na)
+}
+
+// setNavidromeURL points Subsonic client and Navidrome proxy (both optional) to reloaded Navidrome URL
+func setNavidromeURL(config *Config, navidrome subsonic.ISubsonicClient, proxy *ui.NavidromeProxy) {
+	if client, ok := navidrome.(*subsonic.SubsonicClient); ok {
+		client.SetURL(navidromeURL(config))
 	}
-	return client
+	if proxy != nil {
+		target, _ := url.Parse(config.NavidromeURL) // validated with config
+		proxy.SetTarget(target)
+	}
+}
+
+// navidromeURL is NavidromeURL, or StreamDomain if Navidrome is reachable on it
+func navidromeURL(config *Config) string {
+	return strings.TrimSuffix(nvl(config.NavidromeURL, config.StreamDomain), "/")
+}
+
+// widgetProxyHosts are configured hosts, or Navidrome host:port
+func widgetProxyHosts(config *Config) []string {
+	if len(config.WidgetProxyHosts) > 0 {
+		return config.WidgetProxyHosts
+	}
+	if navidromeURL, err := url.Parse(nvl(config.NavidromeURL, config.StreamDomain)); err == nil && navidromeURL.Host != "" {
+		return []string{ui.HostPort(navidromeURL.Host, navidromeURL.Scheme)}
+	}
+	return nil
+}
+
+// subsonicClient is nil if Navidrome user is not configured
+func subsonicClient(config *Config) subsonic.ISubsonicClient {
+	if config.NavidromeUser == "" {
+		return nil
+	}
+	return subsonic.NewSubsonicClient(navidromeURL(config), config.NavidromeUser, config.NavidromePassword, 10*time.Second)
+}
+
+// apiKeys are named keys from config, plus legacy apiKey with all scopes
+func apiKeys(config *Config) (keys []mid.ApiKey) {
+	for _, key := range config.ApiKeys {
+		keys = append(keys, mid.ApiKey{Name: key.Name, Hash: strings.ToLower(key.Hash), Scopes: key.Scopes})
+	}
+	if config.ApiKey != "" {
+		keys = append(keys, mid.ApiKey{Name: "apiKey", Hash: mid.HashApiKey(config.ApiKey), Scopes: mid.Scopes})
+	}
+	return keys
 }
 
-func cached(handler gin.HandlerFunc, store *persistence.InMemoryStore) gin.HandlerFunc {
-	return cache.CachePageWithoutQuery(store, time.Minute, handler)
+// subsonicAuth verifies Navidrome users if navidromeAuth is on, nil otherwise
+func subsonicAuth(config *Config) *mid.SubsonicAuth {
+	if !config.NavidromeAuth {
+		return nil
+	}
+	return mid.NewSubsonicAuth(navidromeURL(config), time.Minute)
+}
+
+func nvl(str, defaultStr string) string {
+	if str == "" {
+		return defaultStr
+	}
+	return str
+}
+
+func initAlexaClient(amazonDomain string, amazonUser string, amazonPassword string, amazonCookiePath string, logRequests func() bool) alexa.IAlexaClient {
+	logs := mid.RequestLogsForClients()
+	http := httpclient.NewHttpClient().WithResponseLogger(
+		func(rq *nethttp.Request, rqBody []byte, rs *nethttp.Response, rsBody []byte, err error, start time.Time) {
+			if logRequests() { // checked per request, can be turned on by config reload
+				logs(rq, rqBody, rs, rsBody, err, start)
+			}
+		})
+	cookie := httpclient.NewCookieHelper(amazonCookiePath)
+	client := alexa.NewAlexaClientWithHttpClient(amazonDomain, amazonUser, amazonPassword, cookie, http)
+	prometheus.MustRegister(alexa.NewSessionCollector(client))
+	if err := client.LogIn(context.Background(), false); err != nil {
+		log.Logger().Error("Unable to log in to Alexa account", "error", err)
+	}
+	return client
 }
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/subsonic"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/mid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestApiKeys(t *testing.T) {
+
+	t.Run("apiKeys, named keys and legacy key with all scopes", func(t *testing.T) {
+		config := &Config{
+			ApiKey:  "legacyKey",
+			ApiKeys: []ApiKeyConfig{{Name: "widget", Hash: "ABCDEF", Scopes: []string{mid.ScopeRead}}},
+		}
+		assert.Equal(t, []mid.ApiKey{
+			{Name: "widget", Hash: "abcdef", Scopes: []string{mid.ScopeRead}},
+			{Name: "apiKey", Hash: mid.HashApiKey("legacyKey"), Scopes: mid.Scopes},
+		}, apiKeys(config))
+	})
+
+	t.Run("apiKeys, named keys only", func(t *testing.T) {
+		config := &Config{ApiKeys: []ApiKeyConfig{{Name: "admin", Hash: "abcdef", Scopes: []string{mid.ScopeAdmin}}}}
+		assert.Equal(t, []mid.ApiKey{{Name: "admin", Hash: "abcdef", Scopes: []string{mid.ScopeAdmin}}}, apiKeys(config))
+	})
+
+}
+
+func TestSubsonicAuth(t *testing.T) {
+	assert.Nil(t, subsonicAuth(&Config{StreamDomain: "https://music.example.com"}))
+	assert.Equal(t, "https://music.example.com", subsonicAuth(&Config{NavidromeAuth: true, StreamDomain: "https://music.example.com/"}).Client.URL())
+	assert.Equal(t, "http://localhost:4533", subsonicAuth(&Config{NavidromeAuth: true, StreamDomain: "https://music.example.com", NavidromeURL: "http://localhost:4533"}).Client.URL())
+}
+
+func TestSubsonicClient(t *testing.T) {
+	assert.Nil(t, subsonicClient(&Config{StreamDomain: "https://music.example.com"}))
+	assert.Equal(t, "https://music.example.com", subsonicClient(&Config{NavidromeUser: "alexa", StreamDomain: "https://music.example.com/"}).(*subsonic.SubsonicClient).URL())
+	assert.Equal(t, "http://localhost:4533", subsonicClient(&Config{NavidromeUser: "alexa", StreamDomain: "https://music.example.com", NavidromeURL: "http://localhost:4533/"}).(*subsonic.SubsonicClient).URL())
+}
+
+func TestWidgetProxyHosts(t *testing.T) {
+	assert.Equal(t, []string{"music.example.com:443"}, widgetProxyHosts(&Config{StreamDomain: "https://music.example.com/"}))
+	assert.Equal(t, []string{"localhost:4533"}, widgetProxyHosts(&Config{StreamDomain: "https://music.example.com", NavidromeURL: "http://localhost:4533"}))
+	assert.Equal(t, []string{"navidrome"}, widgetProxyHosts(&Config{StreamDomain: "https://music.example.com", WidgetProxyHosts: []string{"navidrome"}}))
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/skill/model/request"
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/skill/model/response"
+	"github.com/ahimgit/navidrome-alexa/pkg/navidrome/playqueue"
 	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
 	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"sync/atomic"
+	"time"
 )
 
 type IHandlerSelector interface {
@@ -13,15 +16,47 @@ type IHandlerSelector interface {
 }
 
 type HandlerSelector struct {
-	StreamDomain string
+	streamDomain atomic.Pointer[string] // swapped on config reload
 	Queue        *model.Queue
+	PlayQueue    playqueue.IPlayQueueSync // nil if Navidrome access is not configured
+	StreamAuth   IStreamAuthenticator     // nil if Navidrome access is not configured, queued paths are used as is
 }
 
-func NewHandlerSelector(queue *model.Queue, StreamDomain string) IHandlerSelector {
-	return &HandlerSelector{Queue: queue, StreamDomain: StreamDomain}
+// IStreamAuthenticator adds Navidrome credentials to stream path, queue keeps paths without them
+type IStreamAuthenticator interface {
+	Authenticate(path string) string
+}
+
+func NewHandlerSelector(queue *model.Queue, streamDomain string) *HandlerSelector {
+	handlerSelector := &HandlerSelector{Queue: queue}
+	handlerSelector.SetStreamDomain(streamDomain)
+	return handlerSelector
+}
+
+func (handlerSelector *HandlerSelector) StreamDomain() string {
+	return *handlerSelector.streamDomain.Load()
+}
+
+func (handlerSelector *HandlerSelector) SetStreamDomain(streamDomain string) {
+	handlerSelector.streamDomain.Store(&streamDomain)
+}
+
+// streamURL is the URL Alexa streams song from, Navidrome songs get credentials only here
+func (handlerSelector *HandlerSelector) streamURL(song *model.Song) string {
+	if song.External() || handlerSelector.StreamAuth == nil {
+		return song.StreamURL(handlerSelector.StreamDomain())
+	}
+	return handlerSelector.StreamDomain() + handlerSelector.StreamAuth.Authenticate(song.Stream)
 }
 
 func (handlerSelector *HandlerSelector) HandleRequest(rqe *request.RequestEnvelope, c context.Context) (rs *response.ResponseEnvelope) {
+	handlerSelector.Queue.Lock()
+	defer handlerSelector.Queue.Unlock()
+	defer handlerSelector.syncPlayQueue(rqe, handlerSelector.Queue.QueuePosition)
+	device := rqe.Context.System.Device.DeviceID
+	if handlerSelector.Queue.IsFollower(device) {
+		return handlerSelector.handleFollowerRequest(rqe, device, c)
+	}
 	switch rq := rqe.Request.(type) {
 	case *request.IntentRequest:
 		switch rq.Intent.Name {
@@ -29,8 +64,14 @@ func (handlerSelector *HandlerSelector) HandleRequest(rqe *request.RequestEnvelo
 		case "AMAZON.ResumeIntent":
 			return handlerSelector.handlePlayResumeIntent(c)
 		case "AMAZON.NextIntent":
+			if handlerSelector.Queue.HasNext() {
+				observeTrack("skipped")
+			}
 			return handlerSelector.handleNextIntent(c)
 		case "AMAZON.PreviousIntent":
+			if handlerSelector.Queue.HasPrev() {
+				observeTrack("skipped")
+			}
 			return handlerSelector.handlePrevIntent(c)
 		case "AMAZON.StopIntent":
 			return handlerSelector.handleStopIntent(rqe, c)
@@ -44,11 +85,11 @@ func (handlerSelector *HandlerSelector) HandleRequest(rqe *request.RequestEnvelo
 	case *request.AudioPlayerPlaybackNearlyFinished:
 		return handlerSelector.handlePlaybackNearlyFinishedEnqueue(rq, c)
 	case *request.AudioPlayerPlaybackFinishedRequest:
-		return handlerSelector.handlePlaybackFinishedAdvanceQueue(rq, c)
+		return handlerSelector.handlePlaybackFinishedAdvanceQueue(rq, device, c)
 	case *request.AudioPlayerPlaybackStartedRequest:
-		return handlerSelector.handlePlaybackStarted(c)
+		return handlerSelector.handlePlaybackStarted(rq, device, c)
 	case *request.AudioPlayerPlaybackStoppedRequest:
-		return handlerSelector.handlePlaybackStopped(rq, c)
+		return handlerSelector.handlePlaybackStopped(rq, device, c)
 	case *request.AudioPlayerPlaybackFailedRequest:
 		return handlerSelector.handlePlaybackFailed(rq, c)
 	default:
@@ -56,8 +97,55 @@ func (handlerSelector *HandlerSelector) HandleRequest(rqe *request.RequestEnvelo
 	}
 }
 
-func (handlerSelector *HandlerSelector) handlePlaybackStarted(c context.Context) (rs *response.ResponseEnvelope) {
+// handleFollowerRequest plays along on a device that doesn't lead the queue (multiroom group member),
+// it is kept on current song but never moves the queue, only the leader's callbacks do
+func (handlerSelector *HandlerSelector) handleFollowerRequest(rqe *request.RequestEnvelope, device string, c context.Context) (rs *response.ResponseEnvelope) {
+	switch rq := rqe.Request.(type) {
+	case *request.IntentRequest:
+		switch rq.Intent.Name {
+		case "AMAZON.ResumeIntent", "AMAZON.NextIntent", "AMAZON.PreviousIntent":
+			log.GetContextLogger(c).Info("|> following leader, playing current song", "intent", rq.Intent.Name)
+			return handlerSelector.handlePlayResumeIntent(c)
+		case "AMAZON.StopIntent", "AMAZON.CancelIntent", "AMAZON.PauseIntent":
+			return handlerSelector.handleStopIntent(rqe, c)
+		}
+	case *request.AudioPlayerPlaybackNearlyFinished:
+		return handlerSelector.handlePlaybackNearlyFinishedEnqueue(rq, c)
+	case *request.AudioPlayerPlaybackStartedRequest:
+		observePlaying(device, true)
+	case *request.AudioPlayerPlaybackStoppedRequest:
+		observePlaying(device, false)
+	case *request.AudioPlayerPlaybackFinishedRequest:
+		log.GetContextLogger(c).Info("? playback finished on follower, not advancing queue", "id_amz", rq.Token)
+	case *request.AudioPlayerPlaybackFailedRequest:
+		observeFailure(rq.Error.Type)
+		log.GetContextLogger(c).Warn("X playback failed on follower, not advancing queue",
+			"amz_id", rq.CurrentPlaybackState.Token,
+			"errorType", rq.Error.Type,
+			"errorMessage", rq.Error.Message)
+	}
+	return handlerSelector.handleDefaultResponse()
+}
+
+// syncPlayQueue saves Navidrome play queue when request moved to another song or stopped playback with position
+func (handlerSelector *HandlerSelector) syncPlayQueue(rqe *request.RequestEnvelope, queuePosition int) {
+	if handlerSelector.PlayQueue == nil {
+		return
+	}
+	_, stopped := rqe.Request.(*request.AudioPlayerPlaybackStoppedRequest)
+	if stopped || queuePosition != handlerSelector.Queue.QueuePosition {
+		handlerSelector.PlayQueue.Changed()
+	}
+}
+
+func (handlerSelector *HandlerSelector) handlePlaybackStarted(rq *request.AudioPlayerPlaybackStartedRequest, device string, c context.Context) (rs *response.ResponseEnvelope) {
+	observeTrack("started")
+	observePlaying(device, true)
+	handlerSelector.Queue.Lead(device)
 	if handlerSelector.Queue.HasItems() {
+		if handlerSelector.Queue.Current().Id == rq.Token {
+			observeStartLatency(handlerSelector.Queue, time.Now())
+		}
 		handlerSelector.Queue.State = model.QueueStatePlaying
 		log.GetContextLogger(c).Info("|> playback started",
 			"id", handlerSelector.Queue.Current().Id,
@@ -66,7 +154,8 @@ func (handlerSelector *HandlerSelector) handlePlaybackStarted(c context.Context)
 	return handlerSelector.handleDefaultResponse()
 }
 
-func (handlerSelector *HandlerSelector) handlePlaybackFinishedAdvanceQueue(rq *request.AudioPlayerPlaybackFinishedRequest, c context.Context) (rs *response.ResponseEnvelope) {
+func (handlerSelector *HandlerSelector) handlePlaybackFinishedAdvanceQueue(rq *request.AudioPlayerPlaybackFinishedRequest, device string, c context.Context) (rs *response.ResponseEnvelope) {
+	observeTrack("finished")
 	if handlerSelector.Queue.HasNext() {
 		if handlerSelector.Queue.Current().Id == rq.Token {
 			handlerSelector.Queue.Next()
@@ -74,12 +163,15 @@ func (handlerSelector *HandlerSelector) handlePlaybackFinishedAdvanceQueue(rq *r
 				"id", handlerSelector.Queue.Current().Id,
 				"name", handlerSelector.Queue.Current().Name)
 		} else {
+			observeTokenMismatch("finished")
 			log.GetContextLogger(c).Info("? playback finished, not advancing queue due un-matching ids",
 				"id_amz", rq.Token,
 				"id", handlerSelector.Queue.Current().Id)
 		}
 	} else {
 		handlerSelector.Queue.State = model.QueueStateIdle
+		handlerSelector.Queue.ReleaseLeader()
+		observePlaying(device, false)
 		log.GetContextLogger(c).Info("|| playback finished, no more items in the queue")
 	}
 	return handlerSelector.handleDefaultResponse()
@@ -87,15 +179,15 @@ func (handlerSelector *HandlerSelector) handlePlaybackFinishedAdvanceQueue(rq *r
 
 func (handlerSelector *HandlerSelector) handlePlaybackNearlyFinishedEnqueue(rq *request.AudioPlayerPlaybackNearlyFinished, c context.Context) (rs *response.ResponseEnvelope) {
 	if handlerSelector.Queue.HasNext() {
-		song := SongToAudioItem(
-			handlerSelector.StreamDomain, 0,
-			handlerSelector.Queue.PeekNext())
+		next := handlerSelector.Queue.PeekNext()
+		song := SongToAudioItem(handlerSelector.streamURL(next), 0, next)
 		song.Stream.ExpectedPreviousToken = handlerSelector.Queue.Current().Id // required for enq
 		if handlerSelector.Queue.Current().Id == rq.AudioPlayerPlaybackBase.Token {
 			log.GetContextLogger(c).Info("+ playback nearly finished, enqueueing next song to play",
 				"id", handlerSelector.Queue.PeekNext().Id,
 				"name", handlerSelector.Queue.PeekNext().Name)
 		} else {
+			observeTokenMismatch("nearly_finished")
 			log.GetContextLogger(c).Info("? playback nearly finished, enqueueing likely to be skipped due un-matching ids",
 				"id_amz", rq.AudioPlayerPlaybackBase.Token,
 				"id", handlerSelector.Queue.Current().Id)
@@ -112,7 +204,8 @@ func (handlerSelector *HandlerSelector) handlePlaybackNearlyFinishedEnqueue(rq *
 	}
 }
 
-func (handlerSelector *HandlerSelector) handlePlaybackStopped(rq *request.AudioPlayerPlaybackStoppedRequest, c context.Context) (rs *response.ResponseEnvelope) {
+func (handlerSelector *HandlerSelector) handlePlaybackStopped(rq *request.AudioPlayerPlaybackStoppedRequest, device string, c context.Context) (rs *response.ResponseEnvelope) {
+	observePlaying(device, false)
 	if handlerSelector.Queue.HasItems() && handlerSelector.Queue.Current().Id == rq.Token {
 		handlerSelector.Queue.TrackPosition = rq.OffsetInMilliseconds // save position
 		log.GetContextLogger(c).Info("|| stopped",
@@ -124,10 +217,12 @@ func (handlerSelector *HandlerSelector) handlePlaybackStopped(rq *request.AudioP
 		log.GetContextLogger(c).Info("|| stopped something not current", "amz_id", rq.Token)
 	}
 	handlerSelector.Queue.State = model.QueueStateIdle
+	handlerSelector.Queue.ReleaseLeader()
 	return handlerSelector.handleDefaultResponse()
 }
 
 func (handlerSelector *HandlerSelector) handlePlaybackFailed(rq *request.AudioPlayerPlaybackFailedRequest, c context.Context) (rs *response.ResponseEnvelope) {
+	observeFailure(rq.Error.Type)
 	if handlerSelector.Queue.HasItems() {
 		log.GetContextLogger(c).Warn("X playback failed",
 			"amz_id", rq.CurrentPlaybackState.Token,
@@ -152,10 +247,8 @@ func (handlerSelector *HandlerSelector) handlePlayResumeIntent(c context.Context
 			"id", handlerSelector.Queue.Current().Id,
 			"name", handlerSelector.Queue.Current().Name,
 			"time", handlerSelector.Queue.TrackPosition)
-		song := SongToAudioItem(
-			handlerSelector.StreamDomain,
-			handlerSelector.Queue.TrackPosition,
-			handlerSelector.Queue.Current())
+		current := handlerSelector.Queue.Current()
+		song := SongToAudioItem(handlerSelector.streamURL(current), handlerSelector.Queue.TrackPosition, current)
 		return response.NewResponseBuilder().
 			WithShouldEndSession(true).
 			AddAudioPlayerPlayDirective(response.NewAudioPlayerPlayDirectiveBuilder().
@@ -170,7 +263,8 @@ func (handlerSelector *HandlerSelector) handlePlayResumeIntent(c context.Context
 
 func (handlerSelector *HandlerSelector) handleNextIntent(c context.Context) (rs *response.ResponseEnvelope) {
 	if handlerSelector.Queue.HasNext() {
-		song := SongToAudioItem(handlerSelector.StreamDomain, 0, handlerSelector.Queue.Next())
+		next := handlerSelector.Queue.Next()
+		song := SongToAudioItem(handlerSelector.streamURL(next), 0, next)
 		log.GetContextLogger(c).Info(">> skipping to next", "id", song.Stream.Token, "name", song.Metadata.Title)
 		return response.NewResponseBuilder().
 			WithShouldEndSession(true).
@@ -187,7 +281,8 @@ func (handlerSelector *HandlerSelector) handleNextIntent(c context.Context) (rs
 func (handlerSelector *HandlerSelector) handlePrevIntent(c context.Context) (rs *response.ResponseEnvelope) {
 	if handlerSelector.Queue.HasPrev() {
 		log.GetContextLogger(c).Info("<< skipping back", "id", handlerSelector.Queue.Current().Id, "name", handlerSelector.Queue.Current().Name)
-		song := SongToAudioItem(handlerSelector.StreamDomain, 0, handlerSelector.Queue.Prev())
+		prev := handlerSelector.Queue.Prev()
+		song := SongToAudioItem(handlerSelector.streamURL(prev), 0, prev)
 		return response.NewResponseBuilder().
 			WithShouldEndSession(true).
 			AddAudioPlayerPlayDirective(response.NewAudioPlayerPlayDirectiveBuilder().
@@ -224,11 +319,11 @@ func (handlerSelector *HandlerSelector) handleDefaultResponse() (rs *response.Re
 	return response.NewResponseBuilder().WithShouldEndSession(true).Build()
 }
 
-func SongToAudioItem(streamDomain string, offset int, song *model.Song) (ai *response.AudioItem) {
+func SongToAudioItem(streamURL string, offset int, song *model.Song) (ai *response.AudioItem) {
 	return response.NewAudioItemBuilder().
 		WithStream(response.NewStreamBuilder().
 			WithToken(song.Id).
-			WithURL(streamDomain + song.Stream).
+			WithURL(streamURL).
 			WithOffsetInMilliseconds(offset).
 			Build()).
 		WithMetadata(response.NewMetadataBuilder().
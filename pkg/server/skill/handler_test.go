@@ -205,6 +205,115 @@ func TestHandlerSelectorPlaybackStoppedCallback(t *testing.T) {
 	})
 }
 
+func TestHandlerSelectorPlayQueueSync(t *testing.T) {
+	for _, testCase := range []struct {
+		name    string
+		request *request.RequestEnvelope
+		changed int
+	}{
+		{"NextIntent, moves to another song, should save play queue", intent("AMAZON.NextIntent"), 1},
+		{"PreviousIntent, moves to another song, should save play queue", intent("AMAZON.PreviousIntent"), 1},
+		{"PlaybackFinished, advances queue, should save play queue", playbackFinished("Id2"), 1},
+		{"PlaybackStopped, should save play queue with position", playbackStopped("Id2", 134), 1},
+		{"PlaybackStarted, same song, should not save play queue", playbackStarted("Id2"), 0},
+		{"PlaybackNearlyFinished, same song, should not save play queue", playbackNearlyFinished("Id2"), 0},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			playQueue := &MockPlayQueueSync{}
+			handlerSelector := NewHandlerSelector(queue(1), "example.com")
+			handlerSelector.PlayQueue = playQueue
+
+			handlerSelector.HandleRequest(testCase.request, ctx())
+
+			assert.Equal(t, testCase.changed, playQueue.changed)
+		})
+	}
+}
+
+type MockPlayQueueSync struct {
+	changed int
+}
+
+func (m *MockPlayQueueSync) Changed() {
+	m.changed++
+}
+
+type MockStreamAuthenticator struct{}
+
+func (m *MockStreamAuthenticator) Authenticate(path string) string {
+	return path + "?u=user"
+}
+
+func TestHandlerSelectorStreamAuth(t *testing.T) {
+
+	t.Run("Navidrome song stream is authenticated, queue keeps plain path", func(t *testing.T) {
+		queue := queue(1)
+		handlerSelector := NewHandlerSelector(queue, "example.com")
+		handlerSelector.StreamAuth = &MockStreamAuthenticator{}
+		responseEnvelope := handlerSelector.HandleRequest(intent("AMAZON.NextIntent"), ctx())
+
+		dir := responseEnvelope.Response.Directives[0].(*response.AudioPlayerPlayDirective)
+		assert.Equal(t, "example.com/Stream3?u=user", dir.AudioItem.Stream.URL)
+		assert.Equal(t, "/Stream3", queue.Songs[2].Stream)
+	})
+
+	t.Run("external song stream is used as is", func(t *testing.T) {
+		queue := queue(1)
+		queue.Songs[2].Stream = "https://radio.example.com/stream"
+		handlerSelector := NewHandlerSelector(queue, "example.com")
+		handlerSelector.StreamAuth = &MockStreamAuthenticator{}
+		responseEnvelope := handlerSelector.HandleRequest(intent("AMAZON.NextIntent"), ctx())
+
+		dir := responseEnvelope.Response.Directives[0].(*response.AudioPlayerPlayDirective)
+		assert.Equal(t, "https://radio.example.com/stream", dir.AudioItem.Stream.URL)
+	})
+
+}
+
+func TestHandlerSelectorGroupFollowers(t *testing.T) {
+
+	t.Run("only the device that started playing first advances the queue", func(t *testing.T) {
+		queue := queue(0)
+		handlerSelector := NewHandlerSelector(queue, "example.com")
+		handlerSelector.HandleRequest(onDevice(playbackStarted("Id1"), "leader"), ctx())
+		handlerSelector.HandleRequest(onDevice(playbackStarted("Id1"), "member"), ctx())
+
+		handlerSelector.HandleRequest(onDevice(playbackFinished("Id1"), "member"), ctx())
+		assert.Equal(t, 0, queue.QueuePosition)
+		handlerSelector.HandleRequest(onDevice(playbackFailed("Id1"), "member"), ctx())
+		assert.Equal(t, 0, queue.QueuePosition)
+		handlerSelector.HandleRequest(onDevice(playbackFinished("Id1"), "leader"), ctx())
+		assert.Equal(t, 1, queue.QueuePosition)
+	})
+
+	t.Run("next intent on follower plays current song without moving the queue", func(t *testing.T) {
+		queue := queue(1)
+		queue.TrackPosition = 0
+		handlerSelector := NewHandlerSelector(queue, "example.com")
+		handlerSelector.HandleRequest(onDevice(playbackStarted("Id2"), "leader"), ctx())
+
+		responseEnvelope := handlerSelector.HandleRequest(onDevice(intent("AMAZON.NextIntent"), "member"), ctx())
+
+		assert.Equal(t, 1, queue.QueuePosition)
+		dir := responseEnvelope.Response.Directives[0].(*response.AudioPlayerPlayDirective)
+		assert.Equal(t, expectedAudioItem(2, 0), dir.AudioItem)
+	})
+
+	t.Run("follower stop keeps queue, leader stop lets another device lead", func(t *testing.T) {
+		queue := queue(1)
+		handlerSelector := NewHandlerSelector(queue, "example.com")
+		handlerSelector.HandleRequest(onDevice(playbackStarted("Id2"), "leader"), ctx())
+
+		handlerSelector.HandleRequest(onDevice(playbackStopped("Id2", 5000), "member"), ctx())
+		assert.Equal(t, model.QueueStatePlaying, queue.State)
+		assert.Equal(t, 123, queue.TrackPosition)
+		handlerSelector.HandleRequest(onDevice(playbackStopped("Id2", 5000), "leader"), ctx())
+		assert.Equal(t, model.QueueStateIdle, queue.State)
+		assert.False(t, queue.IsFollower("member"))
+	})
+
+}
+
 func TestHandlerSelectorPlaybackNearlyFinishedCallback(t *testing.T) {
 
 	t.Run("PlaybackNearlyFinished should enqueue next song without advancing queue (that happens in finished)", func(t *testing.T) {
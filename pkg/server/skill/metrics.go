@@ -0,0 +1,109 @@
+package skill
+
+import (
+	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+// play requests older than this are not matched to playback start, Alexa likely ignored the command
+const playRequestMatchWindow = time.Minute
+
+var (
+	playbackTracksTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "playback_tracks_total",
+			Help: "Count of tracks by playback event: started, finished, skipped",
+		},
+		[]string{"event"},
+	)
+
+	playbackFailuresTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "playback_failures_total",
+			Help: "Count of failed tracks by Alexa error type",
+		},
+		[]string{"error_type"},
+	)
+
+	playbackTokenMismatchesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "playback_token_mismatches_total",
+			Help: "Count of playback callbacks with token not matching current queue item",
+		},
+		[]string{"event"},
+	)
+
+	queueLengthDesc = prometheus.NewDesc("playback_queue_length", "Number of songs in the queue", nil, nil)
+
+	queuePositionDesc = prometheus.NewDesc("playback_queue_position", "Current position in the queue", nil, nil)
+
+	playbackPlaying = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "playback_playing",
+			Help: "1 if device is playing our skill, 0 otherwise, by skill device id",
+		},
+		[]string{"device"},
+	)
+
+	playbackStartLatency = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "playback_start_latency_seconds",
+			Help:    "Time from REST play command to matching playback started callback",
+			Buckets: []float64{0.5, 1, 2, 3, 5, 8, 13, 21, 34},
+		},
+	)
+)
+
+func observeTrack(event string) {
+	playbackTracksTotal.WithLabelValues(event).Inc()
+}
+
+func observeFailure(errorType string) {
+	playbackFailuresTotal.WithLabelValues(errorType).Inc()
+}
+
+func observeTokenMismatch(event string) {
+	playbackTokenMismatchesTotal.WithLabelValues(event).Inc()
+}
+
+func observePlaying(device string, playing bool) {
+	if device == "" {
+		return
+	}
+	if playing {
+		playbackPlaying.WithLabelValues(device).Set(1)
+	} else {
+		playbackPlaying.WithLabelValues(device).Set(0)
+	}
+}
+
+// queueCollector reads queue gauges at scrape time, queue is changed by skill callbacks as well as by queue API
+type queueCollector struct {
+	queue *model.Queue
+}
+
+// NewQueueCollector returns collector of queue gauges, to be registered once per queue
+func NewQueueCollector(queue *model.Queue) prometheus.Collector {
+	return &queueCollector{queue: queue}
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueLengthDesc
+	ch <- queuePositionDesc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	c.queue.Lock()
+	length, position := len(c.queue.Songs), c.queue.QueuePosition
+	c.queue.Unlock()
+	ch <- prometheus.MustNewConstMetric(queueLengthDesc, prometheus.GaugeValue, float64(length))
+	ch <- prometheus.MustNewConstMetric(queuePositionDesc, prometheus.GaugeValue, float64(position))
+}
+
+func observeStartLatency(queue *model.Queue, now time.Time) {
+	if requestedAt, ok := queue.TakePlayRequested(); ok && now.Sub(requestedAt) <= playRequestMatchWindow {
+		playbackStartLatency.Observe(now.Sub(requestedAt).Seconds())
+	}
+}
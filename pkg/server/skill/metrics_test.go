@@ -0,0 +1,112 @@
+package skill
+
+import (
+	"github.com/ahimgit/navidrome-alexa/pkg/alexa/skill/model/request"
+	"github.com/ahimgit/navidrome-alexa/pkg/server/api/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// metrics are global, tests check deltas
+
+func TestHandlerSelectorMetrics(t *testing.T) {
+
+	t.Run("track events", func(t *testing.T) {
+		handlerSelector := NewHandlerSelector(queue(1), "example.com")
+		startedBefore := testutil.ToFloat64(playbackTracksTotal.WithLabelValues("started"))
+		finishedBefore := testutil.ToFloat64(playbackTracksTotal.WithLabelValues("finished"))
+		skippedBefore := testutil.ToFloat64(playbackTracksTotal.WithLabelValues("skipped"))
+		failedBefore := testutil.ToFloat64(playbackFailuresTotal.WithLabelValues("ERROR_TYPE"))
+
+		handlerSelector.HandleRequest(playbackStarted("Id2"), ctx())
+		handlerSelector.HandleRequest(playbackFinished("Id2"), ctx())
+		handlerSelector.HandleRequest(intent("AMAZON.PreviousIntent"), ctx())
+		handlerSelector.HandleRequest(playbackFailed("Id2"), ctx())
+
+		assert.Equal(t, startedBefore+1, testutil.ToFloat64(playbackTracksTotal.WithLabelValues("started")))
+		assert.Equal(t, finishedBefore+1, testutil.ToFloat64(playbackTracksTotal.WithLabelValues("finished")))
+		assert.Equal(t, skippedBefore+1, testutil.ToFloat64(playbackTracksTotal.WithLabelValues("skipped")))
+		assert.Equal(t, failedBefore+1, testutil.ToFloat64(playbackFailuresTotal.WithLabelValues("ERROR_TYPE")))
+	})
+
+	t.Run("token mismatches", func(t *testing.T) {
+		handlerSelector := NewHandlerSelector(queue(1), "example.com")
+		finishedBefore := testutil.ToFloat64(playbackTokenMismatchesTotal.WithLabelValues("finished"))
+		nearlyFinishedBefore := testutil.ToFloat64(playbackTokenMismatchesTotal.WithLabelValues("nearly_finished"))
+
+		handlerSelector.HandleRequest(playbackNearlyFinished("UNKNOWN"), ctx())
+		handlerSelector.HandleRequest(playbackFinished("UNKNOWN"), ctx())
+
+		assert.Equal(t, finishedBefore+1, testutil.ToFloat64(playbackTokenMismatchesTotal.WithLabelValues("finished")))
+		assert.Equal(t, nearlyFinishedBefore+1, testutil.ToFloat64(playbackTokenMismatchesTotal.WithLabelValues("nearly_finished")))
+	})
+
+	t.Run("queue and playing state per device", func(t *testing.T) {
+		handlerSelector := NewHandlerSelector(queue(1), "example.com")
+
+		handlerSelector.HandleRequest(onDevice(playbackStarted("Id2"), "device1"), ctx())
+		handlerSelector.HandleRequest(onDevice(playbackStarted("Id2"), "device2"), ctx())
+		handlerSelector.HandleRequest(onDevice(playbackStopped("Id2", 10), "device2"), ctx())
+
+		assert.Equal(t, map[string]float64{"playback_queue_length": 3, "playback_queue_position": 1}, queueGauges(t, handlerSelector.Queue))
+		assert.Equal(t, float64(1), testutil.ToFloat64(playbackPlaying.WithLabelValues("device1")))
+		assert.Equal(t, float64(0), testutil.ToFloat64(playbackPlaying.WithLabelValues("device2")))
+	})
+
+	t.Run("queue changed outside skill callbacks", func(t *testing.T) {
+		queue := queue(1)
+
+		queue.Lock()
+		queue.Songs = queue.Songs[:2]
+		queue.QueuePosition = 0
+		queue.Unlock()
+
+		assert.Equal(t, map[string]float64{"playback_queue_length": 2, "playback_queue_position": 0}, queueGauges(t, queue))
+	})
+
+	t.Run("play command to playback started latency", func(t *testing.T) {
+		queue := queue(1)
+		handlerSelector := NewHandlerSelector(queue, "example.com")
+		countBefore := startLatencyCount(t)
+
+		queue.MarkPlayRequested(time.Now().Add(-2 * time.Second))
+		handlerSelector.HandleRequest(playbackStarted("UNKNOWN"), ctx()) // not matching, keeps pending request
+		handlerSelector.HandleRequest(playbackStarted("Id2"), ctx())
+		handlerSelector.HandleRequest(playbackStarted("Id2"), ctx()) // no pending request
+
+		queue.MarkPlayRequested(time.Now().Add(-playRequestMatchWindow - time.Second))
+		handlerSelector.HandleRequest(playbackStarted("Id2"), ctx()) // too old
+
+		assert.Equal(t, countBefore+1, startLatencyCount(t))
+	})
+}
+
+func queueGauges(t *testing.T, queue *model.Queue) map[string]float64 {
+	t.Helper()
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(NewQueueCollector(queue)))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	gauges := map[string]float64{}
+	for _, family := range families {
+		gauges[family.GetName()] = family.GetMetric()[0].GetGauge().GetValue()
+	}
+	return gauges
+}
+
+func onDevice(rqe *request.RequestEnvelope, device string) *request.RequestEnvelope {
+	rqe.Context.System.Device.DeviceID = device
+	return rqe
+}
+
+func startLatencyCount(t *testing.T) uint64 {
+	t.Helper()
+	metric := &dto.Metric{}
+	require.NoError(t, playbackStartLatency.(prometheus.Metric).Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
@@ -3,13 +3,18 @@ package skill
 import (
 	"github.com/ahimgit/navidrome-alexa/pkg/alexa/skill/model/request"
 	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tracing"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"sync/atomic"
+	"time"
 )
 
 type SkillAPI struct {
 	HandlerSelector IHandlerSelector
 	AlexaSkillId    string
+	CommandLinks    *tracing.CommandLinks // optional, links skill requests to commands that caused them
+	lastRequestAt   atomic.Int64          // unix nanos of last authorized skill request, for health status
 }
 
 func NewSkillAPI(handlerSelector IHandlerSelector, alexaSkillId string) *SkillAPI {
@@ -32,5 +37,15 @@ func (api *SkillAPI) Post(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "Unauthorized"})
 		return
 	}
+	api.lastRequestAt.Store(time.Now().UnixNano())
+	api.CommandLinks.AddLinks(c.Request.Context(), requestEnvelope.Context.System.Device.DeviceID)
 	c.JSON(http.StatusOK, api.HandlerSelector.HandleRequest(&requestEnvelope, log.CreateLoggerContext(c)))
 }
+
+// LastRequestAt is zero if no skill requests were received yet
+func (api *SkillAPI) LastRequestAt() time.Time {
+	if lastRequestAt := api.lastRequestAt.Load(); lastRequestAt != 0 {
+		return time.Unix(0, lastRequestAt)
+	}
+	return time.Time{}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
 func TestSkillAPI(t *testing.T) {
@@ -31,6 +32,7 @@ func TestSkillAPI(t *testing.T) {
 
 		assert.Equal(t, rs, responseRecorder.Body.String())
 		assert.Equal(t, 200, responseRecorder.Code)
+		assert.WithinDuration(t, time.Now(), skillAPI.LastRequestAt(), time.Second)
 
 		mockHandler.AssertExpectations(t)
 	})
@@ -61,6 +63,7 @@ func TestSkillAPI(t *testing.T) {
 
 		assert.Equal(t, rs, responseRecorder.Body.String())
 		assert.Equal(t, 401, responseRecorder.Code)
+		assert.True(t, skillAPI.LastRequestAt().IsZero())
 	})
 }
 
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"crypto/tls"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var tlsVersions = map[string]uint16{"1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13}
+
+const certificateCheckInterval = 10 * time.Second
+
+// CertificateReloader serves certificate from cert and key files, files are checked for changes at most once per
+// check interval during handshakes and loaded again if modified (e.g. renewed by certbot). Keeps serving the last
+// valid certificate if new files can't be loaded, e.g. when caught in the middle of renewal
+type CertificateReloader struct {
+	certFile      string
+	keyFile       string
+	checkInterval time.Duration
+	mutex         sync.Mutex
+	certificate   *tls.Certificate
+	modified      time.Time
+	checkedAt     time.Time
+	now           func() time.Time
+}
+
+func NewCertificateReloader(certFile string, keyFile string) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{certFile: certFile, keyFile: keyFile, checkInterval: certificateCheckInterval, now: time.Now}
+	modified, err := reloader.lastModified()
+	if err != nil {
+		return nil, err
+	}
+	if err = reloader.load(modified); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// GetCertificate is tls.Config GetCertificate callback
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.now().Sub(r.checkedAt) >= r.checkInterval {
+		r.checkedAt = r.now()
+		modified, err := r.lastModified()
+		if err != nil {
+			log.Logger().Error("Unable to check TLS certificate files, keeping current certificate", "error", err)
+		} else if !modified.Equal(r.modified) {
+			if err = r.load(modified); err != nil {
+				log.Logger().Error("Unable to reload TLS certificate, keeping current certificate", "error", err)
+			} else {
+				log.Logger().Info("Reloaded TLS certificate", "certFile", r.certFile)
+			}
+		}
+	}
+	return r.certificate, nil
+}
+
+func (r *CertificateReloader) load(modified time.Time) error {
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "CertificateReloader.load failed")
+	}
+	r.certificate = &certificate
+	r.modified = modified
+	return nil
+}
+
+// lastModified is the latest of cert and key files modification times
+func (r *CertificateReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "CertificateReloader.lastModified failed")
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func tlsConfig(config *Config, certificates *CertificateReloader) *tls.Config {
+	minVersion, exists := tlsVersions[config.TlsMinVersion]
+	if !exists {
+		minVersion = tls.VersionTLS12
+	}
+	return &tls.Config{MinVersion: minVersion, GetCertificate: certificates.GetCertificate}
+}
+
+// httpsRedirect redirects plain HTTP requests to the same host and path on TLS listen address port,
+// permanent redirect keeps method and body so Alexa (or anything else) posting to http still gets there
+func httpsRedirect(listenAddress string) http.Handler {
+	_, port, _ := net.SplitHostPort(listenAddress) // validated with config
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		host := request.Host
+		if hostOnly, _, err := net.SplitHostPort(host); err == nil {
+			host = hostOnly
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(writer, request, "https://"+host+request.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// serve listens on listenAddress, with TLS if cert and key files are set, and optionally redirects plain HTTP
+// requests from httpRedirectAddress. Returns when listener fails
+func serve(config *Config, handler http.Handler) error {
+	if config.TlsCertFile == "" {
+		log.Logger().Info("Starting NA", "listenAddress", config.ListenAddress)
+		return http.ListenAndServe(config.ListenAddress, handler)
+	}
+	certificates, err := NewCertificateReloader(config.TlsCertFile, config.TlsKeyFile)
+	if err != nil {
+		return err
+	}
+	if config.HttpRedirectAddress != "" {
+		go func() {
+			log.Logger().Info("Starting HTTP to HTTPS redirect", "httpRedirectAddress", config.HttpRedirectAddress)
+			log.Logger().Error("Error starting HTTP redirect", "error",
+				http.ListenAndServe(config.HttpRedirectAddress, httpsRedirect(config.ListenAddress)))
+		}()
+	}
+	server := &http.Server{Addr: config.ListenAddress, Handler: handler, TLSConfig: tlsConfig(config, certificates)}
+	log.Logger().Info("Starting NA with TLS", "listenAddress", config.ListenAddress, "tlsMinVersion", config.TlsMinVersion)
+	return server.ListenAndServeTLS("", "")
+}
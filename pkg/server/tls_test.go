@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertificateReloader(t *testing.T) {
+
+	t.Run("GetCertificate, reloads changed files after check interval", func(t *testing.T) {
+		certFile, keyFile := writeCertificate(t, t.TempDir(), "first.example.com", time.Now().Add(-time.Hour))
+		reloader, err := NewCertificateReloader(certFile, keyFile)
+		assert.NoError(t, err)
+		now := time.Now()
+		reloader.now = func() time.Time { return now }
+
+		assert.Equal(t, "first.example.com", certificateName(t, reloader))
+
+		writeCertificate(t, filepath.Dir(certFile), "second.example.com", time.Now())
+		assert.Equal(t, "first.example.com", certificateName(t, reloader), "not checked again within interval")
+
+		now = now.Add(certificateCheckInterval)
+		assert.Equal(t, "second.example.com", certificateName(t, reloader))
+	})
+
+	t.Run("GetCertificate, keeps certificate if new files are invalid", func(t *testing.T) {
+		certFile, keyFile := writeCertificate(t, t.TempDir(), "first.example.com", time.Now().Add(-time.Hour))
+		reloader, err := NewCertificateReloader(certFile, keyFile)
+		assert.NoError(t, err)
+		now := time.Now()
+		reloader.now = func() time.Time { return now }
+		assert.NoError(t, os.WriteFile(keyFile, []byte("renewal in progress"), 0600))
+
+		now = now.Add(certificateCheckInterval)
+		assert.Equal(t, "first.example.com", certificateName(t, reloader))
+	})
+
+	t.Run("NewCertificateReloader, missing files", func(t *testing.T) {
+		_, err := NewCertificateReloader(filepath.Join(t.TempDir(), "cert.pem"), filepath.Join(t.TempDir(), "key.pem"))
+		assert.ErrorContains(t, err, "CertificateReloader.lastModified failed")
+	})
+
+}
+
+func TestTlsConfig(t *testing.T) {
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig(&Config{}, nil).MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig(&Config{TlsMinVersion: "1.3"}, nil).MinVersion)
+}
+
+func TestHttpsRedirect(t *testing.T) {
+
+	t.Run("httpsRedirect, default https port", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		httpsRedirect(":443").ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "http://na.example.com:80/skill?a=b", nil))
+		assert.Equal(t, http.StatusPermanentRedirect, recorder.Code)
+		assert.Equal(t, "https://na.example.com/skill?a=b", recorder.Header().Get("Location"))
+	})
+
+	t.Run("httpsRedirect, custom https port", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		httpsRedirect("0.0.0.0:8443").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://na.example.com/health", nil))
+		assert.Equal(t, "https://na.example.com:8443/health", recorder.Header().Get("Location"))
+	})
+
+}
+
+func certificateName(t *testing.T, reloader *CertificateReloader) string {
+	certificate, err := reloader.GetCertificate(nil)
+	assert.NoError(t, err)
+	parsed, err := x509.ParseCertificate(certificate.Certificate[0])
+	assert.NoError(t, err)
+	return parsed.Subject.CommonName
+}
+
+// writeCertificate writes self-signed cert.pem and key.pem to dir with modification time set
+func writeCertificate(t *testing.T, dir string, name string, modified time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	assert.NoError(t, os.Chtimes(certFile, modified, modified))
+	assert.NoError(t, os.Chtimes(keyFile, modified, modified))
+	return certFile, keyFile
+}
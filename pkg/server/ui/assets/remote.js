@@ -0,0 +1,508 @@
+const naRemoteModule = (function () {
+    // remote.html is served from NA /static, API is next to it (also under /na in Navidrome proxy mode)
+    const apiUrl = window.location.pathname.replace(/\/static\/[^/]*$/, '');
+    const pollInterval = 5000;
+
+    class Settings {
+        #storage;
+        #data;
+
+        constructor(storage) {
+            this.#storage = storage;
+            this.#data = JSON.parse(this.#storage.getItem('naRemoteSettings')) || {};
+        }
+
+        save() {
+            this.#storage.setItem('naRemoteSettings', JSON.stringify(this.#data));
+        }
+
+        getApiKey() {
+            return this.#data.apiKey || '';
+        }
+
+        setApiKey(apiKey) {
+            this.#data.apiKey = apiKey.trim();
+        }
+
+        getDeviceSerialNumber() {
+            return this.#data.deviceSerialNumber;
+        }
+
+        setDeviceSerialNumber(serialNumber) {
+            this.#data.deviceSerialNumber = serialNumber;
+        }
+
+        // credentials Navidrome UI keeps for Subsonic calls, available when served on Navidrome origin (proxy mode)
+        getNavidromeCredentials() {
+            const user = this.#storage.getItem('username');
+            const token = this.#storage.getItem('subsonic-token');
+            const salt = this.#storage.getItem('subsonic-salt');
+            return user && token && salt ? {user: user, token: token, salt: salt} : null;
+        }
+    }
+
+    class RemoteAPI {
+        #settings;
+
+        constructor(settings) {
+            this.#settings = settings;
+        }
+
+        getDevices() {
+            return this.#callAPI('GET', '/api/devices');
+        }
+
+        getPlayerState(serialNumber) {
+            return this.#callAPI('GET', `/api/devices/${encodeURIComponent(serialNumber)}/state`);
+        }
+
+        getQueue() {
+            return this.#callAPI('GET', '/api/queue');
+        }
+
+        postQueue(queue) {
+            return this.#callAPI('POST', '/api/queue', queue);
+        }
+
+        postCommand(command, device) {
+            return this.#callAPI('POST', `/api/${command}`, device);
+        }
+
+        getVolume(serialNumber) {
+            return this.#callAPI('GET', `/api/volume?serial=${encodeURIComponent(serialNumber)}`);
+        }
+
+        postVolume(device, volume) {
+            return this.#callAPI('POST', '/api/volume', {device: device, volume: volume});
+        }
+
+        getLibrary() {
+            return this.#callAPI('GET', '/api/library');
+        }
+
+        postResume() {
+            return this.#callAPI('POST', '/api/queue/resume');
+        }
+
+        search(query) {
+            return this.#callAPI('GET', `/api/library/search?query=${encodeURIComponent(query)}`);
+        }
+
+        // cover art is fetched with API credentials (img src can't send them), returns object URL or null
+        async getCover(id) {
+            const path = `/api/library/cover/${encodeURIComponent(id)}`;
+            try {
+                const response = await fetch(apiUrl + path, {headers: this.#headers()});
+                if (!response.ok) {
+                    console.log('naR', 'got error back from the api', 'GET', path, response.status);
+                    return null;
+                }
+                return URL.createObjectURL(await response.blob());
+            } catch (error) {
+                console.log('naR', 'error calling api', 'GET', path, error);
+                return null;
+            }
+        }
+
+        #headers() {
+            const headers = new Headers();
+            const credentials = this.#settings.getNavidromeCredentials();
+            if (this.#settings.getApiKey() || !credentials) {
+                headers.append('Authorization', `Bearer ${this.#settings.getApiKey()}`);
+            } else {
+                headers.append('X-Subsonic-User', credentials.user);
+                headers.append('X-Subsonic-Token', credentials.token);
+                headers.append('X-Subsonic-Salt', credentials.salt);
+            }
+            return headers;
+        }
+
+        async #callAPI(method, path, requestBody) {
+            try {
+                const headers = this.#headers();
+                const request = {method: method, headers: headers};
+                if (requestBody) {
+                    headers.append('Content-Type', 'application/json');
+                    request.body = JSON.stringify(requestBody);
+                }
+                const response = await fetch(apiUrl + path, request);
+                const responseBody = await response.json();
+                if (!response.ok) {
+                    console.log('naR', 'got error back from the api', method, path, responseBody);
+                    return {error: responseBody.message || response.statusText, status: response.status};
+                }
+                return responseBody;
+            } catch (error) {
+                console.log('naR', 'error calling api', method, path, error);
+                return {error: error.message};
+            }
+        }
+    }
+
+    // queue edits keep queue position on the same song where possible
+    const QueueEdits = {
+        move(queue, index, delta) {
+            const target = index + delta;
+            if (target < 0 || target >= queue.queue.length) {
+                return false;
+            }
+            [queue.queue[index], queue.queue[target]] = [queue.queue[target], queue.queue[index]];
+            if (queue.queuePosition === index) {
+                queue.queuePosition = target;
+            } else if (queue.queuePosition === target) {
+                queue.queuePosition = index;
+            }
+            return true;
+        },
+
+        remove(queue, index) {
+            queue.queue.splice(index, 1);
+            if (index < queue.queuePosition || queue.queuePosition >= queue.queue.length) {
+                queue.queuePosition = Math.max(0, queue.queuePosition - 1);
+            }
+            return true;
+        },
+
+        add(queue, song, next) {
+            if (next && queue.queue.length > 0) {
+                queue.queue.splice(queue.queuePosition + 1, 0, song);
+            } else {
+                queue.queue.push(song);
+            }
+            return true;
+        },
+
+        select(queue, index) {
+            queue.queuePosition = index;
+            queue.trackPosition = 0;
+            return true;
+        },
+    };
+
+    function formatTime(ms) {
+        const seconds = Math.max(0, Math.floor((ms || 0) / 1000));
+        return `${Math.floor(seconds / 60)}:${String(seconds % 60).padStart(2, '0')}`;
+    }
+
+    function isAbsolute(link) {
+        return /^https?:\/\//.test(link);
+    }
+
+    // Navidrome cover art id of relative cover path (library search or Navidrome UI, may carry its credentials)
+    function coverArtId(cover) {
+        return isAbsolute(cover) ? null : new URL(cover, window.location.origin).searchParams.get('id');
+    }
+
+    function element(tag, className, text) {
+        const created = document.createElement(tag);
+        if (className) {
+            created.className = className;
+        }
+        if (text !== undefined) {
+            created.textContent = text;
+        }
+        return created;
+    }
+
+    class Remote {
+        #settings;
+        #api;
+        #devices = [];
+        #queue;
+        #playerState;
+        #stateReceivedAt = 0;
+        #volumeTimer;
+        #coverFor; // song cover shown or being loaded
+
+        constructor(settings, api) {
+            this.#settings = settings;
+            this.#api = api;
+        }
+
+        #get(id) {
+            return document.getElementById(id);
+        }
+
+        #status(message, level) {
+            const status = this.#get('status');
+            status.textContent = message || '';
+            status.className = 'status ' + (level || '');
+        }
+
+        #device() {
+            return this.#devices.find(device => device.serialNumber === this.#settings.getDeviceSerialNumber());
+        }
+
+        async #loadDevices() {
+            const rs = await this.#api.getDevices();
+            if (rs.error) {
+                this.#status(rs.status === 401 ? 'Set API key in settings' : `Error loading devices: ${rs.error}`, 'error');
+                this.#get('settings').hidden = rs.status !== 401 && this.#get('settings').hidden;
+                return;
+            }
+            this.#devices = rs.devices || [];
+            const select = this.#get('device');
+            select.replaceChildren(...this.#devices.map(device => {
+                const option = element('option', null, device.name + (device.online ? '' : ' (offline)'));
+                option.value = device.serialNumber;
+                return option;
+            }));
+            if (!this.#device() && this.#devices.length > 0) {
+                this.#settings.setDeviceSerialNumber(this.#devices[0].serialNumber);
+                this.#settings.save();
+            }
+            select.value = this.#settings.getDeviceSerialNumber() || '';
+            this.#status('');
+            await this.#loadVolume();
+        }
+
+        async #loadVolume() {
+            const device = this.#device();
+            if (!device) {
+                return;
+            }
+            const serialNumber = device.members && device.members.length > 0 ? device.members[0].serialNumber : device.serialNumber;
+            const rs = await this.#api.getVolume(serialNumber);
+            if (!rs.error) {
+                this.#get('volume').value = rs.volume;
+            }
+        }
+
+        async #loadLibrary() {
+            const rs = await this.#api.getLibrary();
+            this.#get('library').hidden = !!rs.error || !rs.enabled;
+        }
+
+        async #refresh() {
+            const queue = await this.#api.getQueue();
+            if (!queue.error) {
+                this.#queue = queue;
+                this.#renderQueue();
+            }
+            const device = this.#device();
+            if (device) {
+                // groups don't report player state, their first member plays the same
+                const serialNumber = device.members && device.members.length > 0 ? device.members[0].serialNumber : device.serialNumber;
+                const state = await this.#api.getPlayerState(serialNumber);
+                this.#playerState = state.error ? null : state;
+                this.#stateReceivedAt = Date.now();
+            }
+            this.#renderNowPlaying();
+        }
+
+        #renderNowPlaying() {
+            const queue = this.#queue;
+            const song = queue && queue.queue.length > 0 ? queue.queue[queue.queuePosition] : null;
+            const state = this.#playerState;
+            // ownPlayback is null when provider can't be told apart, trust it while our queue plays
+            const ownPlayback = state && (state.ownPlayback ?? (queue && queue.state === 'PLAYING'));
+            this.#get('title').textContent = song ? song.name : 'Nothing playing';
+            this.#get('subtitle').textContent = song ? `${song.artist} · ${song.album}` : '';
+            const cover = song ? song.cover : '';
+            if (!cover) {
+                this.#get('cover').hidden = true;
+                this.#coverFor = null;
+            } else if (cover !== this.#coverFor) {
+                this.#coverFor = cover;
+                this.#loadCover(cover);
+            }
+            let progress = ownPlayback ? state.progress : 0;
+            if (ownPlayback && state.state === 'PLAYING') {
+                progress += Date.now() - this.#stateReceivedAt;
+            }
+            const duration = (ownPlayback && state.duration) || (song ? song.duration : 0);
+            progress = Math.min(progress, duration);
+            this.#get('elapsed').textContent = formatTime(progress);
+            this.#get('duration').textContent = formatTime(duration);
+            this.#get('progress').value = duration > 0 ? progress / duration : 0;
+        }
+
+        // imported songs have absolute cover URL, shown as is, Navidrome covers are loaded through NA API
+        async #loadCover(cover) {
+            const id = coverArtId(cover);
+            const src = id ? await this.#api.getCover(id) : (isAbsolute(cover) ? cover : null);
+            if (cover !== this.#coverFor) { // song changed while loading
+                if (id && src) {
+                    URL.revokeObjectURL(src);
+                }
+                return;
+            }
+            const image = this.#get('cover');
+            if (image.src.startsWith('blob:')) {
+                URL.revokeObjectURL(image.src);
+            }
+            image.hidden = !src;
+            image.src = src || '';
+        }
+
+        #renderQueue() {
+            const list = this.#get('queue');
+            const songs = this.#queue.queue || [];
+            if (songs.length === 0) {
+                list.replaceChildren(element('li', 'artist', 'Queue is empty'));
+                return;
+            }
+            list.replaceChildren(...songs.map((song, index) => {
+                const item = this.#songItem(song);
+                item.classList.toggle('current', index === this.#queue.queuePosition);
+                item.querySelector('.info').addEventListener('click', () => this.#playAt(index));
+                item.append(
+                    this.#button('↑', 'Move up', index === 0, () => this.#editQueue(queue => QueueEdits.move(queue, index, -1))),
+                    this.#button('↓', 'Move down', index === songs.length - 1, () => this.#editQueue(queue => QueueEdits.move(queue, index, 1))),
+                    this.#button('✕', 'Remove', false, () => this.#editQueue(queue => QueueEdits.remove(queue, index))),
+                );
+                return item;
+            }));
+        }
+
+        #renderSearchResults(songs) {
+            const list = this.#get('searchResults');
+            if (songs.length === 0) {
+                list.replaceChildren(element('li', 'artist', 'Nothing found'));
+                return;
+            }
+            list.replaceChildren(...songs.map(song => {
+                const item = this.#songItem(song);
+                item.append(
+                    this.#button('⤵', 'Play next', false, () => this.#editQueue(queue => QueueEdits.add(queue, song, true))),
+                    this.#button('+', 'Add to queue', false, () => this.#editQueue(queue => QueueEdits.add(queue, song, false))),
+                );
+                return item;
+            }));
+        }
+
+        #songItem(song) {
+            const item = element('li');
+            const info = element('div', 'info');
+            info.append(element('div', 'name', song.name), element('div', 'artist', `${song.artist} · ${formatTime(song.duration)}`));
+            item.append(info);
+            return item;
+        }
+
+        #button(text, title, disabled, onClick) {
+            const button = element('button', null, text);
+            button.title = title;
+            button.disabled = disabled;
+            button.addEventListener('click', onClick);
+            return button;
+        }
+
+        // edits the latest server queue so changes made by others in the meantime are not lost
+        async #editQueue(edit) {
+            const queue = await this.#api.getQueue();
+            if (queue.error) {
+                this.#status(`Error loading queue: ${queue.error}`, 'error');
+                return false;
+            }
+            queue.queue = queue.queue || [];
+            if (!edit(queue)) {
+                return false;
+            }
+            const rs = await this.#api.postQueue(queue);
+            if (rs.error) {
+                this.#status(`Error saving queue: ${rs.error}`, 'error');
+                return false;
+            }
+            this.#queue = queue;
+            this.#renderQueue();
+            this.#renderNowPlaying();
+            return true;
+        }
+
+        async #playAt(index) {
+            if (await this.#editQueue(queue => QueueEdits.select(queue, index))) {
+                await this.#command('play');
+            }
+        }
+
+        async #command(command) {
+            const device = this.#device();
+            if (!device) {
+                this.#status('Select a device', 'warn');
+                return;
+            }
+            this.#status(`Sending ${command} to ${device.name}…`);
+            const rs = await this.#api.postCommand(command, device);
+            if (rs.error) {
+                this.#status(`Error sending ${command}: ${rs.error}`, 'error');
+                return;
+            }
+            this.#status('');
+            setTimeout(() => this.#refresh(), 1500); // device reports new state with a delay
+        }
+
+        #setVolume(volume) {
+            clearTimeout(this.#volumeTimer);
+            this.#volumeTimer = setTimeout(async () => {
+                const device = this.#device();
+                if (!device) {
+                    return;
+                }
+                const rs = await this.#api.postVolume(device, volume);
+                this.#status(rs.error ? `Error setting volume: ${rs.error}` : '', rs.error ? 'error' : '');
+            }, 500);
+        }
+
+        bind() {
+            this.#get('apiKey').value = this.#settings.getApiKey();
+            this.#get('settingsToggle').addEventListener('click', () => {
+                this.#get('settings').hidden = !this.#get('settings').hidden;
+            });
+            this.#get('settingsSave').addEventListener('click', async () => {
+                this.#settings.setApiKey(this.#get('apiKey').value);
+                this.#settings.save();
+                this.#get('settings').hidden = true;
+                await this.start();
+            });
+            this.#get('device').addEventListener('change', async event => {
+                this.#settings.setDeviceSerialNumber(event.target.value);
+                this.#settings.save();
+                await this.#loadVolume();
+                await this.#refresh();
+            });
+            for (const command of ['play', 'stop', 'next', 'prev']) {
+                this.#get(command).addEventListener('click', () => this.#command(command));
+            }
+            this.#get('volume').addEventListener('input', event => this.#setVolume(Number(event.target.value)));
+            this.#get('searchForm').addEventListener('submit', async event => {
+                event.preventDefault();
+                const query = this.#get('searchQuery').value.trim();
+                if (!query) {
+                    return;
+                }
+                const rs = await this.#api.search(query);
+                if (rs.error) {
+                    this.#status(`Search failed: ${rs.error}`, 'error');
+                    return;
+                }
+                this.#renderSearchResults(rs.songs || []);
+            });
+            this.#get('resume').addEventListener('click', async () => {
+                const rs = await this.#api.postResume();
+                this.#status(rs.error ? `Resume failed: ${rs.error}` : `Loaded ${rs.songs} songs from Navidrome`, rs.error ? 'error' : '');
+                await this.#refresh();
+            });
+            setInterval(() => {
+                if (!document.hidden) {
+                    this.#refresh();
+                }
+            }, pollInterval);
+            setInterval(() => this.#renderNowPlaying(), 1000);
+        }
+
+        async start() {
+            await this.#loadDevices();
+            await this.#loadLibrary();
+            await this.#refresh();
+        }
+    }
+
+    return {Settings, RemoteAPI, Remote, QueueEdits};
+})();
+
+window.addEventListener('DOMContentLoaded', () => {
+    const settings = new naRemoteModule.Settings(localStorage);
+    const remote = new naRemoteModule.Remote(settings, new naRemoteModule.RemoteAPI(settings));
+    remote.bind();
+    remote.start();
+});
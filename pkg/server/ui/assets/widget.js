@@ -1,4 +1,6 @@
 const naWidgetModule = (function () {
+    // set when widget is injected by NA Navidrome proxy, NA API is then on the same origin
+    const defaultApiUrl = document.currentScript ? document.currentScript.dataset.apiUrl : undefined;
 
     class PubSub {
         #statusUpdatedListeners;
@@ -62,15 +64,20 @@ const naWidgetModule = (function () {
         #storage;
         #data;
         #dirty;
+        #defaultApiUrl;
 
-        constructor(storage) {
+        constructor(storage, defaultApiUrl) {
             this.#storage = storage;
             this.#data = {};
             this.#dirty = true;
+            this.#defaultApiUrl = defaultApiUrl;
         }
 
         load() {
             this.#data = JSON.parse(this.#storage.getItem('naWidgetSettings')) || {};
+            if (!this.isApiUrlSet() && this.#defaultApiUrl) {
+                this.#data.apiUrl = this.#defaultApiUrl;
+            }
             this.#dirty = false;
         }
 
@@ -127,6 +134,18 @@ const naWidgetModule = (function () {
             return this.#data.apiKey && this.#data.apiKey.trim().length > 0;
         }
 
+        // credentials Navidrome UI keeps for Subsonic calls, used when api key is not set
+        getNavidromeCredentials() {
+            const user = this.#storage.getItem('username');
+            const token = this.#storage.getItem('subsonic-token');
+            const salt = this.#storage.getItem('subsonic-salt');
+            return user && token && salt ? {user: user, token: token, salt: salt} : null;
+        }
+
+        isAuthSet() {
+            return this.isApiKeySet() || this.getNavidromeCredentials() !== null;
+        }
+
         isDevicesSet() {
             return !!(this.#data.devices);
         }
@@ -190,7 +209,14 @@ const naWidgetModule = (function () {
         async #callAPI(method, path, requestBody) {
             try {
                 let headers = new Headers();
-                headers.append('Authorization', `Bearer ${this.#settingsAPI.getApiKey()}`);
+                const credentials = this.#settingsAPI.getNavidromeCredentials();
+                if (this.#settingsAPI.isApiKeySet() || !credentials) {
+                    headers.append('Authorization', `Bearer ${this.#settingsAPI.getApiKey()}`);
+                } else {
+                    headers.append('X-Subsonic-User', credentials.user);
+                    headers.append('X-Subsonic-Token', credentials.token);
+                    headers.append('X-Subsonic-Salt', credentials.salt);
+                }
                 let request = {
                     method: method,
                     headers: headers,
@@ -253,7 +279,7 @@ const naWidgetModule = (function () {
                 this.#settingsAPI.getDevices().forEach(device => {
                     const option = document.createElement('option');
                     option.value = JSON.stringify(device);
-                    option.textContent = device.name;
+                    option.textContent = device.online === false ? `${device.name} (offline)` : device.name;
                     if (deviceSelected) {
                         if (deviceSelected.serialNumber === device.serialNumber) {
                             option.selected = true;
@@ -300,7 +326,7 @@ const naWidgetModule = (function () {
             this.#checkElement.addEventListener('click', async () => {
                 this.#checkElement.classList.add('disabled');
                 try {
-                    if (this.#apiKeyElement.value && this.#apiUrlElement.value) {
+                    if ((this.#apiKeyElement.value || this.#settingsAPI.getNavidromeCredentials()) && this.#apiUrlElement.value) {
                         const devices = await this.#playerAPI.getDevices();
                         if (devices.devices) {
                             this.#settingsAPI.setDevices(devices.devices);
@@ -346,7 +372,7 @@ const naWidgetModule = (function () {
 
             setInterval(async () => {
                 if (this.#settingsAPI.isDirty()
-                    || !this.#settingsAPI.isApiKeySet()
+                    || !this.#settingsAPI.isAuthSet()
                     || !this.#settingsAPI.isApiUrlSet()
                     || !this.#settingsAPI.isDeviceSelected()) {
                     return;
@@ -410,7 +436,7 @@ const naWidgetModule = (function () {
         }
 
         async #getCurrentVolume() {
-            if (this.#settingsAPI.isApiKeySet() && this.#settingsAPI.isApiUrlSet() && this.#settingsAPI.isDeviceSelected()) {
+            if (this.#settingsAPI.isAuthSet() && this.#settingsAPI.isApiUrlSet() && this.#settingsAPI.isDeviceSelected()) {
                 const volumeRS = await this.#playerAPI.getVolume();
                 if (volumeRS.error || !volumeRS.volumes) {
                     this.#pubSub.publishStatusUpdated('Error getting volume', volumeRS.error, 'error');
@@ -442,7 +468,7 @@ const naWidgetModule = (function () {
         }
 
         #toggleControls() {
-            if (!this.#settingsAPI.isDirty() && this.#settingsAPI.isApiKeySet() && this.#settingsAPI.isApiUrlSet() && this.#settingsAPI.isDeviceSelected()) {
+            if (!this.#settingsAPI.isDirty() && this.#settingsAPI.isAuthSet() && this.#settingsAPI.isApiUrlSet() && this.#settingsAPI.isDeviceSelected()) {
                 this.#playButtonElement.classList.remove('disabled');
                 this.#stopButtonElement.classList.remove('disabled');
                 this.#prevButtonElement.classList.remove('disabled');
@@ -824,7 +850,7 @@ const naWidgetModule = (function () {
                 try {
                     const widget = new Widget();
                     document.body.appendChild(widget);
-                    const settingsAPI = new SettingsLocalStorageAPI(localStorage);
+                    const settingsAPI = new SettingsLocalStorageAPI(localStorage, defaultApiUrl);
                     const queueAPI = new QueueLocalStorageAPI(localStorage);
                     const playerAPI = new PlayerAPI(settingsAPI);
                     const pubSub = new PubSub();
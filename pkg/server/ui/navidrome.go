@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"bytes"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// NaPathPrefix is where NA itself is served in Navidrome proxy mode, everything else goes to Navidrome
+const NaPathPrefix = "/na"
+
+const widgetScript = `<script src="` + NaPathPrefix + `/static/widget.js" data-api-url="` + NaPathPrefix + `"></script>`
+
+// NavidromeProxy reverse proxies Navidrome and injects the widget into its html pages, so no external proxy
+// rewrite is needed. Websockets, server sent events and range requests (streaming, seeking) are passed through as is
+type NavidromeProxy struct {
+	proxy  *httputil.ReverseProxy
+	na     http.Handler
+	target atomic.Pointer[url.URL]
+}
+
+func NewNavidromeProxy(navidromeURL *url.URL, na http.Handler) *NavidromeProxy {
+	p := &NavidromeProxy{na: http.StripPrefix(NaPathPrefix, na)}
+	p.SetTarget(navidromeURL)
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite: func(request *httputil.ProxyRequest) {
+			request.SetURL(p.target.Load())
+			request.SetXForwarded()
+			if strings.Contains(request.In.Header.Get("Accept"), "text/html") {
+				request.Out.Header.Set("Accept-Encoding", "identity") // pages are rewritten, get them uncompressed
+			}
+		},
+		ModifyResponse: injectWidget,
+		FlushInterval:  -1, // streams and events are sent as they come
+		ErrorHandler: func(writer http.ResponseWriter, request *http.Request, err error) {
+			log.Logger().Error("Navidrome proxy failed", "path", request.URL.Path, "error", err)
+			writer.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	return p
+}
+
+// SetTarget points proxy to another Navidrome, requests in flight finish with the old one
+func (p *NavidromeProxy) SetTarget(navidromeURL *url.URL) {
+	p.target.Store(navidromeURL)
+}
+
+func (p *NavidromeProxy) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.URL.Path == NaPathPrefix || strings.HasPrefix(request.URL.Path, NaPathPrefix+"/") {
+		p.na.ServeHTTP(writer, request)
+		return
+	}
+	p.proxy.ServeHTTP(writer, request)
+}
+
+// injectWidget adds widget script to uncompressed html pages (Navidrome index.html), other responses are untouched
+func injectWidget(response *http.Response) error {
+	if response.StatusCode != http.StatusOK ||
+		!strings.HasPrefix(response.Header.Get("Content-Type"), "text/html") ||
+		response.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	page, err := io.ReadAll(io.LimitReader(response.Body, MaxSize+1))
+	if err != nil {
+		return errors.Wrap(err, "injectWidget reading page failed")
+	}
+	if len(page) > MaxSize { // not a Navidrome page, pass it through
+		response.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(page), response.Body), Closer: response.Body}
+		return nil
+	}
+	_ = response.Body.Close()
+	page = injectScript(page)
+	response.Body = io.NopCloser(bytes.NewReader(page))
+	response.ContentLength = int64(len(page))
+	response.Header.Set("Content-Length", strconv.Itoa(len(page)))
+	response.Header.Del("ETag") // content differs from upstream
+	return nil
+}
+
+func injectScript(page []byte) []byte {
+	for _, tag := range []string{"</head>", "</body>"} {
+		if index := bytes.Index(bytes.ToLower(page), []byte(tag)); index >= 0 {
+			return append(page[:index:index], append([]byte(widgetScript), page[index:]...)...)
+		}
+	}
+	return append(page, []byte(widgetScript)...)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,139 @@
+package ui
+
+import (
+	"bufio"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const navidromeIndex = `<!doctype html><html><head><title>Navidrome</title></head><body><div id="root"></div></body></html>`
+
+func TestNavidromeProxy(t *testing.T) {
+
+	log.InitWithLogger(slog.Default())
+
+	navidrome := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/app/":
+			if request.Header.Get("Accept-Encoding") != "identity" {
+				t.Error("html pages should be requested uncompressed")
+			}
+			writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+			writer.Header().Set("ETag", `"index"`)
+			_, _ = io.WriteString(writer, navidromeIndex)
+		case "/rest/stream":
+			http.ServeContent(writer, request, "song.mp3", time.Time{}, strings.NewReader("0123456789"))
+		case "/ws":
+			upgrade(t, writer, request)
+		default:
+			writer.Header().Set("Content-Type", "application/javascript")
+			_, _ = io.WriteString(writer, "console.log('</head>')")
+		}
+	}))
+	defer navidrome.Close()
+	navidromeURL, _ := url.Parse(navidrome.URL)
+	na := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = io.WriteString(writer, "na "+request.URL.Path)
+	})
+	proxy := httptest.NewServer(NewNavidromeProxy(navidromeURL, na))
+	defer proxy.Close()
+
+	t.Run("NavidromeProxy, injects widget into index page", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, proxy.URL+"/app/", nil)
+		request.Header.Set("Accept", "text/html,application/xhtml+xml")
+		request.Header.Set("Accept-Encoding", "gzip")
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		body := readBody(t, response)
+		assert.Equal(t, `<!doctype html><html><head><title>Navidrome</title>`+
+			`<script src="/na/static/widget.js" data-api-url="/na"></script></head><body><div id="root"></div></body></html>`, body)
+		assert.Equal(t, int64(len(body)), response.ContentLength)
+		assert.Empty(t, response.Header.Get("ETag"))
+	})
+
+	t.Run("NavidromeProxy, other content is untouched", func(t *testing.T) {
+		response, err := http.Get(proxy.URL + "/app/assets/index.js")
+		assert.NoError(t, err)
+		assert.Equal(t, "console.log('</head>')", readBody(t, response))
+	})
+
+	t.Run("NavidromeProxy, passes range requests", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, proxy.URL+"/rest/stream?id=1", nil)
+		request.Header.Set("Range", "bytes=2-5")
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusPartialContent, response.StatusCode)
+		assert.Equal(t, "bytes 2-5/10", response.Header.Get("Content-Range"))
+		assert.Equal(t, "2345", readBody(t, response))
+	})
+
+	t.Run("NavidromeProxy, passes websockets", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, proxy.URL+"/ws", nil)
+		request.Header.Set("Connection", "Upgrade")
+		request.Header.Set("Upgrade", "websocket")
+		response, err := http.DefaultClient.Do(request)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusSwitchingProtocols, response.StatusCode)
+		connection := response.Body.(io.ReadWriteCloser)
+		defer connection.Close()
+		_, err = io.WriteString(connection, "ping\n")
+		assert.NoError(t, err)
+		line, err := bufio.NewReader(connection).ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "echo ping\n", line)
+	})
+
+	t.Run("NavidromeProxy, serves NA under prefix", func(t *testing.T) {
+		response, err := http.Get(proxy.URL + "/na/api/queue")
+		assert.NoError(t, err)
+		assert.Equal(t, "na /api/queue", readBody(t, response))
+	})
+
+	t.Run("NavidromeProxy, Navidrome unavailable", func(t *testing.T) {
+		unavailableURL, _ := url.Parse("http://127.0.0.1:1")
+		unavailable := httptest.NewServer(NewNavidromeProxy(unavailableURL, na))
+		defer unavailable.Close()
+		response, err := http.Get(unavailable.URL + "/app/")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadGateway, response.StatusCode)
+	})
+
+}
+
+func TestInjectScript(t *testing.T) {
+	assert.Equal(t, "<BODY>x"+widgetScript+"</BODY>", string(injectScript([]byte("<BODY>x</BODY>"))))
+	assert.Equal(t, "x"+widgetScript, string(injectScript([]byte("x"))))
+}
+
+// upgrade switches connection protocol and echoes lines back
+func upgrade(t *testing.T, writer http.ResponseWriter, request *http.Request) {
+	if request.Header.Get("Upgrade") != "websocket" {
+		t.Error("upgrade header should be passed")
+	}
+	connection, buffer, err := writer.(http.Hijacker).Hijack()
+	assert.NoError(t, err)
+	defer connection.Close()
+	_, _ = buffer.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+	_ = buffer.Flush()
+	line, err := buffer.ReadString('\n')
+	if err != nil {
+		return
+	}
+	_, _ = buffer.WriteString("echo " + line)
+	_ = buffer.Flush()
+}
+
+func readBody(t *testing.T, response *http.Response) string {
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
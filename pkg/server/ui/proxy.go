@@ -1,61 +1,231 @@
 package ui
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ahimgit/navidrome-alexa/pkg/util/log"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const MaxSize = 3 * 1024 * 1024
+const widgetCacheMax = 16
 
-func GetWidget(c *gin.Context) {
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+var errUpstreamStatus = errors.New("unexpected upstream status")
+var errTooLarge = errors.New("remote file is too large")
+
+// WidgetProxy serves Navidrome UI script from allowed upstream hosts with widget appended.
+// Combined scripts are cached and revalidated upstream with conditional requests, stale copy is served if upstream fails
+type WidgetProxy struct {
+	allowedHosts atomic.Pointer[[]string]
+	client       *http.Client
+	mutex        sync.Mutex
+	cache        map[string]*cachedWidget
+	now          func() time.Time
+}
+
+type cachedWidget struct {
+	content              []byte
+	etag                 string
+	lastModified         time.Time
+	upstreamETag         string
+	upstreamLastModified string
+}
+
+// NewWidgetProxy hosts are host, host:port or host:* (any port) of URLs proxy may fetch from
+func NewWidgetProxy(allowedHosts []string, timeout time.Duration) *WidgetProxy {
+	proxy := &WidgetProxy{
+		client: &http.Client{Timeout: timeout, CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse // redirects could lead outside allowed hosts
+		}},
+		cache: map[string]*cachedWidget{},
+		now:   time.Now,
+	}
+	proxy.SetAllowedHosts(allowedHosts)
+	return proxy
+}
+
+func (p *WidgetProxy) SetAllowedHosts(allowedHosts []string) {
+	p.allowedHosts.Store(&allowedHosts)
+}
+
+func (p *WidgetProxy) GetWidget(c *gin.Context) {
 	proxied := c.Query("proxied")
-	if err := validateURL(proxied); err != nil {
+	proxiedURL, err := validateURL(proxied)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
-	proxiedContent, err := fetchWithLimit(proxied, MaxSize)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !p.isAllowed(proxiedURL) {
+		log.GetRequestContextLogger(c).Error("Widget proxy host not allowed", "proxied", proxied)
+		c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "host " + proxiedURL.Host + " is not allowed"})
 		return
 	}
-	widgetContent, err := assets.ReadFile("assets/widget.js")
+	widget, err := p.fetch(c.Request.Context(), proxied)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		log.GetRequestContextLogger(c).Error("Widget proxy upstream failed", "proxied", proxied, "error", err)
+		c.JSON(upstreamErrorStatus(err), gin.H{"status": "error", "message": err.Error()})
 		return
 	}
-	combinedContent := string(proxiedContent) + "\n\n\n // NA widget\n" + string(widgetContent)
-	c.Header("Content-Type", "application/javascript")
-	c.String(http.StatusOK, combinedContent)
+	c.Header("ETag", widget.etag)
+	c.Header("Last-Modified", widget.lastModified.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-cache") // always revalidate, Navidrome asset names change with releases anyway
+	if isNotModified(c.Request, widget) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/javascript", widget.content)
 }
 
-func validateURL(rawURL string) error {
-	parsedURL, err := url.ParseRequestURI(rawURL)
+// isAllowed matches host:port of proxied URL, allowed host without port means default port of URL scheme
+// and host:* allows any port
+func (p *WidgetProxy) isAllowed(proxiedURL *url.URL) bool {
+	proxiedHost := HostPort(proxiedURL.Host, proxiedURL.Scheme)
+	for _, allowedHost := range *p.allowedHosts.Load() {
+		if hostname, anyPort := strings.CutSuffix(allowedHost, ":*"); anyPort {
+			if strings.EqualFold(strings.Trim(hostname, "[]"), proxiedURL.Hostname()) {
+				return true
+			}
+		} else if strings.EqualFold(HostPort(allowedHost, proxiedURL.Scheme), proxiedHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// HostPort is host with port, default port of scheme is added if host has none
+func HostPort(host string, scheme string) string {
+	parsed := &url.URL{Host: host}
+	port := parsed.Port()
+	if port == "" {
+		port = defaultPorts[scheme]
+	}
+	return net.JoinHostPort(parsed.Hostname(), port)
+}
+
+// fetch returns cached widget if upstream has not changed, stale widget if upstream fails
+func (p *WidgetProxy) fetch(ctx context.Context, proxied string) (*cachedWidget, error) {
+	p.mutex.Lock()
+	cached := p.cache[proxied]
+	p.mutex.Unlock()
+	widget, err := p.revalidate(ctx, proxied, cached)
 	if err != nil {
-		return err
+		if cached != nil {
+			log.Logger().Warn("Widget proxy upstream failed, serving cached copy", "proxied", proxied, "error", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	if widget != cached {
+		p.mutex.Lock()
+		if len(p.cache) >= widgetCacheMax {
+			p.cache = map[string]*cachedWidget{}
+		}
+		p.cache[proxied] = widget
+		p.mutex.Unlock()
 	}
+	return widget, nil
+}
 
-	if !(parsedURL.Scheme == "http" || parsedURL.Scheme == "https") {
-		return errors.New("invalid URL scheme")
+func (p *WidgetProxy) revalidate(ctx context.Context, proxied string, cached *cachedWidget) (*cachedWidget, error) {
+	rq, err := http.NewRequestWithContext(ctx, http.MethodGet, proxied, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "WidgetProxy.revalidate creating request failed")
 	}
-	return nil
+	if cached != nil {
+		if cached.upstreamETag != "" {
+			rq.Header.Set("If-None-Match", cached.upstreamETag)
+		}
+		if cached.upstreamLastModified != "" {
+			rq.Header.Set("If-Modified-Since", cached.upstreamLastModified)
+		}
+	}
+	rs, err := p.client.Do(rq)
+	if err != nil {
+		return nil, errors.Wrap(err, "WidgetProxy.revalidate failed")
+	}
+	defer rs.Body.Close()
+	if rs.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, nil
+	}
+	if rs.StatusCode != http.StatusOK {
+		return nil, errors.Wrap(errUpstreamStatus, "WidgetProxy.revalidate upstream status "+strconv.Itoa(rs.StatusCode))
+	}
+	upstreamContent, err := readWithLimit(rs.Body, MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	widgetContent, err := assets.ReadFile("assets/widget.js")
+	if err != nil {
+		return nil, errors.Wrap(err, "WidgetProxy.revalidate reading widget failed")
+	}
+	content := append(upstreamContent, []byte("\n\n\n // NA widget\n"+string(widgetContent))...)
+	hash := sha256.Sum256(content)
+	lastModified, err := http.ParseTime(rs.Header.Get("Last-Modified"))
+	if err != nil {
+		lastModified = p.now()
+	}
+	return &cachedWidget{
+		content:              content,
+		etag:                 `"` + hex.EncodeToString(hash[:16]) + `"`,
+		lastModified:         lastModified,
+		upstreamETag:         rs.Header.Get("ETag"),
+		upstreamLastModified: rs.Header.Get("Last-Modified"),
+	}, nil
 }
 
-func fetchWithLimit(rawURL string, maxSize int64) ([]byte, error) {
-	resp, err := http.Get(rawURL)
+// isNotModified checks client conditional headers, If-None-Match takes precedence as in RFC 9110
+func isNotModified(request *http.Request, widget *cachedWidget) bool {
+	if ifNoneMatch := request.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return ifNoneMatch == widget.etag || ifNoneMatch == "*"
+	}
+	ifModifiedSince, err := http.ParseTime(request.Header.Get("If-Modified-Since"))
+	return err == nil && !widget.lastModified.Truncate(time.Second).After(ifModifiedSince)
+}
+
+func upstreamErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) || isTimeout(err) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+func isTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+func validateURL(rawURL string) (*url.URL, error) {
+	parsedURL, err := url.ParseRequestURI(rawURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	limitedReader := &io.LimitedReader{R: resp.Body, N: maxSize}
+	if !(parsedURL.Scheme == "http" || parsedURL.Scheme == "https") {
+		return nil, errors.New("invalid URL scheme")
+	}
+	return parsedURL, nil
+}
+
+func readWithLimit(reader io.Reader, maxSize int64) ([]byte, error) {
+	limitedReader := &io.LimitedReader{R: reader, N: maxSize + 1}
 	content, err := io.ReadAll(limitedReader)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "readWithLimit failed")
 	}
 	if limitedReader.N <= 0 {
-		return nil, errors.New("remote file is too large")
+		return nil, errTooLarge
 	}
 	return content, nil
 }
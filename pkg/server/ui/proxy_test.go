@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"github.com/ahimgit/navidrome-alexa/pkg/util/tests"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const upstreamLastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+func TestWidgetProxy(t *testing.T) {
+
+	var fetches, notModified atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/app/assets/index.js":
+			fetches.Add(1)
+			if request.Header.Get("If-None-Match") == `"v1"` && request.Header.Get("If-Modified-Since") == upstreamLastModified {
+				notModified.Add(1)
+				writer.WriteHeader(http.StatusNotModified)
+				return
+			}
+			writer.Header().Set("ETag", `"v1"`)
+			writer.Header().Set("Last-Modified", upstreamLastModified)
+			_, _ = io.WriteString(writer, "navidrome();")
+		case "/slow.js":
+			time.Sleep(200 * time.Millisecond)
+		case "/large.js":
+			_, _ = io.WriteString(writer, strings.Repeat("x", MaxSize+1))
+		case "/redirect.js":
+			http.Redirect(writer, request, "http://internal.example.com/", http.StatusFound)
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+
+	t.Run("GetWidget, combines upstream script with widget", func(t *testing.T) {
+		proxy := NewWidgetProxy([]string{upstreamURL.Host}, time.Second)
+		recorder := getWidget(proxy, upstream.URL+"/app/assets/index.js", nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/javascript", recorder.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(recorder.Body.String(), "navidrome();\n\n\n // NA widget\nconst naWidgetModule"))
+		assert.Equal(t, upstreamLastModified, recorder.Header().Get("Last-Modified"))
+		assert.NotEmpty(t, recorder.Header().Get("ETag"))
+	})
+
+	t.Run("GetWidget, revalidates upstream and client conditionally", func(t *testing.T) {
+		proxy := NewWidgetProxy([]string{upstreamURL.Hostname() + ":*"}, time.Second)
+		fetches.Store(0)
+		notModified.Store(0)
+		first := getWidget(proxy, upstream.URL+"/app/assets/index.js", nil)
+		etag := first.Header().Get("ETag")
+
+		cached := getWidget(proxy, upstream.URL+"/app/assets/index.js", nil)
+		assert.Equal(t, http.StatusOK, cached.Code)
+		assert.Equal(t, first.Body.String(), cached.Body.String())
+		assert.Equal(t, etag, cached.Header().Get("ETag"))
+
+		byETag := getWidget(proxy, upstream.URL+"/app/assets/index.js", http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusNotModified, byETag.Code)
+		assert.Empty(t, byETag.Body.String())
+
+		byDate := getWidget(proxy, upstream.URL+"/app/assets/index.js", http.Header{"If-Modified-Since": {upstreamLastModified}})
+		assert.Equal(t, http.StatusNotModified, byDate.Code)
+
+		changed := getWidget(proxy, upstream.URL+"/app/assets/index.js", http.Header{"If-None-Match": {`"old"`}})
+		assert.Equal(t, http.StatusOK, changed.Code)
+
+		assert.Equal(t, int32(5), fetches.Load())
+		assert.Equal(t, int32(4), notModified.Load())
+	})
+
+	t.Run("GetWidget, serves cached copy if upstream fails", func(t *testing.T) {
+		proxy := NewWidgetProxy([]string{upstreamURL.Host}, time.Second)
+		first := getWidget(proxy, upstream.URL+"/app/assets/index.js", nil)
+		proxy.client.Transport = failingTransport{}
+		stale := getWidget(proxy, upstream.URL+"/app/assets/index.js", nil)
+		assert.Equal(t, http.StatusOK, stale.Code)
+		assert.Equal(t, first.Body.String(), stale.Body.String())
+	})
+
+	t.Run("GetWidget, errors", func(t *testing.T) {
+		proxy := NewWidgetProxy([]string{upstreamURL.Host}, time.Second)
+		for _, test := range []struct {
+			proxied string
+			status  int
+			message string
+		}{
+			{"", http.StatusBadRequest, "parse \"\": empty url"},
+			{"file:///etc/passwd", http.StatusBadRequest, "invalid URL scheme"},
+			{"http://169.254.169.254/latest/meta-data", http.StatusForbidden, "host 169.254.169.254 is not allowed"},
+			{upstream.URL + "/missing.js", http.StatusBadGateway, "WidgetProxy.revalidate upstream status 404: unexpected upstream status"},
+			{upstream.URL + "/redirect.js", http.StatusBadGateway, "WidgetProxy.revalidate upstream status 302: unexpected upstream status"},
+			{upstream.URL + "/large.js", http.StatusBadGateway, "remote file is too large"},
+		} {
+			recorder := getWidget(proxy, test.proxied, nil)
+			assert.Equal(t, test.status, recorder.Code, test.proxied)
+			assert.JSONEq(t, `{"status":"error","message":"`+strings.ReplaceAll(test.message, `"`, `\"`)+`"}`, recorder.Body.String(), test.proxied)
+		}
+		timeout := getWidget(NewWidgetProxy([]string{upstreamURL.Host}, 50*time.Millisecond), upstream.URL+"/slow.js", nil)
+		assert.Equal(t, http.StatusGatewayTimeout, timeout.Code)
+	})
+
+	t.Run("SetAllowedHosts, replaces allowlist", func(t *testing.T) {
+		proxy := NewWidgetProxy([]string{"navidrome.example.com"}, time.Second)
+		assert.Equal(t, http.StatusForbidden, getWidget(proxy, upstream.URL+"/app/assets/index.js", nil).Code)
+		proxy.SetAllowedHosts([]string{upstreamURL.Host})
+		assert.Equal(t, http.StatusOK, getWidget(proxy, upstream.URL+"/app/assets/index.js", nil).Code)
+	})
+
+}
+
+func TestWidgetProxyIsAllowed(t *testing.T) {
+	proxy := NewWidgetProxy([]string{"navidrome.example.com", "localhost:4533", "navidrome:*", "[::1]:4533"}, time.Second)
+	for _, test := range []struct {
+		proxied string
+		allowed bool
+	}{
+		{"https://navidrome.example.com/app/index.js", true},
+		{"http://navidrome.example.com/app/index.js", true},
+		{"https://navidrome.example.com:443/app/index.js", true},
+		{"https://NAVIDROME.example.com/app/index.js", true},
+		{"https://navidrome.example.com:8443/app/index.js", false},
+		{"http://localhost:4533/app/index.js", true},
+		{"http://localhost/app/index.js", false},
+		{"http://localhost:6379/app/index.js", false},
+		{"http://navidrome:4533/app/index.js", true},
+		{"http://navidrome/app/index.js", true},
+		{"http://[::1]:4533/app/index.js", true},
+		{"http://[::1]:22/app/index.js", false},
+	} {
+		proxiedURL, _ := url.Parse(test.proxied)
+		assert.Equal(t, test.allowed, proxy.isAllowed(proxiedURL), test.proxied)
+	}
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+func getWidget(proxy *WidgetProxy, proxied string, headers http.Header) *httptest.ResponseRecorder {
+	request := tests.MockJSONGet("/proxy?proxied=" + url.QueryEscape(proxied))
+	for name, values := range headers {
+		request.Header[name] = values
+	}
+	context, recorder := tests.MockGin(request)
+	proxy.GetWidget(context)
+	return recorder
+}
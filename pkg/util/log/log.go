@@ -6,14 +6,16 @@ import (
 	"github.com/rs/xid"
 	"log/slog"
 	"os"
+	"sync/atomic"
 )
 
 type loggerKeyType string
 
 const loggerKey loggerKeyType = "Logger"
 
-var rootLogger *slog.Logger
+var rootLogger atomic.Pointer[slog.Logger] // swapped on config reload
 
+// Init sets up root logger, can be called again at runtime to change log format and level
 func Init(structured bool, level slog.Level) {
 	var newLogger *slog.Logger
 	if structured {
@@ -25,14 +27,14 @@ func Init(structured bool, level slog.Level) {
 }
 
 func InitWithLogger(logger *slog.Logger) {
-	rootLogger = logger
+	rootLogger.Store(logger)
 	slog.SetDefault(logger)
 }
 
 func CreateRequestContextLogger(c *gin.Context) *slog.Logger {
 	requestId := xid.New().String()
 	correlationId := nvl(c.Request.Header.Get("X-Correlation-ID"), xid.New().String())
-	logger := rootLogger.With(
+	logger := rootLogger.Load().With(
 		"RequestID", requestId,
 		"CorrelationID", correlationId,
 	)
@@ -44,8 +46,13 @@ func CreateRequestContextLogger(c *gin.Context) *slog.Logger {
 	return logger
 }
 
+// AddRequestContextLoggerAttrs adds attributes to request logger for the rest of the request
+func AddRequestContextLoggerAttrs(c *gin.Context, args ...any) {
+	c.Set(string(loggerKey), GetRequestContextLogger(c).With(args...))
+}
+
 func Logger() *slog.Logger {
-	return rootLogger
+	return rootLogger.Load()
 }
 
 func CreateLoggerContext(ginContext *gin.Context) context.Context {
@@ -56,7 +63,7 @@ func GetRequestContextLogger(ginContext *gin.Context) *slog.Logger {
 	if val, exist := ginContext.Get(string(loggerKey)); exist {
 		return val.(*slog.Logger)
 	} else {
-		return rootLogger
+		return rootLogger.Load()
 	}
 }
 
@@ -65,7 +72,10 @@ func SetContextLogger(ctx context.Context, logger *slog.Logger) context.Context
 }
 
 func GetContextLogger(context context.Context) *slog.Logger {
-	return context.Value(loggerKey).(*slog.Logger)
+	if logger, exist := context.Value(loggerKey).(*slog.Logger); exist {
+		return logger
+	}
+	return rootLogger.Load()
 }
 
 func nvl(str, defaultStr string) string {
@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+const (
+	maxCommands = 100
+	maxDevices  = 100
+	deviceTTL   = 24 * time.Hour // learned device not seen again is dropped, skill device ids change on re-enabling the skill
+)
+
+// CommandLinks remembers spans of commands sent to devices, so skill requests Alexa sends as their result can link to them.
+// Skill requests carry skill device id instead of device serial number, the mapping is learned when all commands
+// sent within the window were for the same device, a later such request overwrites it, so a wrong guess doesn't stick
+type CommandLinks struct {
+	Window   time.Duration
+	mutex    sync.Mutex
+	commands []sentCommand
+	devices  map[string]learnedDevice // skill device id -> device serial number
+	now      func() time.Time
+}
+
+type learnedDevice struct {
+	serial    string
+	learnedAt time.Time
+}
+
+type sentCommand struct {
+	serial string
+	span   trace.SpanContext
+	sentAt time.Time
+}
+
+func NewCommandLinks(window time.Duration) *CommandLinks {
+	return &CommandLinks{
+		Window:  window,
+		devices: map[string]learnedDevice{},
+		now:     time.Now,
+	}
+}
+
+// Record remembers command span for the device, noop when tracing is off (no valid span in context) or links are nil
+func (l *CommandLinks) Record(ctx context.Context, serial string) {
+	span := trace.SpanContextFromContext(ctx)
+	if l == nil || !span.IsValid() {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.prune()
+	if len(l.commands) == maxCommands {
+		l.commands = l.commands[1:]
+	}
+	l.commands = append(l.commands, sentCommand{serial: serial, span: span, sentAt: l.now()})
+}
+
+// Links returns links to commands sent within the window to the device skill request came from,
+// or to all recent commands if skill device is not known yet
+func (l *CommandLinks) Links(skillDevice string) (links []trace.Link) {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.prune()
+	serials := map[string]bool{}
+	for _, command := range l.commands {
+		serials[command.serial] = true
+	}
+	if skillDevice != "" && len(serials) == 1 {
+		for serial := range serials {
+			l.learn(skillDevice, serial)
+		}
+	}
+	device, known := l.devices[skillDevice]
+	for _, command := range l.commands {
+		if !known || command.serial == device.serial {
+			links = append(links, trace.Link{SpanContext: command.span})
+		}
+	}
+	return links
+}
+
+// learn sets or overwrites device mapping, evicting the oldest one when full
+func (l *CommandLinks) learn(skillDevice string, serial string) {
+	if _, exists := l.devices[skillDevice]; !exists && len(l.devices) >= maxDevices {
+		oldest := ""
+		for id, device := range l.devices {
+			if oldest == "" || device.learnedAt.Before(l.devices[oldest].learnedAt) {
+				oldest = id
+			}
+		}
+		delete(l.devices, oldest)
+	}
+	l.devices[skillDevice] = learnedDevice{serial: serial, learnedAt: l.now()}
+}
+
+// AddLinks links current span in context to recent commands for the skill device
+func (l *CommandLinks) AddLinks(ctx context.Context, skillDevice string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	for _, link := range l.Links(skillDevice) {
+		span.AddLink(link)
+	}
+}
+
+func (l *CommandLinks) prune() {
+	cutoff := l.now().Add(-l.Window)
+	i := 0
+	for i < len(l.commands) && l.commands[i].sentAt.Before(cutoff) {
+		i++
+	}
+	l.commands = l.commands[i:]
+	for id, device := range l.devices {
+		if device.learnedAt.Before(l.now().Add(-deviceTTL)) {
+			delete(l.devices, id)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+	"time"
+)
+
+func TestCommandLinks(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	tracer := provider.Tracer("test")
+
+	t.Run("skill request links to recent commands and learns device", func(t *testing.T) {
+		links, now := testCommandLinks()
+		command1 := startSpan(tracer)
+		command2 := startSpan(tracer)
+		links.Record(command1, "serial1")
+		*now = now.Add(10 * time.Second)
+		links.Record(command2, "serial2")
+
+		assert.Equal(t, spanContexts(command1, command2), linkedSpans(links.Links("skillDevice1"))) // ambiguous, links both
+
+		*now = now.Add(25 * time.Second) // command1 is out of the window
+		assert.Equal(t, spanContexts(command2), linkedSpans(links.Links("skillDevice2")))
+
+		command3 := startSpan(tracer)
+		links.Record(command3, "serial1")
+		assert.Equal(t, spanContexts(command2), linkedSpans(links.Links("skillDevice2"))) // learned skillDevice2 is serial2
+		assert.Equal(t, spanContexts(command2, command3), linkedSpans(links.Links("skillDevice3")))
+	})
+
+	t.Run("newer single device observation overwrites learned device", func(t *testing.T) {
+		links, now := testCommandLinks()
+		command1 := startSpan(tracer)
+		links.Record(command1, "serial1")
+		assert.Equal(t, spanContexts(command1), linkedSpans(links.Links("skillDevice1"))) // learned as serial1, may be a wrong guess
+
+		*now = now.Add(time.Minute)
+		command2 := startSpan(tracer)
+		links.Record(command2, "serial2")
+
+		assert.Equal(t, spanContexts(command2), linkedSpans(links.Links("skillDevice1")))
+		assert.Equal(t, "serial2", links.devices["skillDevice1"].serial)
+	})
+
+	t.Run("learned devices expire and are bounded", func(t *testing.T) {
+		links, now := testCommandLinks()
+		links.Record(startSpan(tracer), "serial1")
+		for i := 0; i <= maxDevices; i++ {
+			*now = now.Add(time.Millisecond)
+			links.Links(fmt.Sprintf("skillDevice%d", i))
+		}
+		assert.Len(t, links.devices, maxDevices)
+		assert.NotContains(t, links.devices, "skillDevice0") // oldest is evicted
+
+		*now = now.Add(deviceTTL + time.Second)
+		links.Links("")
+		assert.Empty(t, links.devices)
+	})
+
+	t.Run("old commands are not linked", func(t *testing.T) {
+		links, now := testCommandLinks()
+		links.Record(startSpan(tracer), "serial1")
+		*now = now.Add(time.Minute)
+
+		assert.Empty(t, links.Links("skillDevice1"))
+	})
+
+	t.Run("noop without tracing or links", func(t *testing.T) {
+		links, _ := testCommandLinks()
+		links.Record(context.Background(), "serial1")
+		assert.Empty(t, links.Links("skillDevice1"))
+
+		var nilLinks *CommandLinks
+		nilLinks.Record(startSpan(tracer), "serial1")
+		nilLinks.AddLinks(context.Background(), "skillDevice1")
+		assert.Empty(t, nilLinks.Links("skillDevice1"))
+	})
+
+	t.Run("adds links to current span", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		recordingTracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+		links, _ := testCommandLinks()
+		command := startSpan(recordingTracer)
+		links.Record(command, "serial1")
+
+		ctx, span := recordingTracer.Start(context.Background(), "skill")
+		links.AddLinks(ctx, "skillDevice1")
+		span.End()
+
+		ended := recorder.Ended()
+		assert.Len(t, ended, 2)
+		skillSpan := ended[1]
+		assert.Equal(t, "skill", skillSpan.Name())
+		assert.Len(t, skillSpan.Links(), 1)
+		assert.Equal(t, trace.SpanContextFromContext(command), skillSpan.Links()[0].SpanContext)
+	})
+}
+
+func testCommandLinks() (*CommandLinks, *time.Time) {
+	now := time.Now()
+	links := NewCommandLinks(30 * time.Second)
+	links.now = func() time.Time { return now }
+	return links, &now
+}
+
+func startSpan(tracer trace.Tracer) context.Context {
+	ctx, span := tracer.Start(context.Background(), "command")
+	span.End()
+	return ctx
+}
+
+func spanContexts(ctxs ...context.Context) (spans []trace.SpanContext) {
+	for _, ctx := range ctxs {
+		spans = append(spans, trace.SpanContextFromContext(ctx))
+	}
+	return spans
+}
+
+func linkedSpans(links []trace.Link) (spans []trace.SpanContext) {
+	for _, link := range links {
+		spans = append(spans, link.SpanContext)
+	}
+	return spans
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"strings"
+)
+
+const (
+	tracerName = "github.com/ahimgit/navidrome-alexa"
+	tracesPath = "/v1/traces"
+)
+
+// Init sets up OTLP/HTTP trace exporter, with empty endpoint tracing stays off (noop tracer provider),
+// returned shutdown flushes pending spans
+func Init(ctx context.Context, endpoint string, serviceName string) (shutdown func(context.Context) error, err error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(tracesURL(endpoint)))
+	if err != nil {
+		return nil, errors.Wrap(err, "tracing.Init creating OTLP exporter failed")
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// tracesURL adds OTLP traces path to collector base URL (e.g. http://localhost:4318), exporter uses URL path as is
+func tracesURL(endpoint string) string {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || strings.Trim(parsed.Path, "/") != "" {
+		return endpoint
+	}
+	parsed.Path = tracesPath
+	return parsed.String()
+}
+
+// Tracer uses global provider, so spans are noop until Init is called with an endpoint
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestInit(t *testing.T) {
+
+	t.Run("Init, exports to OTLP traces path of collector base URL", func(t *testing.T) {
+		for _, testCase := range []struct {
+			suffix, path string
+		}{
+			{"", "/v1/traces"},
+			{"/", "/v1/traces"},
+			{"/otlp/traces", "/otlp/traces"},
+		} {
+			var mu sync.Mutex
+			var paths []string
+			collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				paths = append(paths, r.URL.Path)
+				mu.Unlock()
+			}))
+			previous := otel.GetTracerProvider()
+
+			shutdown, err := Init(context.Background(), collector.URL+testCase.suffix, "test")
+			require.NoError(t, err)
+			_, span := Tracer().Start(context.Background(), "span")
+			span.End()
+			require.NoError(t, shutdown(context.Background())) // flushes span
+
+			otel.SetTracerProvider(previous)
+			collector.Close()
+			mu.Lock()
+			assert.Equal(t, []string{testCase.path}, paths, testCase.suffix)
+			mu.Unlock()
+		}
+	})
+
+	t.Run("Init, stays off without endpoint", func(t *testing.T) {
+		shutdown, err := Init(context.Background(), "", "test")
+
+		assert.NoError(t, err)
+		assert.NoError(t, shutdown(context.Background()))
+	})
+}